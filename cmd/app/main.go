@@ -1,6 +1,7 @@
 package main
 
 import (
+	"context"
 	"csv-microservice/config"
 	"csv-microservice/controllers"
 	repository "csv-microservice/repositories"
@@ -9,6 +10,7 @@ import (
 	"csv-microservice/utils"
 	"fmt"
 	"log"
+	"time"
 
 	"github.com/gin-gonic/gin"
 	"github.com/joho/godotenv"
@@ -39,8 +41,46 @@ func main() {
 	// Initialize layers
 	repo := repository.NewRepository(db)
 	service := services.NewService(repo)
+	profiles, err := services.LoadMappingProfiles(config.GetMappingProfilesFile())
+	if err != nil {
+		log.Fatalf("Error loading mapping profiles: %v", err)
+	}
+	service.Profiles = profiles
+	transforms, err := services.LoadTransformRules(config.GetTransformRulesFile())
+	if err != nil {
+		log.Fatalf("Error loading transform rules: %v", err)
+	}
+	service.Transforms = transforms
+	service.UploadDir = config.GetUploadDir()
+	service.ResumeRetention = config.GetResumeRetention()
+	service.Reviewers = config.GetReviewerTokens()
+	go service.RunResumeSweep(context.Background())
+	service.Uploads = services.NewUploadStore(config.GetUploadSessionTTL())
+	if removed, err := service.Uploads.RemoveAbandoned(service.UploadDir, time.Now()); err != nil {
+		log.Printf("Error removing abandoned uploads: %v", err)
+	} else if removed > 0 {
+		log.Printf("Removed %d abandoned uploads", removed)
+	}
+	go service.Uploads.Run(context.Background())
+	service.Blobs, err = services.NewBlobStore(config.GetBlobDir(), config.GetBlobRetention())
+	if err != nil {
+		log.Fatalf("Error opening blob archive: %v", err)
+	}
+	go service.Blobs.Run(context.Background())
+	service.Scheduler = services.NewScheduler(config.GetIngestWorkers())
+	service.BatchSize = config.GetBatchSize()
+	service.CopyBatchSize = config.GetCopyBatchSize()
 	controller := controllers.NewController(service)
 
+	// Import files dropped into the watch folder, if one is configured
+	if watchDir := config.GetWatchDir(); watchDir != "" {
+		watcher, err := services.NewFolderWatcher(service, watchDir, config.GetWatchInterval())
+		if err != nil {
+			log.Fatalf("Error starting folder watcher: %v", err)
+		}
+		go watcher.Run(context.Background())
+	}
+
 	// Register routes
 	routes.RegisterRoutes(router, controller)
 
@@ -1,7 +1,151 @@
 package config
 
-import "os"
+import (
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// defaultWatchInterval is how often the drop folder is scanned when WATCH_INTERVAL is unset.
+const defaultWatchInterval = 10 * time.Second
+
+// Ingestion defaults used when the INGEST_* variables are unset.
+const (
+	defaultIngestWorkers = 10
+	defaultBatchSize     = 100
+	defaultCopyBatchSize = 5000
+)
+
+// defaultUploadSessionTTL is how long an idle chunked upload is kept when UPLOAD_SESSION_TTL is unset.
+const defaultUploadSessionTTL = 24 * time.Hour
+
+// defaultResumeRetention is how long the upload of an unfinished import is kept for
+// resuming when RESUME_RETENTION is unset.
+const defaultResumeRetention = 7 * 24 * time.Hour
+
+// Blob archive defaults used when BLOB_DIR and BLOB_RETENTION are unset.
+const (
+	defaultBlobDir       = "blobs"
+	defaultBlobRetention = 30 * 24 * time.Hour
+)
 
 func GetDBConnectionString() string {
 	return os.Getenv("DB_CONNECTION_STRING")
 }
+
+// GetMappingProfilesFile returns the path of the JSON file holding CSV mapping profiles.
+func GetMappingProfilesFile() string {
+	return os.Getenv("MAPPING_PROFILES_FILE")
+}
+
+// GetTransformRulesFile returns the path of the JSON file holding the transformation
+// rules applied to imported rows.
+func GetTransformRulesFile() string {
+	return os.Getenv("TRANSFORM_RULES_FILE")
+}
+
+// GetWatchDir returns the drop folder watched for files to import. Empty disables the watcher.
+func GetWatchDir() string {
+	return os.Getenv("WATCH_DIR")
+}
+
+// GetUploadDir returns where uploads are stored while they are imported, e.g.
+// UPLOAD_DIR=/var/lib/csv/uploads. Uploads of imports that did not complete stay there
+// for RESUME_RETENTION so the imports can be resumed. Empty uses the system temp directory.
+func GetUploadDir() string {
+	return os.Getenv("UPLOAD_DIR")
+}
+
+// GetResumeRetention returns how long the upload of an import that did not complete is
+// kept for resuming, e.g. RESUME_RETENTION=72h. The import can no longer be resumed
+// after that. 0 keeps uploads forever. Missing or invalid values fall back to 7 days.
+func GetResumeRetention() time.Duration {
+	retention, err := time.ParseDuration(os.Getenv("RESUME_RETENTION"))
+	if err != nil || retention < 0 {
+		return defaultResumeRetention
+	}
+	return retention
+}
+
+// GetBlobDir returns where uploaded files are archived for re-imports, e.g.
+// BLOB_DIR=/var/lib/csv/blobs. Defaults to ./blobs.
+func GetBlobDir() string {
+	if dir := os.Getenv("BLOB_DIR"); dir != "" {
+		return dir
+	}
+	return defaultBlobDir
+}
+
+// GetBlobRetention returns how long an archived file is kept after it was last uploaded,
+// e.g. BLOB_RETENTION=2160h. 0 keeps files forever. Missing or invalid values fall back
+// to 30 days.
+func GetBlobRetention() time.Duration {
+	retention, err := time.ParseDuration(os.Getenv("BLOB_RETENTION"))
+	if err != nil || retention < 0 {
+		return defaultBlobRetention
+	}
+	return retention
+}
+
+// GetWatchInterval returns how often the drop folder is scanned, e.g. WATCH_INTERVAL=30s.
+// Missing or invalid values fall back to 10 seconds.
+func GetWatchInterval() time.Duration {
+	interval, err := time.ParseDuration(os.Getenv("WATCH_INTERVAL"))
+	if err != nil || interval <= 0 {
+		return defaultWatchInterval
+	}
+	return interval
+}
+
+// GetUploadSessionTTL returns how long a chunked upload may stay idle before it expires
+// and its data is removed, e.g. UPLOAD_SESSION_TTL=6h. Missing or invalid values fall
+// back to 24 hours.
+func GetUploadSessionTTL() time.Duration {
+	ttl, err := time.ParseDuration(os.Getenv("UPLOAD_SESSION_TTL"))
+	if err != nil || ttl <= 0 {
+		return defaultUploadSessionTTL
+	}
+	return ttl
+}
+
+// GetIngestWorkers returns the number of workers shared by all imports, e.g.
+// INGEST_WORKERS=8. Keep it below the database connection pool size.
+func GetIngestWorkers() int {
+	return positiveInt("INGEST_WORKERS", defaultIngestWorkers)
+}
+
+// GetBatchSize returns the number of rows written per INSERT batch, e.g. INGEST_BATCH_SIZE=500.
+func GetBatchSize() int {
+	return positiveInt("INGEST_BATCH_SIZE", defaultBatchSize)
+}
+
+// GetCopyBatchSize returns the number of rows written per COPY batch, e.g.
+// INGEST_COPY_BATCH_SIZE=10000.
+func GetCopyBatchSize() int {
+	return positiveInt("INGEST_COPY_BATCH_SIZE", defaultCopyBatchSize)
+}
+
+// GetReviewerTokens returns the API tokens of the people who may approve or reject staged
+// imports, mapped to their names, e.g. REVIEWER_TOKENS=alice:s3cret,bob:t0ken. Entries
+// without a name or token are ignored.
+func GetReviewerTokens() map[string]string {
+	reviewers := make(map[string]string)
+	for _, entry := range strings.Split(os.Getenv("REVIEWER_TOKENS"), ",") {
+		name, token, ok := strings.Cut(strings.TrimSpace(entry), ":")
+		if ok && name != "" && token != "" {
+			reviewers[token] = name
+		}
+	}
+	return reviewers
+}
+
+// positiveInt reads a positive integer variable. Missing or invalid values fall back to
+// defaultValue.
+func positiveInt(name string, defaultValue int) int {
+	value, err := strconv.Atoi(os.Getenv(name))
+	if err != nil || value <= 0 {
+		return defaultValue
+	}
+	return value
+}
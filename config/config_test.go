@@ -3,6 +3,7 @@ package config
 import (
 	"os"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
 )
@@ -50,3 +51,122 @@ func TestGetDBConnectionString(t *testing.T) {
 		os.Unsetenv("DB_CONNECTION_STRING")
 	})
 }
+
+func TestGetMappingProfilesFile(t *testing.T) {
+	os.Setenv("MAPPING_PROFILES_FILE", "/etc/csv/profiles.json")
+	defer os.Unsetenv("MAPPING_PROFILES_FILE")
+
+	assert.Equal(t, "/etc/csv/profiles.json", GetMappingProfilesFile())
+}
+
+func TestGetTransformRulesFile(t *testing.T) {
+	os.Setenv("TRANSFORM_RULES_FILE", "/etc/csv/transforms.json")
+	defer os.Unsetenv("TRANSFORM_RULES_FILE")
+
+	assert.Equal(t, "/etc/csv/transforms.json", GetTransformRulesFile())
+}
+
+func TestGetWatchDir(t *testing.T) {
+	os.Setenv("WATCH_DIR", "/var/spool/csv")
+	defer os.Unsetenv("WATCH_DIR")
+
+	assert.Equal(t, "/var/spool/csv", GetWatchDir())
+}
+
+func TestGetUploadDir(t *testing.T) {
+	os.Setenv("UPLOAD_DIR", "/var/lib/csv/uploads")
+	defer os.Unsetenv("UPLOAD_DIR")
+
+	assert.Equal(t, "/var/lib/csv/uploads", GetUploadDir())
+}
+
+func TestGetWatchInterval(t *testing.T) {
+	defer os.Unsetenv("WATCH_INTERVAL")
+
+	os.Setenv("WATCH_INTERVAL", "30s")
+	assert.Equal(t, 30*time.Second, GetWatchInterval())
+
+	os.Setenv("WATCH_INTERVAL", "soon")
+	assert.Equal(t, 10*time.Second, GetWatchInterval())
+
+	os.Unsetenv("WATCH_INTERVAL")
+	assert.Equal(t, 10*time.Second, GetWatchInterval())
+}
+
+func TestGetUploadSessionTTL(t *testing.T) {
+	defer os.Unsetenv("UPLOAD_SESSION_TTL")
+
+	os.Setenv("UPLOAD_SESSION_TTL", "6h")
+	assert.Equal(t, 6*time.Hour, GetUploadSessionTTL())
+
+	os.Setenv("UPLOAD_SESSION_TTL", "-1h")
+	assert.Equal(t, 24*time.Hour, GetUploadSessionTTL())
+
+	os.Unsetenv("UPLOAD_SESSION_TTL")
+	assert.Equal(t, 24*time.Hour, GetUploadSessionTTL())
+}
+
+func TestGetIngestSettings(t *testing.T) {
+	defer os.Unsetenv("INGEST_WORKERS")
+	defer os.Unsetenv("INGEST_BATCH_SIZE")
+	defer os.Unsetenv("INGEST_COPY_BATCH_SIZE")
+
+	assert.Equal(t, 10, GetIngestWorkers())
+	assert.Equal(t, 100, GetBatchSize())
+	assert.Equal(t, 5000, GetCopyBatchSize())
+
+	os.Setenv("INGEST_WORKERS", "4")
+	os.Setenv("INGEST_BATCH_SIZE", "500")
+	os.Setenv("INGEST_COPY_BATCH_SIZE", "20000")
+	assert.Equal(t, 4, GetIngestWorkers())
+	assert.Equal(t, 500, GetBatchSize())
+	assert.Equal(t, 20000, GetCopyBatchSize())
+
+	os.Setenv("INGEST_WORKERS", "0")
+	os.Setenv("INGEST_BATCH_SIZE", "many")
+	assert.Equal(t, 10, GetIngestWorkers())
+	assert.Equal(t, 100, GetBatchSize())
+}
+
+func TestGetBlobSettings(t *testing.T) {
+	defer os.Unsetenv("BLOB_DIR")
+	defer os.Unsetenv("BLOB_RETENTION")
+
+	assert.Equal(t, "blobs", GetBlobDir())
+	assert.Equal(t, 30*24*time.Hour, GetBlobRetention())
+
+	os.Setenv("BLOB_DIR", "/var/lib/csv/blobs")
+	os.Setenv("BLOB_RETENTION", "2160h")
+	assert.Equal(t, "/var/lib/csv/blobs", GetBlobDir())
+	assert.Equal(t, 90*24*time.Hour, GetBlobRetention())
+
+	os.Setenv("BLOB_RETENTION", "0")
+	assert.Equal(t, time.Duration(0), GetBlobRetention())
+
+	os.Setenv("BLOB_RETENTION", "forever")
+	assert.Equal(t, 30*24*time.Hour, GetBlobRetention())
+}
+
+func TestGetResumeRetention(t *testing.T) {
+	defer os.Unsetenv("RESUME_RETENTION")
+
+	assert.Equal(t, 7*24*time.Hour, GetResumeRetention())
+
+	os.Setenv("RESUME_RETENTION", "72h")
+	assert.Equal(t, 72*time.Hour, GetResumeRetention())
+
+	os.Setenv("RESUME_RETENTION", "0")
+	assert.Equal(t, time.Duration(0), GetResumeRetention())
+
+	os.Setenv("RESUME_RETENTION", "-1h")
+	assert.Equal(t, 7*24*time.Hour, GetResumeRetention())
+}
+
+func TestGetReviewerTokens(t *testing.T) {
+	defer os.Unsetenv("REVIEWER_TOKENS")
+
+	assert.Empty(t, GetReviewerTokens())
+
+	os.Setenv("REVIEWER_TOKENS", "alice:s3cret, bob:t0ken,carol,:orphan")
+	assert.Equal(t, map[string]string{"s3cret": "alice", "t0ken": "bob"}, GetReviewerTokens())
+}
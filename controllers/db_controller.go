@@ -19,6 +19,14 @@ func (c *Controller) UploadCSV(ctx *gin.Context) {
 	c.Service.UploadCSV(ctx)
 }
 
+func (c *Controller) ValidateCSV(ctx *gin.Context) {
+	c.Service.ValidateCSV(ctx)
+}
+
+func (c *Controller) UploadJSON(ctx *gin.Context) {
+	c.Service.UploadJSON(ctx)
+}
+
 func (c *Controller) ListRecords(ctx *gin.Context) {
 	c.Service.ListAllEntries(ctx)
 }
@@ -39,6 +47,102 @@ func (c *Controller) DeleteRecord(ctx *gin.Context) {
 	c.Service.DeleteRecord(ctx)
 }
 
+func (c *Controller) GetJob(ctx *gin.Context) {
+	c.Service.GetJob(ctx)
+}
+
+func (c *Controller) ListJobs(ctx *gin.Context) {
+	c.Service.ListJobs(ctx)
+}
+
+func (c *Controller) GetJobErrors(ctx *gin.Context) {
+	c.Service.GetJobErrors(ctx)
+}
+
+func (c *Controller) ListImports(ctx *gin.Context) {
+	c.Service.ListImports(ctx)
+}
+
+func (c *Controller) GetImport(ctx *gin.Context) {
+	c.Service.GetImport(ctx)
+}
+
+func (c *Controller) RevertImport(ctx *gin.Context) {
+	c.Service.RevertImport(ctx)
+}
+
+func (c *Controller) ListQuarantine(ctx *gin.Context) {
+	c.Service.ListQuarantine(ctx)
+}
+
+func (c *Controller) UpdateQuarantinedRow(ctx *gin.Context) {
+	c.Service.UpdateQuarantinedRow(ctx)
+}
+
+func (c *Controller) ResubmitQuarantinedRow(ctx *gin.Context) {
+	c.Service.ResubmitQuarantinedRow(ctx)
+}
+
+func (c *Controller) ResubmitQuarantine(ctx *gin.Context) {
+	c.Service.ResubmitQuarantine(ctx)
+}
+
+func (c *Controller) CancelJob(ctx *gin.Context) {
+	c.Service.CancelJob(ctx)
+}
+
+func (c *Controller) ResumeImport(ctx *gin.Context) {
+	c.Service.ResumeImport(ctx)
+}
+
+func (c *Controller) SyncCSV(ctx *gin.Context) {
+	c.Service.SyncCSV(ctx)
+}
+
+func (c *Controller) DiffImport(ctx *gin.Context) {
+	c.Service.DiffImport(ctx)
+}
+
+func (c *Controller) ApproveImport(ctx *gin.Context) {
+	c.Service.ApproveImport(ctx)
+}
+
+func (c *Controller) RejectImport(ctx *gin.Context) {
+	c.Service.RejectImport(ctx)
+}
+
+func (c *Controller) ReimportImport(ctx *gin.Context) {
+	c.Service.ReimportImport(ctx)
+}
+
+func (c *Controller) GetBlob(ctx *gin.Context) {
+	c.Service.GetBlob(ctx)
+}
+
+func (c *Controller) ListAttributes(ctx *gin.Context) {
+	c.Service.ListAttributes(ctx)
+}
+
+func (c *Controller) CreateUpload(ctx *gin.Context) {
+	c.Service.CreateUpload(ctx)
+}
+
+func (c *Controller) GetUpload(ctx *gin.Context) {
+	c.Service.GetUpload(ctx)
+}
+
+func (c *Controller) AppendUpload(ctx *gin.Context) {
+	c.Service.AppendUpload(ctx)
+}
+
+func (c *Controller) CompleteUpload(ctx *gin.Context) {
+	c.Service.CompleteUpload(ctx)
+}
+
+func (c *Controller) AbortUpload(ctx *gin.Context) {
+	c.Service.AbortUpload(ctx)
+}
+
 func (c *Controller) GetLogs(ctx *gin.Context) {
 	// c.Service.GetLogs(ctx)
 	services.GetLogs(ctx)
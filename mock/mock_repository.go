@@ -7,7 +7,9 @@ package mock
 import (
 	context "context"
 	models "csv-microservice/models"
+	repository "csv-microservice/repositories"
 	reflect "reflect"
+	time "time"
 
 	gomock "github.com/golang/mock/gomock"
 )
@@ -49,6 +51,21 @@ func (mr *MockRepositoryInterfaceMockRecorder) AddRecord(record interface{}) *go
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "AddRecord", reflect.TypeOf((*MockRepositoryInterface)(nil).AddRecord), record)
 }
 
+// ApproveStagedImport mocks base method.
+func (m *MockRepositoryInterface) ApproveStagedImport(id, mode, missing, reviewer string) (models.StagedSummary, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ApproveStagedImport", id, mode, missing, reviewer)
+	ret0, _ := ret[0].(models.StagedSummary)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// ApproveStagedImport indicates an expected call of ApproveStagedImport.
+func (mr *MockRepositoryInterfaceMockRecorder) ApproveStagedImport(id, mode, missing, reviewer interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ApproveStagedImport", reflect.TypeOf((*MockRepositoryInterface)(nil).ApproveStagedImport), id, mode, missing, reviewer)
+}
+
 // BulkInsert mocks base method.
 func (m *MockRepositoryInterface) BulkInsert(records []models.User) error {
 	m.ctrl.T.Helper()
@@ -63,6 +80,50 @@ func (mr *MockRepositoryInterfaceMockRecorder) BulkInsert(records interface{}) *
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "BulkInsert", reflect.TypeOf((*MockRepositoryInterface)(nil).BulkInsert), records)
 }
 
+// ClaimQuarantinedRows mocks base method.
+func (m *MockRepositoryInterface) ClaimQuarantinedRows(ids []uint) ([]uint, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ClaimQuarantinedRows", ids)
+	ret0, _ := ret[0].([]uint)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// ClaimQuarantinedRows indicates an expected call of ClaimQuarantinedRows.
+func (mr *MockRepositoryInterfaceMockRecorder) ClaimQuarantinedRows(ids interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ClaimQuarantinedRows", reflect.TypeOf((*MockRepositoryInterface)(nil).ClaimQuarantinedRows), ids)
+}
+
+// CopyInsert mocks base method.
+func (m *MockRepositoryInterface) CopyInsert(ctx context.Context, records []models.User) (int64, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "CopyInsert", ctx, records)
+	ret0, _ := ret[0].(int64)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// CopyInsert indicates an expected call of CopyInsert.
+func (mr *MockRepositoryInterfaceMockRecorder) CopyInsert(ctx, records interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CopyInsert", reflect.TypeOf((*MockRepositoryInterface)(nil).CopyInsert), ctx, records)
+}
+
+// DeleteIdempotencyKey mocks base method.
+func (m *MockRepositoryInterface) DeleteIdempotencyKey(key string) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "DeleteIdempotencyKey", key)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// DeleteIdempotencyKey indicates an expected call of DeleteIdempotencyKey.
+func (mr *MockRepositoryInterfaceMockRecorder) DeleteIdempotencyKey(key interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "DeleteIdempotencyKey", reflect.TypeOf((*MockRepositoryInterface)(nil).DeleteIdempotencyKey), key)
+}
+
 // DeleteRecord mocks base method.
 func (m *MockRepositoryInterface) DeleteRecord(ctx context.Context, id int) error {
 	m.ctrl.T.Helper()
@@ -77,6 +138,95 @@ func (mr *MockRepositoryInterfaceMockRecorder) DeleteRecord(ctx, id interface{})
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "DeleteRecord", reflect.TypeOf((*MockRepositoryInterface)(nil).DeleteRecord), ctx, id)
 }
 
+// DiffStagedImport mocks base method.
+func (m *MockRepositoryInterface) DiffStagedImport(id, mode, missing string) (models.StagedDiff, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "DiffStagedImport", id, mode, missing)
+	ret0, _ := ret[0].(models.StagedDiff)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// DiffStagedImport indicates an expected call of DiffStagedImport.
+func (mr *MockRepositoryInterfaceMockRecorder) DiffStagedImport(id, mode, missing interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "DiffStagedImport", reflect.TypeOf((*MockRepositoryInterface)(nil).DiffStagedImport), id, mode, missing)
+}
+
+// DiscardStagedImport mocks base method.
+func (m *MockRepositoryInterface) DiscardStagedImport(id, state string) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "DiscardStagedImport", id, state)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// DiscardStagedImport indicates an expected call of DiscardStagedImport.
+func (mr *MockRepositoryInterfaceMockRecorder) DiscardStagedImport(id, state interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "DiscardStagedImport", reflect.TypeOf((*MockRepositoryInterface)(nil).DiscardStagedImport), id, state)
+}
+
+// FindExistingUsers mocks base method.
+func (m *MockRepositoryInterface) FindExistingUsers(records []models.UserRecord, mode string) ([]bool, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "FindExistingUsers", records, mode)
+	ret0, _ := ret[0].([]bool)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// FindExistingUsers indicates an expected call of FindExistingUsers.
+func (mr *MockRepositoryInterfaceMockRecorder) FindExistingUsers(records, mode interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "FindExistingUsers", reflect.TypeOf((*MockRepositoryInterface)(nil).FindExistingUsers), records, mode)
+}
+
+// FindImportBySHA256 mocks base method.
+func (m *MockRepositoryInterface) FindImportBySHA256(sum string) (models.Import, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "FindImportBySHA256", sum)
+	ret0, _ := ret[0].(models.Import)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// FindImportBySHA256 indicates an expected call of FindImportBySHA256.
+func (mr *MockRepositoryInterfaceMockRecorder) FindImportBySHA256(sum interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "FindImportBySHA256", reflect.TypeOf((*MockRepositoryInterface)(nil).FindImportBySHA256), sum)
+}
+
+// GetImport mocks base method.
+func (m *MockRepositoryInterface) GetImport(id string) (models.Import, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetImport", id)
+	ret0, _ := ret[0].(models.Import)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetImport indicates an expected call of GetImport.
+func (mr *MockRepositoryInterfaceMockRecorder) GetImport(id interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetImport", reflect.TypeOf((*MockRepositoryInterface)(nil).GetImport), id)
+}
+
+// GetQuarantinedRows mocks base method.
+func (m *MockRepositoryInterface) GetQuarantinedRows(ids []uint) ([]models.QuarantinedRow, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetQuarantinedRows", ids)
+	ret0, _ := ret[0].([]models.QuarantinedRow)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetQuarantinedRows indicates an expected call of GetQuarantinedRows.
+func (mr *MockRepositoryInterfaceMockRecorder) GetQuarantinedRows(ids interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetQuarantinedRows", reflect.TypeOf((*MockRepositoryInterface)(nil).GetQuarantinedRows), ids)
+}
+
 // InsertRecord mocks base method.
 func (m *MockRepositoryInterface) InsertRecord(ctx context.Context, record interface{}) error {
 	m.ctrl.T.Helper()
@@ -91,6 +241,97 @@ func (mr *MockRepositoryInterfaceMockRecorder) InsertRecord(ctx, record interfac
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "InsertRecord", reflect.TypeOf((*MockRepositoryInterface)(nil).InsertRecord), ctx, record)
 }
 
+// ListAttributes mocks base method.
+func (m *MockRepositoryInterface) ListAttributes() ([]models.Attribute, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ListAttributes")
+	ret0, _ := ret[0].([]models.Attribute)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// ListAttributes indicates an expected call of ListAttributes.
+func (mr *MockRepositoryInterfaceMockRecorder) ListAttributes() *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ListAttributes", reflect.TypeOf((*MockRepositoryInterface)(nil).ListAttributes))
+}
+
+// ListImportChunks mocks base method.
+func (m *MockRepositoryInterface) ListImportChunks(importID string) ([]models.ImportChunk, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ListImportChunks", importID)
+	ret0, _ := ret[0].([]models.ImportChunk)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// ListImportChunks indicates an expected call of ListImportChunks.
+func (mr *MockRepositoryInterfaceMockRecorder) ListImportChunks(importID interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ListImportChunks", reflect.TypeOf((*MockRepositoryInterface)(nil).ListImportChunks), importID)
+}
+
+// ListImports mocks base method.
+func (m *MockRepositoryInterface) ListImports(offset, limit int) ([]models.Import, int64, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ListImports", offset, limit)
+	ret0, _ := ret[0].([]models.Import)
+	ret1, _ := ret[1].(int64)
+	ret2, _ := ret[2].(error)
+	return ret0, ret1, ret2
+}
+
+// ListImports indicates an expected call of ListImports.
+func (mr *MockRepositoryInterfaceMockRecorder) ListImports(offset, limit interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ListImports", reflect.TypeOf((*MockRepositoryInterface)(nil).ListImports), offset, limit)
+}
+
+// ListQuarantine mocks base method.
+func (m *MockRepositoryInterface) ListQuarantine(importID, status string, offset, limit int) ([]models.QuarantinedRow, int64, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ListQuarantine", importID, status, offset, limit)
+	ret0, _ := ret[0].([]models.QuarantinedRow)
+	ret1, _ := ret[1].(int64)
+	ret2, _ := ret[2].(error)
+	return ret0, ret1, ret2
+}
+
+// ListQuarantine indicates an expected call of ListQuarantine.
+func (mr *MockRepositoryInterfaceMockRecorder) ListQuarantine(importID, status, offset, limit interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ListQuarantine", reflect.TypeOf((*MockRepositoryInterface)(nil).ListQuarantine), importID, status, offset, limit)
+}
+
+// ListResumableImports mocks base method.
+func (m *MockRepositoryInterface) ListResumableImports(createdBefore time.Time) ([]models.Import, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ListResumableImports", createdBefore)
+	ret0, _ := ret[0].([]models.Import)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// ListResumableImports indicates an expected call of ListResumableImports.
+func (mr *MockRepositoryInterfaceMockRecorder) ListResumableImports(createdBefore interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ListResumableImports", reflect.TypeOf((*MockRepositoryInterface)(nil).ListResumableImports), createdBefore)
+}
+
+// QuarantineRows mocks base method.
+func (m *MockRepositoryInterface) QuarantineRows(rows []models.QuarantinedRow) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "QuarantineRows", rows)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// QuarantineRows indicates an expected call of QuarantineRows.
+func (mr *MockRepositoryInterfaceMockRecorder) QuarantineRows(rows interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "QuarantineRows", reflect.TypeOf((*MockRepositoryInterface)(nil).QuarantineRows), rows)
+}
+
 // QueryRecords mocks base method.
 func (m *MockRepositoryInterface) QueryRecords(ctx context.Context, queryParams map[string]interface{}, offset, limit int) ([]models.User, error) {
 	m.ctrl.T.Helper()
@@ -105,3 +346,174 @@ func (mr *MockRepositoryInterfaceMockRecorder) QueryRecords(ctx, queryParams, of
 	mr.mock.ctrl.T.Helper()
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "QueryRecords", reflect.TypeOf((*MockRepositoryInterface)(nil).QueryRecords), ctx, queryParams, offset, limit)
 }
+
+// RegisterAttributes mocks base method.
+func (m *MockRepositoryInterface) RegisterAttributes(attributes []models.Attribute) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "RegisterAttributes", attributes)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// RegisterAttributes indicates an expected call of RegisterAttributes.
+func (mr *MockRepositoryInterfaceMockRecorder) RegisterAttributes(attributes interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "RegisterAttributes", reflect.TypeOf((*MockRepositoryInterface)(nil).RegisterAttributes), attributes)
+}
+
+// RejectStagedImport mocks base method.
+func (m *MockRepositoryInterface) RejectStagedImport(id, reviewer string) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "RejectStagedImport", id, reviewer)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// RejectStagedImport indicates an expected call of RejectStagedImport.
+func (mr *MockRepositoryInterfaceMockRecorder) RejectStagedImport(id, reviewer interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "RejectStagedImport", reflect.TypeOf((*MockRepositoryInterface)(nil).RejectStagedImport), id, reviewer)
+}
+
+// ReleaseQuarantinedRows mocks base method.
+func (m *MockRepositoryInterface) ReleaseQuarantinedRows(claimedBefore time.Time) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ReleaseQuarantinedRows", claimedBefore)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// ReleaseQuarantinedRows indicates an expected call of ReleaseQuarantinedRows.
+func (mr *MockRepositoryInterfaceMockRecorder) ReleaseQuarantinedRows(claimedBefore interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ReleaseQuarantinedRows", reflect.TypeOf((*MockRepositoryInterface)(nil).ReleaseQuarantinedRows), claimedBefore)
+}
+
+// ReserveIdempotencyKey mocks base method.
+func (m *MockRepositoryInterface) ReserveIdempotencyKey(key *models.IdempotencyKey, expiredBefore, abandonedBefore time.Time) (bool, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ReserveIdempotencyKey", key, expiredBefore, abandonedBefore)
+	ret0, _ := ret[0].(bool)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// ReserveIdempotencyKey indicates an expected call of ReserveIdempotencyKey.
+func (mr *MockRepositoryInterfaceMockRecorder) ReserveIdempotencyKey(key, expiredBefore, abandonedBefore interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ReserveIdempotencyKey", reflect.TypeOf((*MockRepositoryInterface)(nil).ReserveIdempotencyKey), key, expiredBefore, abandonedBefore)
+}
+
+// RevertImport mocks base method.
+func (m *MockRepositoryInterface) RevertImport(id string, dryRun bool) (models.RevertResult, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "RevertImport", id, dryRun)
+	ret0, _ := ret[0].(models.RevertResult)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// RevertImport indicates an expected call of RevertImport.
+func (mr *MockRepositoryInterfaceMockRecorder) RevertImport(id, dryRun interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "RevertImport", reflect.TypeOf((*MockRepositoryInterface)(nil).RevertImport), id, dryRun)
+}
+
+// SaveIdempotencyKey mocks base method.
+func (m *MockRepositoryInterface) SaveIdempotencyKey(key *models.IdempotencyKey) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "SaveIdempotencyKey", key)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// SaveIdempotencyKey indicates an expected call of SaveIdempotencyKey.
+func (mr *MockRepositoryInterfaceMockRecorder) SaveIdempotencyKey(key interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SaveIdempotencyKey", reflect.TypeOf((*MockRepositoryInterface)(nil).SaveIdempotencyKey), key)
+}
+
+// SaveImport mocks base method.
+func (m *MockRepositoryInterface) SaveImport(record *models.Import) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "SaveImport", record)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// SaveImport indicates an expected call of SaveImport.
+func (mr *MockRepositoryInterfaceMockRecorder) SaveImport(record interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SaveImport", reflect.TypeOf((*MockRepositoryInterface)(nil).SaveImport), record)
+}
+
+// SaveQuarantinedRow mocks base method.
+func (m *MockRepositoryInterface) SaveQuarantinedRow(row *models.QuarantinedRow) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "SaveQuarantinedRow", row)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// SaveQuarantinedRow indicates an expected call of SaveQuarantinedRow.
+func (mr *MockRepositoryInterfaceMockRecorder) SaveQuarantinedRow(row interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SaveQuarantinedRow", reflect.TypeOf((*MockRepositoryInterface)(nil).SaveQuarantinedRow), row)
+}
+
+// StageRows mocks base method.
+func (m *MockRepositoryInterface) StageRows(rows []models.StagedRow, mode string) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "StageRows", rows, mode)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// StageRows indicates an expected call of StageRows.
+func (mr *MockRepositoryInterfaceMockRecorder) StageRows(rows, mode interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "StageRows", reflect.TypeOf((*MockRepositoryInterface)(nil).StageRows), rows, mode)
+}
+
+// Transaction mocks base method.
+func (m *MockRepositoryInterface) Transaction(fn func(repository.RepositoryInterface) error) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Transaction", fn)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// Transaction indicates an expected call of Transaction.
+func (mr *MockRepositoryInterfaceMockRecorder) Transaction(fn interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Transaction", reflect.TypeOf((*MockRepositoryInterface)(nil).Transaction), fn)
+}
+
+// UpsertBatch mocks base method.
+func (m *MockRepositoryInterface) UpsertBatch(records []models.UserRecord, mode string, merge bool) (models.WriteResult, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "UpsertBatch", records, mode, merge)
+	ret0, _ := ret[0].(models.WriteResult)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// UpsertBatch indicates an expected call of UpsertBatch.
+func (mr *MockRepositoryInterfaceMockRecorder) UpsertBatch(records, mode, merge interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "UpsertBatch", reflect.TypeOf((*MockRepositoryInterface)(nil).UpsertBatch), records, mode, merge)
+}
+
+// WriteChunk mocks base method.
+func (m *MockRepositoryInterface) WriteChunk(chunk *models.ImportChunk, write func(repository.RepositoryInterface) error) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "WriteChunk", chunk, write)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// WriteChunk indicates an expected call of WriteChunk.
+func (mr *MockRepositoryInterfaceMockRecorder) WriteChunk(chunk, write interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "WriteChunk", reflect.TypeOf((*MockRepositoryInterface)(nil).WriteChunk), chunk, write)
+}
@@ -34,6 +34,18 @@ func (m *MockServiceInterface) EXPECT() *MockServiceInterfaceMockRecorder {
 	return m.recorder
 }
 
+// AbortUpload mocks base method.
+func (m *MockServiceInterface) AbortUpload(ctx *gin.Context) {
+	m.ctrl.T.Helper()
+	m.ctrl.Call(m, "AbortUpload", ctx)
+}
+
+// AbortUpload indicates an expected call of AbortUpload.
+func (mr *MockServiceInterfaceMockRecorder) AbortUpload(ctx interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "AbortUpload", reflect.TypeOf((*MockServiceInterface)(nil).AbortUpload), ctx)
+}
+
 // AddRecord mocks base method.
 func (m *MockServiceInterface) AddRecord(ctx *gin.Context) {
 	m.ctrl.T.Helper()
@@ -46,6 +58,66 @@ func (mr *MockServiceInterfaceMockRecorder) AddRecord(ctx interface{}) *gomock.C
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "AddRecord", reflect.TypeOf((*MockServiceInterface)(nil).AddRecord), ctx)
 }
 
+// AppendUpload mocks base method.
+func (m *MockServiceInterface) AppendUpload(ctx *gin.Context) {
+	m.ctrl.T.Helper()
+	m.ctrl.Call(m, "AppendUpload", ctx)
+}
+
+// AppendUpload indicates an expected call of AppendUpload.
+func (mr *MockServiceInterfaceMockRecorder) AppendUpload(ctx interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "AppendUpload", reflect.TypeOf((*MockServiceInterface)(nil).AppendUpload), ctx)
+}
+
+// ApproveImport mocks base method.
+func (m *MockServiceInterface) ApproveImport(ctx *gin.Context) {
+	m.ctrl.T.Helper()
+	m.ctrl.Call(m, "ApproveImport", ctx)
+}
+
+// ApproveImport indicates an expected call of ApproveImport.
+func (mr *MockServiceInterfaceMockRecorder) ApproveImport(ctx interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ApproveImport", reflect.TypeOf((*MockServiceInterface)(nil).ApproveImport), ctx)
+}
+
+// CancelJob mocks base method.
+func (m *MockServiceInterface) CancelJob(ctx *gin.Context) {
+	m.ctrl.T.Helper()
+	m.ctrl.Call(m, "CancelJob", ctx)
+}
+
+// CancelJob indicates an expected call of CancelJob.
+func (mr *MockServiceInterfaceMockRecorder) CancelJob(ctx interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CancelJob", reflect.TypeOf((*MockServiceInterface)(nil).CancelJob), ctx)
+}
+
+// CompleteUpload mocks base method.
+func (m *MockServiceInterface) CompleteUpload(ctx *gin.Context) {
+	m.ctrl.T.Helper()
+	m.ctrl.Call(m, "CompleteUpload", ctx)
+}
+
+// CompleteUpload indicates an expected call of CompleteUpload.
+func (mr *MockServiceInterfaceMockRecorder) CompleteUpload(ctx interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CompleteUpload", reflect.TypeOf((*MockServiceInterface)(nil).CompleteUpload), ctx)
+}
+
+// CreateUpload mocks base method.
+func (m *MockServiceInterface) CreateUpload(ctx *gin.Context) {
+	m.ctrl.T.Helper()
+	m.ctrl.Call(m, "CreateUpload", ctx)
+}
+
+// CreateUpload indicates an expected call of CreateUpload.
+func (mr *MockServiceInterfaceMockRecorder) CreateUpload(ctx interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CreateUpload", reflect.TypeOf((*MockServiceInterface)(nil).CreateUpload), ctx)
+}
+
 // DeleteRecord mocks base method.
 func (m *MockServiceInterface) DeleteRecord(ctx *gin.Context) {
 	m.ctrl.T.Helper()
@@ -58,6 +130,78 @@ func (mr *MockServiceInterfaceMockRecorder) DeleteRecord(ctx interface{}) *gomoc
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "DeleteRecord", reflect.TypeOf((*MockServiceInterface)(nil).DeleteRecord), ctx)
 }
 
+// DiffImport mocks base method.
+func (m *MockServiceInterface) DiffImport(ctx *gin.Context) {
+	m.ctrl.T.Helper()
+	m.ctrl.Call(m, "DiffImport", ctx)
+}
+
+// DiffImport indicates an expected call of DiffImport.
+func (mr *MockServiceInterfaceMockRecorder) DiffImport(ctx interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "DiffImport", reflect.TypeOf((*MockServiceInterface)(nil).DiffImport), ctx)
+}
+
+// GetBlob mocks base method.
+func (m *MockServiceInterface) GetBlob(ctx *gin.Context) {
+	m.ctrl.T.Helper()
+	m.ctrl.Call(m, "GetBlob", ctx)
+}
+
+// GetBlob indicates an expected call of GetBlob.
+func (mr *MockServiceInterfaceMockRecorder) GetBlob(ctx interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetBlob", reflect.TypeOf((*MockServiceInterface)(nil).GetBlob), ctx)
+}
+
+// GetImport mocks base method.
+func (m *MockServiceInterface) GetImport(ctx *gin.Context) {
+	m.ctrl.T.Helper()
+	m.ctrl.Call(m, "GetImport", ctx)
+}
+
+// GetImport indicates an expected call of GetImport.
+func (mr *MockServiceInterfaceMockRecorder) GetImport(ctx interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetImport", reflect.TypeOf((*MockServiceInterface)(nil).GetImport), ctx)
+}
+
+// GetJob mocks base method.
+func (m *MockServiceInterface) GetJob(ctx *gin.Context) {
+	m.ctrl.T.Helper()
+	m.ctrl.Call(m, "GetJob", ctx)
+}
+
+// GetJob indicates an expected call of GetJob.
+func (mr *MockServiceInterfaceMockRecorder) GetJob(ctx interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetJob", reflect.TypeOf((*MockServiceInterface)(nil).GetJob), ctx)
+}
+
+// GetJobErrors mocks base method.
+func (m *MockServiceInterface) GetJobErrors(ctx *gin.Context) {
+	m.ctrl.T.Helper()
+	m.ctrl.Call(m, "GetJobErrors", ctx)
+}
+
+// GetJobErrors indicates an expected call of GetJobErrors.
+func (mr *MockServiceInterfaceMockRecorder) GetJobErrors(ctx interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetJobErrors", reflect.TypeOf((*MockServiceInterface)(nil).GetJobErrors), ctx)
+}
+
+// GetUpload mocks base method.
+func (m *MockServiceInterface) GetUpload(ctx *gin.Context) {
+	m.ctrl.T.Helper()
+	m.ctrl.Call(m, "GetUpload", ctx)
+}
+
+// GetUpload indicates an expected call of GetUpload.
+func (mr *MockServiceInterfaceMockRecorder) GetUpload(ctx interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetUpload", reflect.TypeOf((*MockServiceInterface)(nil).GetUpload), ctx)
+}
+
 // ListAllEntries mocks base method.
 func (m *MockServiceInterface) ListAllEntries(ctx *gin.Context) {
 	m.ctrl.T.Helper()
@@ -70,6 +214,18 @@ func (mr *MockServiceInterfaceMockRecorder) ListAllEntries(ctx interface{}) *gom
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ListAllEntries", reflect.TypeOf((*MockServiceInterface)(nil).ListAllEntries), ctx)
 }
 
+// ListAttributes mocks base method.
+func (m *MockServiceInterface) ListAttributes(ctx *gin.Context) {
+	m.ctrl.T.Helper()
+	m.ctrl.Call(m, "ListAttributes", ctx)
+}
+
+// ListAttributes indicates an expected call of ListAttributes.
+func (mr *MockServiceInterfaceMockRecorder) ListAttributes(ctx interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ListAttributes", reflect.TypeOf((*MockServiceInterface)(nil).ListAttributes), ctx)
+}
+
 // ListEntriesByPages mocks base method.
 func (m *MockServiceInterface) ListEntriesByPages(ctx *gin.Context) {
 	m.ctrl.T.Helper()
@@ -82,6 +238,42 @@ func (mr *MockServiceInterfaceMockRecorder) ListEntriesByPages(ctx interface{})
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ListEntriesByPages", reflect.TypeOf((*MockServiceInterface)(nil).ListEntriesByPages), ctx)
 }
 
+// ListImports mocks base method.
+func (m *MockServiceInterface) ListImports(ctx *gin.Context) {
+	m.ctrl.T.Helper()
+	m.ctrl.Call(m, "ListImports", ctx)
+}
+
+// ListImports indicates an expected call of ListImports.
+func (mr *MockServiceInterfaceMockRecorder) ListImports(ctx interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ListImports", reflect.TypeOf((*MockServiceInterface)(nil).ListImports), ctx)
+}
+
+// ListJobs mocks base method.
+func (m *MockServiceInterface) ListJobs(ctx *gin.Context) {
+	m.ctrl.T.Helper()
+	m.ctrl.Call(m, "ListJobs", ctx)
+}
+
+// ListJobs indicates an expected call of ListJobs.
+func (mr *MockServiceInterfaceMockRecorder) ListJobs(ctx interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ListJobs", reflect.TypeOf((*MockServiceInterface)(nil).ListJobs), ctx)
+}
+
+// ListQuarantine mocks base method.
+func (m *MockServiceInterface) ListQuarantine(ctx *gin.Context) {
+	m.ctrl.T.Helper()
+	m.ctrl.Call(m, "ListQuarantine", ctx)
+}
+
+// ListQuarantine indicates an expected call of ListQuarantine.
+func (mr *MockServiceInterfaceMockRecorder) ListQuarantine(ctx interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ListQuarantine", reflect.TypeOf((*MockServiceInterface)(nil).ListQuarantine), ctx)
+}
+
 // QueryUpdates mocks base method.
 func (m *MockServiceInterface) QueryUpdates(ctx *gin.Context) {
 	m.ctrl.T.Helper()
@@ -94,6 +286,102 @@ func (mr *MockServiceInterfaceMockRecorder) QueryUpdates(ctx interface{}) *gomoc
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "QueryUpdates", reflect.TypeOf((*MockServiceInterface)(nil).QueryUpdates), ctx)
 }
 
+// ReimportImport mocks base method.
+func (m *MockServiceInterface) ReimportImport(ctx *gin.Context) {
+	m.ctrl.T.Helper()
+	m.ctrl.Call(m, "ReimportImport", ctx)
+}
+
+// ReimportImport indicates an expected call of ReimportImport.
+func (mr *MockServiceInterfaceMockRecorder) ReimportImport(ctx interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ReimportImport", reflect.TypeOf((*MockServiceInterface)(nil).ReimportImport), ctx)
+}
+
+// RejectImport mocks base method.
+func (m *MockServiceInterface) RejectImport(ctx *gin.Context) {
+	m.ctrl.T.Helper()
+	m.ctrl.Call(m, "RejectImport", ctx)
+}
+
+// RejectImport indicates an expected call of RejectImport.
+func (mr *MockServiceInterfaceMockRecorder) RejectImport(ctx interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "RejectImport", reflect.TypeOf((*MockServiceInterface)(nil).RejectImport), ctx)
+}
+
+// ResubmitQuarantine mocks base method.
+func (m *MockServiceInterface) ResubmitQuarantine(ctx *gin.Context) {
+	m.ctrl.T.Helper()
+	m.ctrl.Call(m, "ResubmitQuarantine", ctx)
+}
+
+// ResubmitQuarantine indicates an expected call of ResubmitQuarantine.
+func (mr *MockServiceInterfaceMockRecorder) ResubmitQuarantine(ctx interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ResubmitQuarantine", reflect.TypeOf((*MockServiceInterface)(nil).ResubmitQuarantine), ctx)
+}
+
+// ResubmitQuarantinedRow mocks base method.
+func (m *MockServiceInterface) ResubmitQuarantinedRow(ctx *gin.Context) {
+	m.ctrl.T.Helper()
+	m.ctrl.Call(m, "ResubmitQuarantinedRow", ctx)
+}
+
+// ResubmitQuarantinedRow indicates an expected call of ResubmitQuarantinedRow.
+func (mr *MockServiceInterfaceMockRecorder) ResubmitQuarantinedRow(ctx interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ResubmitQuarantinedRow", reflect.TypeOf((*MockServiceInterface)(nil).ResubmitQuarantinedRow), ctx)
+}
+
+// ResumeImport mocks base method.
+func (m *MockServiceInterface) ResumeImport(ctx *gin.Context) {
+	m.ctrl.T.Helper()
+	m.ctrl.Call(m, "ResumeImport", ctx)
+}
+
+// ResumeImport indicates an expected call of ResumeImport.
+func (mr *MockServiceInterfaceMockRecorder) ResumeImport(ctx interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ResumeImport", reflect.TypeOf((*MockServiceInterface)(nil).ResumeImport), ctx)
+}
+
+// RevertImport mocks base method.
+func (m *MockServiceInterface) RevertImport(ctx *gin.Context) {
+	m.ctrl.T.Helper()
+	m.ctrl.Call(m, "RevertImport", ctx)
+}
+
+// RevertImport indicates an expected call of RevertImport.
+func (mr *MockServiceInterfaceMockRecorder) RevertImport(ctx interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "RevertImport", reflect.TypeOf((*MockServiceInterface)(nil).RevertImport), ctx)
+}
+
+// SyncCSV mocks base method.
+func (m *MockServiceInterface) SyncCSV(ctx *gin.Context) {
+	m.ctrl.T.Helper()
+	m.ctrl.Call(m, "SyncCSV", ctx)
+}
+
+// SyncCSV indicates an expected call of SyncCSV.
+func (mr *MockServiceInterfaceMockRecorder) SyncCSV(ctx interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SyncCSV", reflect.TypeOf((*MockServiceInterface)(nil).SyncCSV), ctx)
+}
+
+// UpdateQuarantinedRow mocks base method.
+func (m *MockServiceInterface) UpdateQuarantinedRow(ctx *gin.Context) {
+	m.ctrl.T.Helper()
+	m.ctrl.Call(m, "UpdateQuarantinedRow", ctx)
+}
+
+// UpdateQuarantinedRow indicates an expected call of UpdateQuarantinedRow.
+func (mr *MockServiceInterfaceMockRecorder) UpdateQuarantinedRow(ctx interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "UpdateQuarantinedRow", reflect.TypeOf((*MockServiceInterface)(nil).UpdateQuarantinedRow), ctx)
+}
+
 // UploadCSV mocks base method.
 func (m *MockServiceInterface) UploadCSV(ctx *gin.Context) {
 	m.ctrl.T.Helper()
@@ -105,3 +393,27 @@ func (mr *MockServiceInterfaceMockRecorder) UploadCSV(ctx interface{}) *gomock.C
 	mr.mock.ctrl.T.Helper()
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "UploadCSV", reflect.TypeOf((*MockServiceInterface)(nil).UploadCSV), ctx)
 }
+
+// UploadJSON mocks base method.
+func (m *MockServiceInterface) UploadJSON(ctx *gin.Context) {
+	m.ctrl.T.Helper()
+	m.ctrl.Call(m, "UploadJSON", ctx)
+}
+
+// UploadJSON indicates an expected call of UploadJSON.
+func (mr *MockServiceInterfaceMockRecorder) UploadJSON(ctx interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "UploadJSON", reflect.TypeOf((*MockServiceInterface)(nil).UploadJSON), ctx)
+}
+
+// ValidateCSV mocks base method.
+func (m *MockServiceInterface) ValidateCSV(ctx *gin.Context) {
+	m.ctrl.T.Helper()
+	m.ctrl.Call(m, "ValidateCSV", ctx)
+}
+
+// ValidateCSV indicates an expected call of ValidateCSV.
+func (mr *MockServiceInterfaceMockRecorder) ValidateCSV(ctx interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ValidateCSV", reflect.TypeOf((*MockServiceInterface)(nil).ValidateCSV), ctx)
+}
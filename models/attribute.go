@@ -0,0 +1,56 @@
+package models
+
+import (
+	"database/sql/driver"
+	"encoding/json"
+	"fmt"
+	"time"
+)
+
+// Attributes holds the custom attributes of a user, stored in a JSONB column. An empty
+// set is stored as NULL.
+type Attributes map[string]string
+
+// Value implements driver.Valuer.
+func (a Attributes) Value() (driver.Value, error) {
+	if len(a) == 0 {
+		return nil, nil
+	}
+	data, err := json.Marshal(map[string]string(a))
+	if err != nil {
+		return nil, err
+	}
+	return string(data), nil
+}
+
+// Scan implements sql.Scanner.
+func (a *Attributes) Scan(src interface{}) error {
+	var data []byte
+	switch v := src.(type) {
+	case nil:
+		*a = nil
+		return nil
+	case []byte:
+		data = v
+	case string:
+		data = []byte(v)
+	default:
+		return fmt.Errorf("cannot scan %T into Attributes", src)
+	}
+	var values map[string]string
+	if err := json.Unmarshal(data, &values); err != nil {
+		return err
+	}
+	*a = values
+	return nil
+}
+
+// Attribute defines a custom attribute. It is registered by the first import whose file
+// has a column of that name which maps to no User field.
+type Attribute struct {
+	Name      string    `json:"name" gorm:"primaryKey"`      // Normalised header name, the key in User.Attributes
+	Header    string    `json:"header"`                      // Header as it appeared in that file
+	ImportID  string    `json:"import_id"`                   // Import that registered the attribute
+	Users     int64     `json:"users" gorm:"-:migration;->"` // Users with a value, filled by ListAttributes
+	CreatedAt time.Time `json:"created_at"`
+}
@@ -0,0 +1,16 @@
+package models
+
+import "time"
+
+// IdempotencyKey keeps the response to a request sent with an Idempotency-Key header, so
+// a retry of the request gets the same response instead of being carried out again.
+type IdempotencyKey struct {
+	Key         string `gorm:"primaryKey"`
+	Method      string // Method and path of the request, which a retry must repeat
+	Path        string
+	Fingerprint string // Hash of the query string and form or body, which a retry must repeat too
+	Status      int    // Response status; 0 while the first request is still running
+	ContentType string
+	Body        []byte
+	CreatedAt   time.Time
+}
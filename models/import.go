@@ -0,0 +1,104 @@
+package models
+
+import "time"
+
+// Conflict policies for CSV imports.
+const (
+	ModeInsert       = "insert"        // Always insert; conflicts fail the row
+	ModeUpsertID     = "upsert_id"     // Update the row with the same id, insert otherwise
+	ModeUpsertEmail  = "upsert_email"  // Update the row with the same email, insert otherwise
+	ModeSkipExisting = "skip_existing" // Leave rows that already exist (by id, or email without id) untouched
+)
+
+// Transaction modes for CSV imports.
+const (
+	TxBestEffort = "best_effort" // Batches commit independently; failed rows are reported and skipped
+	TxAtomic     = "atomic"      // The whole file commits in one transaction or not at all
+)
+
+// Loaders that write import batches to the database.
+const (
+	LoaderGorm = "gorm" // Batched INSERTs through GORM; supports every mode
+	LoaderCopy = "copy" // Postgres COPY FROM STDIN; insert-only, best-effort
+)
+
+// UserRecord is a User parsed from an import together with the columns that had values.
+type UserRecord struct {
+	User   User
+	Fields []string // Column names whose source cell was non-empty
+}
+
+// WriteResult counts what happened to the records of a write.
+type WriteResult struct {
+	Inserted int `json:"inserted"`
+	Updated  int `json:"updated"`
+	Skipped  int `json:"skipped"`
+}
+
+// ImportReverted is the state of an import whose changes were reverted.
+const ImportReverted = "reverted"
+
+// Import is the audit record of an import job, stored in the imports table. Its ID is the
+// job ID, which is also stored in the import_id column of every user the import inserted.
+type Import struct {
+	ID           string     `json:"id" gorm:"primaryKey"`
+	Filename     string     `json:"filename"`
+	Size         int64      `json:"size"`                              // Size in bytes of the uploaded file
+	SHA256       string     `json:"sha256" gorm:"column:sha256;index"` // Checksum of the uploaded file
+	Uploader     string     `json:"uploader"`                          // Who sent the file, see the X-Uploader header
+	Upload       string     `json:"upload,omitempty"`                  // Name the file was uploaded with, e.g. the archive holding Filename
+	Blob         string     `json:"blob,omitempty"`                    // Archived copy of the file, served by GET /blobs/:sha256
+	State        string     `json:"state"`
+	Mode         string     `json:"mode"`
+	Merge        bool       `json:"merge"`
+	Transaction  string     `json:"transaction"`
+	Loader       string     `json:"loader"`
+	Locale       string     `json:"locale,omitempty"`
+	Missing      string     `json:"missing,omitempty"` // What a staged import does with users missing from the file
+	RowsRead     int        `json:"rows_read"`
+	RowsInserted int        `json:"rows_inserted"`
+	RowsUpdated  int        `json:"rows_updated"`
+	RowsSkipped  int        `json:"rows_skipped"`
+	RowsFailed   int        `json:"rows_failed"`
+	RowsDeleted  int        `json:"rows_deleted"` // Users removed by an approved staged import
+	Checkpoint   int        `json:"checkpoint"`   // Data rows from the start of the file that are all committed
+	Error        string     `json:"error,omitempty"`
+	CreatedAt    time.Time  `json:"created_at"`
+	StartedAt    *time.Time `json:"started_at,omitempty"`
+	FinishedAt   *time.Time `json:"finished_at,omitempty"`
+	ReviewedBy   string     `json:"reviewed_by,omitempty"` // Who approved or rejected a staged import
+	ReviewedAt   *time.Time `json:"reviewed_at,omitempty"`
+	ResumeState  string     `json:"-"` // What an interrupted import needs to continue, as JSON
+}
+
+// ImportChunk records a run of consecutive rows an import has committed. It is written
+// in the same transaction as the rows, so a resumed import skips exactly these rows.
+type ImportChunk struct {
+	ImportID string `json:"import_id" gorm:"primaryKey"`
+	Offset   int    `json:"offset" gorm:"primaryKey;autoIncrement:false"` // First data row, counted from 0 after the header
+	Rows     int    `json:"rows"`                                         // Data rows in the chunk, rejected ones included
+	Inserted int    `json:"inserted"`
+	Updated  int    `json:"updated"`
+	Skipped  int    `json:"skipped"`
+	Failed   int    `json:"failed"`
+	// Rows of the chunk that failed, so a resumed import still reports them
+	Errors []RowError `json:"errors,omitempty" gorm:"serializer:json"`
+}
+
+// ImportChange keeps the state of a user before an import updated it, so the update can
+// be reverted. Before holds the user as JSON.
+type ImportChange struct {
+	ID        uint      `json:"id" gorm:"primaryKey"`
+	ImportID  string    `json:"import_id" gorm:"index"`
+	UserID    int       `json:"user_id"`
+	Before    string    `json:"before"`
+	Deleted   bool      `json:"deleted"` // The import deleted the user rather than updating it
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// RevertResult counts what reverting an import did, or would do in a dry run.
+type RevertResult struct {
+	Deleted  int64 `json:"deleted"`  // Users the import inserted
+	Restored int64 `json:"restored"` // Users the import updated, set back to their earlier values
+	Missing  int64 `json:"missing"`  // Updated users that no longer exist
+}
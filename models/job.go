@@ -0,0 +1,40 @@
+package models
+
+import "time"
+
+// Job states for background imports.
+const (
+	JobQueued    = "queued"
+	JobRunning   = "running"
+	JobCompleted = "completed"
+	JobFailed    = "failed"
+	JobCancelled = "cancelled" // Stopped on request; the committed rows stay and the import can be resumed
+)
+
+// ImportJob describes the progress of a background CSV import.
+type ImportJob struct {
+	ID           string     `json:"id"`
+	Filename     string     `json:"filename"`
+	State        string     `json:"state"`
+	Mode         string     `json:"mode"`          // Conflict policy, see ModeInsert and friends
+	Transaction  string     `json:"transaction"`   // TxBestEffort or TxAtomic
+	Loader       string     `json:"loader"`        // LoaderGorm or LoaderCopy
+	RowsRead     int        `json:"rows_read"`     // Data rows read from the file (header excluded)
+	RowsInserted int        `json:"rows_inserted"` // Rows written to the database as new records
+	RowsUpdated  int        `json:"rows_updated"`  // Existing records overwritten by an upsert
+	RowsSkipped  int        `json:"rows_skipped"`  // Rows left out because the record already existed
+	RowsFailed   int        `json:"rows_failed"`   // Rows that could not be parsed or inserted
+	Checkpoint   int        `json:"checkpoint"`    // Data rows from the start of the file that are all committed
+	Error        string     `json:"error,omitempty"`
+	FirstError   *RowError  `json:"first_error,omitempty"` // First rejected row of a rolled back atomic import
+	CreatedAt    time.Time  `json:"created_at"`
+	StartedAt    *time.Time `json:"started_at,omitempty"`
+	FinishedAt   *time.Time `json:"finished_at,omitempty"`
+}
+
+// RowError describes a CSV row that was rejected during an import.
+type RowError struct {
+	Line   int      `json:"line"`   // Line number in the source file
+	Values []string `json:"values"` // Raw values as read from the file
+	Reason string   `json:"reason"`
+}
@@ -0,0 +1,26 @@
+package models
+
+import "time"
+
+// Quarantine states.
+const (
+	QuarantinePending      = "pending"      // Waiting to be fixed and resubmitted
+	QuarantineResubmitting = "resubmitting" // Claimed by a resubmission in progress
+	QuarantineResubmitted  = "resubmitted"  // Imported by a later resubmission
+)
+
+// QuarantinedRow is a row rejected by an import, kept so it can be fixed and resubmitted.
+type QuarantinedRow struct {
+	ID       uint     `json:"id" gorm:"primaryKey"`
+	ImportID string   `json:"import_id" gorm:"index"` // Import that rejected the row
+	Filename string   `json:"filename"`
+	Line     int      `json:"line"`                          // Line number in the source file
+	Values   []string `json:"values" gorm:"serializer:json"` // Raw values as read from the file
+	// Values by User field or custom attribute name, as mapped by the import. Edits change these.
+	Fields              map[string]string `json:"fields" gorm:"serializer:json"`
+	Reason              string            `json:"reason"`
+	Status              string            `json:"status" gorm:"index"`
+	ResubmittedImportID *string           `json:"resubmitted_import_id,omitempty"` // Import that finally wrote the row
+	CreatedAt           time.Time         `json:"created_at"`
+	UpdatedAt           time.Time         `json:"updated_at"`
+}
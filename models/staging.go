@@ -0,0 +1,58 @@
+package models
+
+// States of a staged import in the imports table. Once approved it is JobCompleted.
+const (
+	ImportStaged    = "staged"    // Loaded into the staging area, waiting for approval
+	ImportRejected  = "rejected"  // Discarded by a reviewer; nothing was written to users
+	ImportPreviewed = "previewed" // Compared with users by a sync preview and discarded
+)
+
+// What applying a staged import does with users missing from the file.
+const (
+	MissingKeep       = "keep"       // Leave them as they are
+	MissingDelete     = "delete"     // Delete them
+	MissingDeactivate = "deactivate" // Keep them with IsActive set to false
+)
+
+// Kinds of change a staged import makes to the users table.
+const (
+	StagedNew         = "new"         // The row matches no user and is inserted
+	StagedChanged     = "changed"     // The row matches a user whose values differ
+	StagedRemoved     = "removed"     // The user matches no row and is deleted
+	StagedDeactivated = "deactivated" // The user matches no row and is set inactive
+)
+
+// StagedRow is a parsed row of a staged import, kept apart from the users table until
+// the import is approved.
+type StagedRow struct {
+	ID       uint     `json:"id" gorm:"primaryKey"`
+	ImportID string   `json:"import_id" gorm:"index"`
+	Line     int      `json:"line"`                          // Line number in the source file
+	Key      string   `json:"key" gorm:"index"`              // Id or email matched against the live users, by mode
+	User     User     `json:"user" gorm:"serializer:json"`   // Values as they will be written
+	Fields   []string `json:"fields" gorm:"serializer:json"` // Columns an update overwrites; nil for all
+}
+
+// StagedChange is one difference between a staged import and the users table.
+type StagedChange struct {
+	Kind    string   `json:"kind"`              // StagedNew, StagedChanged, StagedRemoved or StagedDeactivated
+	Line    int      `json:"line,omitempty"`    // Line number in the source file; 0 for missing users
+	Before  *User    `json:"before,omitempty"`  // Live user; nil for new rows
+	After   *User    `json:"after,omitempty"`   // User as approval writes it; nil for removed users
+	Columns []string `json:"columns,omitempty"` // Columns that differ, for changed rows
+}
+
+// StagedSummary counts the changes of a staged import.
+type StagedSummary struct {
+	New         int `json:"new"`
+	Changed     int `json:"changed"`
+	Removed     int `json:"removed"`
+	Deactivated int `json:"deactivated"`
+	Unchanged   int `json:"unchanged"` // Rows identical to the user they match
+}
+
+// StagedDiff is the difference between a staged import and the users table.
+type StagedDiff struct {
+	Summary StagedSummary  `json:"summary"`
+	Changes []StagedChange `json:"changes"`
+}
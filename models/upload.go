@@ -0,0 +1,16 @@
+package models
+
+import "time"
+
+// UploadSession describes a file sent in chunks through POST /uploads, PATCH /uploads/:id
+// and POST /uploads/:id/complete.
+type UploadSession struct {
+	ID        string    `json:"id"`
+	Filename  string    `json:"filename"`
+	Size      int64     `json:"size,omitempty"` // Total size announced by the client; 0 when unknown
+	SHA256    string    `json:"sha256"`         // Checksum the assembled file must have
+	Offset    int64     `json:"offset"`         // Bytes received so far
+	Uploader  string    `json:"uploader"`
+	CreatedAt time.Time `json:"created_at"`
+	ExpiresAt time.Time `json:"expires_at"` // Moved forward by every chunk
+}
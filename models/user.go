@@ -6,10 +6,11 @@ package models
 
 type User struct {
 	// gorm.Model
-	Id         int     `json:"id"`
-	FirstName  string  `json:"first_name"`  // User's first name
-	LastName   string  `json:"last_name"`   // User's last name
-	Email      string  `json:"email"`       // User's email, must be unique
+	Id        int    `json:"id"`
+	FirstName string `json:"first_name"` // User's first name
+	LastName  string `json:"last_name"`  // User's last name
+	// User's email, unique unless empty
+	Email      string  `json:"email" gorm:"uniqueIndex:idx_users_email,where:email <> ''"`
 	Age        int     `json:"age"`         // User's age
 	Gender     string  `json:"gender"`      // Gender (e.g., "Male", "Female", "Other")
 	Department string  `json:"department"`  // User's department
@@ -17,4 +18,8 @@ type User struct {
 	Salary     float64 `json:"salary"`      // User's salary
 	DateJoined string  `json:"date_joined"` // Date when the user joined (ISO format: YYYY-MM-DD)
 	IsActive   bool    `json:"is_active"`   // Active status of the user
+	// Import that created the user; nil for records added through the API
+	ImportID *string `json:"import_id,omitempty" gorm:"index"`
+	// Values of CSV columns that map to no field above, by attribute name
+	Attributes Attributes `json:"attributes,omitempty" gorm:"type:jsonb;index:,type:gin"`
 }
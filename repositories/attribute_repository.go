@@ -0,0 +1,28 @@
+package repository
+
+import (
+	"csv-microservice/models"
+
+	"gorm.io/gorm/clause"
+)
+
+// RegisterAttributes defines custom attributes. Attributes already defined keep the
+// header and import that registered them first.
+func (r *Repository) RegisterAttributes(attributes []models.Attribute) error {
+	if len(attributes) == 0 {
+		return nil
+	}
+	return r.Db.Clauses(clause.OnConflict{DoNothing: true}).Create(&attributes).Error
+}
+
+// ListAttributes returns every custom attribute by name, with the number of users that
+// have a value for it.
+func (r *Repository) ListAttributes() ([]models.Attribute, error) {
+	var attributes []models.Attribute
+	err := r.Db.Model(&models.Attribute{}).
+		// The ? operator tests for a key with the GIN index on attributes; with no arguments
+		// GORM leaves it alone
+		Select("attributes.*, (SELECT COUNT(*) FROM users WHERE users.attributes ? attributes.name) AS users").
+		Order("name").Find(&attributes).Error
+	return attributes, err
+}
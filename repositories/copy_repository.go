@@ -0,0 +1,89 @@
+package repository
+
+import (
+	"context"
+	"csv-microservice/models"
+	"fmt"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/stdlib"
+	"gorm.io/gorm"
+)
+
+// copyColumns are the users columns filled by CopyInsert, in COPY order.
+var copyColumns = []string{"first_name", "last_name", "email", "age", "gender", "department", "company", "salary", "date_joined", "is_active", "import_id", "attributes"}
+
+// CopyInsert streams records into the users table with COPY FROM STDIN.
+// Records without an id leave the column to its default (the sequence). Inside
+// Transaction or WriteChunk, COPY runs on that transaction's connection behind a
+// savepoint, so it commits or rolls back with the transaction and takes no second
+// connection from the pool. Otherwise it takes a connection and transaction of its own.
+func (r *Repository) CopyInsert(ctx context.Context, records []models.User) (int64, error) {
+	if len(records) == 0 {
+		return 0, nil
+	}
+
+	stmt := &gorm.Statement{DB: r.Db}
+	if err := stmt.Parse(&models.User{}); err != nil {
+		return 0, err
+	}
+
+	var withID, withoutID []models.User
+	for _, record := range records {
+		if record.Id != 0 {
+			withID = append(withID, record)
+		} else {
+			withoutID = append(withoutID, record)
+		}
+	}
+
+	var copied int64
+	// Both groups go in one transaction so a failure leaves nothing behind.
+	err := r.transaction(func(tx *Repository) error {
+		return tx.conn.Raw(func(driverConn any) error {
+			stdConn, ok := driverConn.(*stdlib.Conn)
+			if !ok {
+				return fmt.Errorf("COPY requires the pgx driver, got %T", driverConn)
+			}
+			// The transaction is open on this connection, so COPY runs inside it.
+			pgxConn := stdConn.Conn()
+
+			table := pgx.Identifier{stmt.Schema.Table}
+			if len(withID) > 0 {
+				n, err := pgxConn.CopyFrom(ctx, table, append([]string{"id"}, copyColumns...), copyRows(withID, true))
+				if err != nil {
+					return err
+				}
+				copied += n
+			}
+			if len(withoutID) > 0 {
+				n, err := pgxConn.CopyFrom(ctx, table, copyColumns, copyRows(withoutID, false))
+				if err != nil {
+					return err
+				}
+				copied += n
+			}
+			return nil
+		})
+	})
+	if err != nil {
+		return 0, err
+	}
+	return copied, nil
+}
+
+// copyRows adapts records to pgx's CopyFromSource in copyColumns order.
+func copyRows(records []models.User, withID bool) pgx.CopyFromSource {
+	return pgx.CopyFromSlice(len(records), func(i int) ([]any, error) {
+		u := records[i]
+		attributes, err := u.Attributes.Value()
+		if err != nil {
+			return nil, err
+		}
+		row := []any{u.FirstName, u.LastName, u.Email, u.Age, u.Gender, u.Department, u.Company, u.Salary, u.DateJoined, u.IsActive, u.ImportID, attributes}
+		if withID {
+			row = append([]any{u.Id}, row...)
+		}
+		return row, nil
+	})
+}
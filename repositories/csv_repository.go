@@ -3,9 +3,11 @@ package repository
 import (
 	"context"
 	"csv-microservice/models"
+	"database/sql"
 	"errors"
 	"fmt"
 	"strings"
+	"time"
 
 	"gorm.io/gorm"
 )
@@ -17,11 +19,42 @@ type RepositoryInterface interface {
 	QueryRecords(ctx context.Context, queryParams map[string]interface{}, offset, limit int) ([]models.User, error)
 	AddRecord(record models.User) error
 	BulkInsert(records []models.User) error
+	UpsertBatch(records []models.UserRecord, mode string, merge bool) (models.WriteResult, error)
+	FindExistingUsers(records []models.UserRecord, mode string) ([]bool, error)
+	Transaction(fn func(repo RepositoryInterface) error) error
+	CopyInsert(ctx context.Context, records []models.User) (int64, error)
+	SaveImport(record *models.Import) error
+	GetImport(id string) (models.Import, error)
+	ListImports(offset, limit int) ([]models.Import, int64, error)
+	RevertImport(id string, dryRun bool) (models.RevertResult, error)
+	FindImportBySHA256(sum string) (models.Import, error)
+	WriteChunk(chunk *models.ImportChunk, write func(repo RepositoryInterface) error) error
+	ListImportChunks(importID string) ([]models.ImportChunk, error)
+	ListResumableImports(createdBefore time.Time) ([]models.Import, error)
+	ReserveIdempotencyKey(key *models.IdempotencyKey, expiredBefore, abandonedBefore time.Time) (bool, error)
+	SaveIdempotencyKey(key *models.IdempotencyKey) error
+	DeleteIdempotencyKey(key string) error
+	QuarantineRows(rows []models.QuarantinedRow) error
+	ListQuarantine(importID, status string, offset, limit int) ([]models.QuarantinedRow, int64, error)
+	GetQuarantinedRows(ids []uint) ([]models.QuarantinedRow, error)
+	SaveQuarantinedRow(row *models.QuarantinedRow) error
+	ClaimQuarantinedRows(ids []uint) ([]uint, error)
+	ReleaseQuarantinedRows(claimedBefore time.Time) error
+	StageRows(rows []models.StagedRow, mode string) error
+	DiffStagedImport(id, mode, missing string) (models.StagedDiff, error)
+	ApproveStagedImport(id, mode, missing, reviewer string) (models.StagedSummary, error)
+	RejectStagedImport(id, reviewer string) error
+	DiscardStagedImport(id, state string) error
+	RegisterAttributes(attributes []models.Attribute) error
+	ListAttributes() ([]models.Attribute, error)
 }
 
 // Repository implementation
 type Repository struct {
 	Db *gorm.DB
+	// conn is the connection Db's transaction runs on, set inside Transaction and
+	// WriteChunk so CopyInsert can COPY within that transaction.
+	conn *sql.Conn
 }
 
 func NewRepository(db *gorm.DB) *Repository {
@@ -44,13 +77,20 @@ func (r *Repository) DeleteRecord(ctx context.Context, id int) error {
 	return nil
 }
 
+// AttributeParamPrefix marks a QueryRecords parameter that filters on a custom attribute:
+// "attributes.cost_center" matches users whose attribute cost_center equals the value.
+const AttributeParamPrefix = "attributes."
+
 func (r *Repository) QueryRecords(ctx context.Context, queryParams map[string]interface{}, offset, limit int) ([]models.User, error) {
 	var results []models.User
 	query := r.Db.WithContext(ctx)
 
 	// Apply filters dynamically
 	for key, value := range queryParams {
-		if key == "first_name" {
+		if name, ok := strings.CutPrefix(key, AttributeParamPrefix); ok {
+			// Containment is answered by the GIN index on attributes
+			query = query.Where("attributes @> jsonb_build_object(?::text, ?::text)", name, value)
+		} else if key == "first_name" {
 			query = query.Where("LOWER(first_name) LIKE ?", "%"+strings.ToLower(value.(string))+"%")
 		} else {
 			query = query.Where(key+" = ?", value)
@@ -74,19 +114,158 @@ func (r *Repository) AddRecord(record models.User) error {
 }
 
 // BulkInsert inserts multiple records in a single transaction.
+// Inside Transaction it uses a savepoint, so a failed batch leaves the outer transaction usable.
 func (r *Repository) BulkInsert(records []models.User) error {
 	if len(records) == 0 {
 		return nil
 	}
 
-	tx := r.Db.Begin()
-	if tx.Error != nil {
-		return tx.Error
+	return r.Db.Transaction(func(tx *gorm.DB) error {
+		return tx.Create(&records).Error
+	})
+}
+
+// Transaction runs fn against a repository bound to a single database transaction.
+// The transaction is committed if fn returns nil and rolled back otherwise.
+func (r *Repository) Transaction(fn func(repo RepositoryInterface) error) error {
+	return r.transaction(func(tx *Repository) error {
+		return fn(tx)
+	})
+}
+
+// transaction runs fn in a transaction on a connection of its own, which the repository
+// passed to fn keeps so COPY can use it. Nested calls use a savepoint on that connection.
+func (r *Repository) transaction(fn func(tx *Repository) error) error {
+	if r.conn != nil {
+		return r.Db.Transaction(func(tx *gorm.DB) error {
+			return fn(&Repository{Db: tx, conn: r.conn})
+		})
+	}
+	return r.Db.Connection(func(db *gorm.DB) error {
+		conn, _ := db.Statement.ConnPool.(*sql.Conn)
+		return db.Transaction(func(tx *gorm.DB) error {
+			return fn(&Repository{Db: tx, conn: conn})
+		})
+	})
+}
+
+// userColumns are the columns an upsert may overwrite. The primary key is never updated,
+// and import_id keeps pointing at the import that created the row.
+var userColumns = []string{"first_name", "last_name", "email", "age", "gender", "department", "company", "salary", "date_joined", "is_active", "attributes"}
+
+// UpsertBatch writes records in a single transaction according to the conflict mode
+// (see models.ModeInsert and friends). With merge set, updates only overwrite the
+// columns listed in each record's Fields, and add the record's attributes to those the
+// user already has. Each user is looked up before it is written, so of two transactions
+// writing the same email or id at once, the unique index makes the later one fail rather
+// than insert the user twice. Imports lock those keys so their chunks do not fail that way.
+func (r *Repository) UpsertBatch(records []models.UserRecord, mode string, merge bool) (models.WriteResult, error) {
+	var result models.WriteResult
+	if len(records) == 0 {
+		return result, nil
+	}
+
+	err := r.Db.Transaction(func(tx *gorm.DB) error {
+		for _, record := range records {
+			user := record.User
+			existing, found, err := findExisting(tx, user, mode)
+			if err != nil {
+				return err
+			}
+
+			switch {
+			case !found:
+				if err := tx.Create(&user).Error; err != nil {
+					return err
+				}
+				result.Inserted++
+			case mode == models.ModeSkipExisting:
+				result.Skipped++
+			default:
+				columns := userColumns
+				if merge {
+					columns = mergeColumns(record.Fields)
+					user.Attributes = mergeAttributes(existing.Attributes, user.Attributes)
+				}
+				if len(columns) > 0 {
+					if err := recordChange(tx, existing, user.ImportID); err != nil {
+						return err
+					}
+					if err := tx.Model(&existing).Select(columns).Updates(&user).Error; err != nil {
+						return err
+					}
+				}
+				result.Updated++
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return models.WriteResult{}, err
 	}
+	return result, nil
+}
 
-	if err := tx.Create(&records).Error; err != nil {
-		tx.Rollback()
-		return err
+// FindExistingUsers reports for each record whether a stored user conflicts with it under
+// mode, as UpsertBatch looks it up. Nothing is written.
+func (r *Repository) FindExistingUsers(records []models.UserRecord, mode string) ([]bool, error) {
+	found := make([]bool, len(records))
+	for i, record := range records {
+		_, ok, err := findExisting(r.Db, record.User, mode)
+		if err != nil {
+			return nil, err
+		}
+		found[i] = ok
+	}
+	return found, nil
+}
+
+// findExisting looks up the stored record that conflicts with user under the given mode.
+func findExisting(tx *gorm.DB, user models.User, mode string) (models.User, bool, error) {
+	var query *gorm.DB
+	switch {
+	case mode == models.ModeInsert:
+		return models.User{}, false, nil
+	case (mode == models.ModeUpsertID || mode == models.ModeSkipExisting) && user.Id != 0:
+		query = tx.Where("id = ?", user.Id)
+	case (mode == models.ModeUpsertEmail || mode == models.ModeSkipExisting) && user.Email != "":
+		query = tx.Where("email = ?", user.Email)
+	default:
+		return models.User{}, false, nil
+	}
+
+	var existing models.User
+	err := query.Limit(1).Find(&existing).Error
+	if err != nil {
+		return models.User{}, false, err
+	}
+	return existing, existing.Id != 0, nil
+}
+
+// mergeAttributes returns the attributes of user updated with those of a merged row.
+func mergeAttributes(user, row models.Attributes) models.Attributes {
+	if len(row) == 0 {
+		return user
+	}
+	merged := make(models.Attributes, len(user)+len(row))
+	for name, value := range user {
+		merged[name] = value
+	}
+	for name, value := range row {
+		merged[name] = value
+	}
+	return merged
+}
+
+// mergeColumns keeps the updatable columns that had a value in the source row.
+func mergeColumns(fields []string) []string {
+	var columns []string
+	for _, field := range fields {
+		for _, column := range userColumns {
+			if field == column {
+				columns = append(columns, column)
+			}
+		}
 	}
-	return tx.Commit().Error
+	return columns
 }
@@ -0,0 +1,125 @@
+package repository
+
+import (
+	"context"
+	"csv-microservice/models"
+	"fmt"
+	"os"
+	"testing"
+
+	"gorm.io/driver/postgres"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+// The benchmarks need a disposable Postgres database, e.g.
+// BENCH_DB_CONNECTION_STRING='host=localhost user=postgres password=... dbname=bench sslmode=disable' go test -bench . ./repositories/
+// They truncate the users table, as do the tests that use the same database.
+func benchRepository(b testing.TB) *Repository {
+	dsn := os.Getenv("BENCH_DB_CONNECTION_STRING")
+	if dsn == "" {
+		b.Skip("BENCH_DB_CONNECTION_STRING is not set")
+	}
+	db, err := gorm.Open(postgres.Open(dsn), &gorm.Config{Logger: logger.Default.LogMode(logger.Silent)})
+	if err != nil {
+		b.Fatalf("Failed to connect to database: %v", err)
+	}
+	if err := db.AutoMigrate(&models.User{}); err != nil {
+		b.Fatalf("Failed to migrate: %v", err)
+	}
+	return NewRepository(db)
+}
+
+func benchUsers(n int) []models.User {
+	users := make([]models.User, n)
+	for i := range users {
+		users[i] = models.User{
+			FirstName:  "First",
+			LastName:   "Last",
+			Email:      fmt.Sprintf("user%d@example.com", i),
+			Age:        30,
+			Gender:     "Other",
+			Department: "Engineering",
+			Company:    "TechCorp",
+			Salary:     100000,
+			DateJoined: "2025-01-01",
+			IsActive:   true,
+		}
+	}
+	return users
+}
+
+func truncateUsers(b *testing.B, r *Repository) {
+	b.StopTimer()
+	if err := r.Db.Exec("TRUNCATE TABLE users RESTART IDENTITY").Error; err != nil {
+		b.Fatalf("Failed to truncate users: %v", err)
+	}
+	b.StartTimer()
+}
+
+// TestCopyInsert_InWriteChunk checks that COPY inside WriteChunk needs no second
+// connection and rolls back with the chunk.
+func TestCopyInsert_InWriteChunk(t *testing.T) {
+	r := benchRepository(t)
+	if err := r.Db.AutoMigrate(&models.ImportChunk{}); err != nil {
+		t.Fatalf("Failed to migrate: %v", err)
+	}
+	if err := r.Db.Exec("TRUNCATE TABLE users RESTART IDENTITY").Error; err != nil {
+		t.Fatalf("Failed to truncate users: %v", err)
+	}
+	sqlDB, err := r.Db.DB()
+	if err != nil {
+		t.Fatal(err)
+	}
+	sqlDB.SetMaxOpenConns(1)
+
+	chunk := &models.ImportChunk{ImportID: "copy-in-write-chunk"}
+	errAbort := fmt.Errorf("abort")
+	err = r.WriteChunk(chunk, func(repo RepositoryInterface) error {
+		if _, err := repo.CopyInsert(context.Background(), benchUsers(10)); err != nil {
+			return err
+		}
+		return errAbort
+	})
+	if err != errAbort {
+		t.Fatalf("WriteChunk returned %v, want %v", err, errAbort)
+	}
+
+	var count int64
+	if err := r.Db.Model(&models.User{}).Count(&count).Error; err != nil {
+		t.Fatal(err)
+	}
+	if count != 0 {
+		t.Errorf("%d users left after the chunk rolled back, want 0", count)
+	}
+}
+
+// BenchmarkBulkInsert inserts 10,000 rows in batches of 100, as the GORM loader does.
+func BenchmarkBulkInsert(b *testing.B) {
+	r := benchRepository(b)
+	users := benchUsers(10000)
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		truncateUsers(b, r)
+		for start := 0; start < len(users); start += 100 {
+			if err := r.BulkInsert(users[start : start+100]); err != nil {
+				b.Fatal(err)
+			}
+		}
+	}
+}
+
+// BenchmarkCopyInsert inserts the same 10,000 rows in batches of 5,000, as the COPY loader does.
+func BenchmarkCopyInsert(b *testing.B) {
+	r := benchRepository(b)
+	users := benchUsers(10000)
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		truncateUsers(b, r)
+		for start := 0; start < len(users); start += 5000 {
+			if _, err := r.CopyInsert(context.Background(), users[start:start+5000]); err != nil {
+				b.Fatal(err)
+			}
+		}
+	}
+}
@@ -0,0 +1,37 @@
+package repository
+
+import (
+	"csv-microservice/models"
+	"time"
+
+	"gorm.io/gorm/clause"
+)
+
+// ReserveIdempotencyKey stores a new key for a request about to run and returns true. If
+// the key is already taken it loads the stored record into key and returns false. Keys
+// created before expiredBefore are replaced, and so are keys still waiting for a response
+// that were reserved before abandonedBefore.
+func (r *Repository) ReserveIdempotencyKey(key *models.IdempotencyKey, expiredBefore, abandonedBefore time.Time) (bool, error) {
+	if err := r.Db.Where("key = ? AND (created_at < ? OR (status = 0 AND created_at < ?))", key.Key, expiredBefore, abandonedBefore).
+		Delete(&models.IdempotencyKey{}).Error; err != nil {
+		return false, err
+	}
+	result := r.Db.Clauses(clause.OnConflict{DoNothing: true}).Create(key)
+	if result.Error != nil {
+		return false, result.Error
+	}
+	if result.RowsAffected == 1 {
+		return true, nil
+	}
+	return false, r.Db.Where("key = ?", key.Key).First(key).Error
+}
+
+// SaveIdempotencyKey stores the response of the request a key was reserved for.
+func (r *Repository) SaveIdempotencyKey(key *models.IdempotencyKey) error {
+	return r.Db.Save(key).Error
+}
+
+// DeleteIdempotencyKey releases a key, so the request can be retried.
+func (r *Repository) DeleteIdempotencyKey(key string) error {
+	return r.Db.Where("key = ?", key).Delete(&models.IdempotencyKey{}).Error
+}
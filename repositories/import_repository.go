@@ -0,0 +1,153 @@
+package repository
+
+import (
+	"csv-microservice/models"
+	"encoding/json"
+	"errors"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// errDryRun rolls back the transaction of a dry-run revert.
+var errDryRun = errors.New("dry run")
+
+// SaveImport creates or updates the audit record of an import.
+func (r *Repository) SaveImport(record *models.Import) error {
+	return r.Db.Save(record).Error
+}
+
+// GetImport looks up an import by ID. It returns gorm.ErrRecordNotFound when there is none.
+func (r *Repository) GetImport(id string) (models.Import, error) {
+	var record models.Import
+	err := r.Db.Where("id = ?", id).First(&record).Error
+	return record, err
+}
+
+// FindImportBySHA256 returns the latest import of a file with the given checksum that
+// wrote or may still write rows, i.e. one that neither failed nor was reverted, rejected
+// or only previewed. It returns gorm.ErrRecordNotFound when there is none.
+func (r *Repository) FindImportBySHA256(sum string) (models.Import, error) {
+	var record models.Import
+	err := r.Db.Where("sha256 = ? AND state NOT IN ?", sum, []string{models.JobFailed, models.ImportReverted, models.ImportRejected, models.ImportPreviewed}).
+		Order("created_at DESC").First(&record).Error
+	return record, err
+}
+
+// ListImports returns a page of imports, newest first, along with the total count.
+func (r *Repository) ListImports(offset, limit int) ([]models.Import, int64, error) {
+	var records []models.Import
+	var total int64
+	if err := r.Db.Model(&models.Import{}).Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+	err := r.Db.Order("created_at DESC").Offset(offset).Limit(limit).Find(&records).Error
+	return records, total, err
+}
+
+// WriteChunk runs write against a repository bound to one transaction and records the
+// chunk in the same transaction, once write has filled in its counts. Writes that fail
+// inside write roll back to their own savepoint, so later writes can still commit.
+func (r *Repository) WriteChunk(chunk *models.ImportChunk, write func(repo RepositoryInterface) error) error {
+	return r.transaction(func(tx *Repository) error {
+		if err := write(tx); err != nil {
+			return err
+		}
+		return tx.Db.Create(chunk).Error
+	})
+}
+
+// ListImportChunks returns the chunks an import has committed, in file order.
+func (r *Repository) ListImportChunks(importID string) ([]models.ImportChunk, error) {
+	var chunks []models.ImportChunk
+	err := r.Db.Where("import_id = ?", importID).Order("\"offset\"").Find(&chunks).Error
+	return chunks, err
+}
+
+// ListResumableImports returns the imports created before createdBefore that still keep
+// their upload for resuming.
+func (r *Repository) ListResumableImports(createdBefore time.Time) ([]models.Import, error) {
+	var records []models.Import
+	err := r.Db.Where("resume_state <> '' AND created_at < ?", createdBefore).Order("created_at").Find(&records).Error
+	return records, err
+}
+
+// recordChange saves the state of a user before an import overwrites it.
+func recordChange(tx *gorm.DB, before models.User, importID *string) error {
+	if importID == nil {
+		return nil
+	}
+	data, err := json.Marshal(before)
+	if err != nil {
+		return err
+	}
+	return tx.Create(&models.ImportChange{ImportID: *importID, UserID: before.Id, Before: string(data)}).Error
+}
+
+// RevertImport deletes the users an import inserted and restores the users it updated
+// or deleted, then marks the import as reverted, so it can no longer be resumed. A dry
+// run does the same work in a transaction that is rolled back, so the counts are exact.
+func (r *Repository) RevertImport(id string, dryRun bool) (models.RevertResult, error) {
+	var result models.RevertResult
+	err := r.Db.Transaction(func(tx *gorm.DB) error {
+		var changes []models.ImportChange
+		// Newest first, so a user updated twice ends up with its oldest before-image.
+		if err := tx.Where("import_id = ?", id).Order("id DESC").Find(&changes).Error; err != nil {
+			return err
+		}
+		restored, missing := make(map[int]bool), make(map[int]bool)
+		for _, change := range changes {
+			var current models.User
+			if err := tx.Where("id = ?", change.UserID).Limit(1).Find(&current).Error; err != nil {
+				return err
+			}
+			var before models.User
+			if err := json.Unmarshal([]byte(change.Before), &before); err != nil {
+				return err
+			}
+			if current.Id == 0 && change.Deleted {
+				if err := tx.Create(&before).Error; err != nil {
+					return err
+				}
+				restored[change.UserID] = true
+				continue
+			}
+			if current.Id == 0 {
+				missing[change.UserID] = true
+				continue
+			}
+			if current.ImportID != nil && *current.ImportID == id {
+				continue // Inserted by this import as well, so it is deleted below
+			}
+			if err := tx.Model(&current).Select(userColumns).Updates(&before).Error; err != nil {
+				return err
+			}
+			restored[change.UserID] = true
+		}
+		result.Restored, result.Missing = int64(len(restored)), int64(len(missing))
+
+		res := tx.Where("import_id = ?", id).Delete(&models.User{})
+		if res.Error != nil {
+			return res.Error
+		}
+		result.Deleted = res.RowsAffected
+
+		if err := tx.Where("import_id = ?", id).Delete(&models.ImportChange{}).Error; err != nil {
+			return err
+		}
+		if err := tx.Where("import_id = ?", id).Delete(&models.ImportChunk{}).Error; err != nil {
+			return err
+		}
+		if err := tx.Model(&models.Import{}).Where("id = ?", id).Update("state", models.ImportReverted).Error; err != nil {
+			return err
+		}
+		if dryRun {
+			return errDryRun
+		}
+		return nil
+	})
+	if err != nil && !errors.Is(err, errDryRun) {
+		return models.RevertResult{}, err
+	}
+	return result, nil
+}
@@ -0,0 +1,74 @@
+package repository
+
+import (
+	"csv-microservice/models"
+	"time"
+
+	"gorm.io/gorm/clause"
+)
+
+// QuarantineRows stores rejected rows.
+func (r *Repository) QuarantineRows(rows []models.QuarantinedRow) error {
+	if len(rows) == 0 {
+		return nil
+	}
+	return r.Db.CreateInBatches(&rows, 500).Error
+}
+
+// ListQuarantine returns a page of quarantined rows, oldest first, along with the total
+// count. Empty importID or status values do not filter.
+func (r *Repository) ListQuarantine(importID, status string, offset, limit int) ([]models.QuarantinedRow, int64, error) {
+	query := r.Db.Model(&models.QuarantinedRow{})
+	if importID != "" {
+		query = query.Where("import_id = ?", importID)
+	}
+	if status != "" {
+		query = query.Where("status = ?", status)
+	}
+
+	var total int64
+	if err := query.Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+	var rows []models.QuarantinedRow
+	err := query.Order("id").Offset(offset).Limit(limit).Find(&rows).Error
+	return rows, total, err
+}
+
+// GetQuarantinedRows looks up quarantined rows by ID. Unknown IDs are left out.
+func (r *Repository) GetQuarantinedRows(ids []uint) ([]models.QuarantinedRow, error) {
+	var rows []models.QuarantinedRow
+	err := r.Db.Where("id IN ?", ids).Order("id").Find(&rows).Error
+	return rows, err
+}
+
+// SaveQuarantinedRow updates a quarantined row.
+func (r *Repository) SaveQuarantinedRow(row *models.QuarantinedRow) error {
+	return r.Db.Save(row).Error
+}
+
+// ClaimQuarantinedRows marks the pending rows among ids as being resubmitted in a single
+// update and returns the IDs it marked. Rows another resubmission claimed first are left
+// out, so no row is imported twice.
+func (r *Repository) ClaimQuarantinedRows(ids []uint) ([]uint, error) {
+	var claimed []models.QuarantinedRow
+	err := r.Db.Model(&claimed).Clauses(clause.Returning{Columns: []clause.Column{{Name: "id"}}}).
+		Where("id IN ? AND status = ?", ids, models.QuarantinePending).
+		Update("status", models.QuarantineResubmitting).Error
+	if err != nil {
+		return nil, err
+	}
+	result := make([]uint, len(claimed))
+	for i, row := range claimed {
+		result[i] = row.ID
+	}
+	return result, nil
+}
+
+// ReleaseQuarantinedRows makes the rows claimed before claimedBefore pending again. The
+// resubmission that claimed them did not finish, e.g. because the service stopped.
+func (r *Repository) ReleaseQuarantinedRows(claimedBefore time.Time) error {
+	return r.Db.Model(&models.QuarantinedRow{}).
+		Where("status = ? AND updated_at < ?", models.QuarantineResubmitting, claimedBefore).
+		Update("status", models.QuarantinePending).Error
+}
@@ -0,0 +1,270 @@
+package repository
+
+import (
+	"csv-microservice/models"
+	"encoding/json"
+	"errors"
+	"reflect"
+	"strconv"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// ErrNotStaged is returned when an import to approve or reject is not waiting for approval.
+var ErrNotStaged = errors.New("import is not staged")
+
+// stagingKeyColumn is the users column staged rows are matched on under mode.
+func stagingKeyColumn(mode string) string {
+	if mode == models.ModeUpsertID {
+		return "id"
+	}
+	return "email"
+}
+
+// stagingKey returns the value of user's key column under mode, empty when it has none.
+func stagingKey(user models.User, mode string) string {
+	if mode == models.ModeUpsertID {
+		if user.Id == 0 {
+			return ""
+		}
+		return strconv.Itoa(user.Id)
+	}
+	return user.Email
+}
+
+// StageRows stores parsed rows of a staged import, keyed for matching under mode.
+func (r *Repository) StageRows(rows []models.StagedRow, mode string) error {
+	if len(rows) == 0 {
+		return nil
+	}
+	for i := range rows {
+		rows[i].Key = stagingKey(rows[i].User, mode)
+	}
+	return r.Db.CreateInBatches(&rows, 500).Error
+}
+
+// DiffStagedImport compares the rows of a staged import with the users table. missing
+// says what happens to users the file does not have, see models.MissingKeep.
+func (r *Repository) DiffStagedImport(id, mode, missing string) (models.StagedDiff, error) {
+	return diffStaged(r.Db, id, mode, missing)
+}
+
+// diffStaged matches the staged rows of an import with the users table on the key
+// column of mode. When the file has several rows for one user, the last one wins.
+// Users that are already inactive are not deactivated again.
+func diffStaged(tx *gorm.DB, id, mode, missing string) (models.StagedDiff, error) {
+	var diff models.StagedDiff
+	var rows []models.StagedRow
+	if err := tx.Where("import_id = ?", id).Order("line").Find(&rows).Error; err != nil {
+		return diff, err
+	}
+
+	keyed := make(map[string]int) // Key -> index of its row in rows
+	var keys []string
+	for i, row := range rows {
+		if row.Key == "" {
+			continue
+		}
+		if _, ok := keyed[row.Key]; !ok {
+			keys = append(keys, row.Key)
+		}
+		keyed[row.Key] = i
+	}
+	live := make(map[string]models.User, len(keys))
+	column := stagingKeyColumn(mode)
+	for start := 0; start < len(keys); start += 1000 {
+		end := min(start+1000, len(keys))
+		var users []models.User
+		if err := tx.Where(column+" IN ?", keys[start:end]).Find(&users).Error; err != nil {
+			return diff, err
+		}
+		for _, user := range users {
+			live[stagingKey(user, mode)] = user
+		}
+	}
+
+	for i, row := range rows {
+		if row.Key != "" && keyed[row.Key] != i {
+			continue // A later row has the same key
+		}
+		after := row.User
+		before, found := live[row.Key]
+		if row.Key == "" || !found {
+			diff.Changes = append(diff.Changes, models.StagedChange{Kind: models.StagedNew, Line: row.Line, After: &after})
+			diff.Summary.New++
+			continue
+		}
+		if row.Fields != nil {
+			after.Attributes = mergeAttributes(before.Attributes, after.Attributes)
+		}
+		columns := changedColumns(before, after, row.Fields)
+		if len(columns) == 0 {
+			diff.Summary.Unchanged++
+			continue
+		}
+		diff.Changes = append(diff.Changes, models.StagedChange{Kind: models.StagedChanged, Line: row.Line, Before: &before, After: &after, Columns: columns})
+		diff.Summary.Changed++
+	}
+
+	if missing == models.MissingKeep {
+		return diff, nil
+	}
+	query := tx.Where("NOT EXISTS (SELECT 1 FROM staged_rows s WHERE s.import_id = ? AND s.key = CAST(users."+column+" AS TEXT))", id)
+	kind := models.StagedRemoved
+	if missing == models.MissingDeactivate {
+		query = query.Where("is_active = ?", true)
+		kind = models.StagedDeactivated
+	}
+	var removed []models.User
+	if err := query.Order("id").Find(&removed).Error; err != nil {
+		return diff, err
+	}
+	for i := range removed {
+		diff.Changes = append(diff.Changes, models.StagedChange{Kind: kind, Before: &removed[i]})
+	}
+	if kind == models.StagedDeactivated {
+		diff.Summary.Deactivated = len(removed)
+	} else {
+		diff.Summary.Removed = len(removed)
+	}
+	return diff, nil
+}
+
+// userFieldNames maps the updatable columns to the fields of models.User.
+var userFieldNames = map[string]string{
+	"first_name": "FirstName", "last_name": "LastName", "email": "Email", "age": "Age", "gender": "Gender",
+	"department": "Department", "company": "Company", "salary": "Salary", "date_joined": "DateJoined", "is_active": "IsActive",
+	"attributes": "Attributes",
+}
+
+// changedColumns lists the columns an update from after would change in before. fields
+// limits the comparison to the columns a merge overwrites; nil compares all of them.
+func changedColumns(before, after models.User, fields []string) []string {
+	columns := userColumns
+	if fields != nil {
+		columns = mergeColumns(fields)
+	}
+	b, a := reflect.ValueOf(before), reflect.ValueOf(after)
+	var changed []string
+	for _, column := range columns {
+		name := userFieldNames[column]
+		if !reflect.DeepEqual(b.FieldByName(name).Interface(), a.FieldByName(name).Interface()) {
+			changed = append(changed, column)
+		}
+	}
+	return changed
+}
+
+// ApproveStagedImport writes a staged import to the users table in one transaction: new
+// rows are inserted, changed users updated and missing users deleted or deactivated. The
+// diff is taken again inside the transaction, so it reflects the users table at approval.
+// Updates and deletions are recorded, so the import can be reverted like any other. An
+// empty reviewer applies the import without recording a review. It returns ErrNotStaged
+// when the import is not waiting for approval.
+func (r *Repository) ApproveStagedImport(id, mode, missing, reviewer string) (models.StagedSummary, error) {
+	var summary models.StagedSummary
+	err := r.Db.Transaction(func(tx *gorm.DB) error {
+		if err := review(tx, id, models.JobCompleted, reviewer); err != nil {
+			return err
+		}
+		diff, err := diffStaged(tx, id, mode, missing)
+		if err != nil {
+			return err
+		}
+		for _, change := range diff.Changes {
+			switch change.Kind {
+			case models.StagedNew:
+				if err := tx.Create(change.After).Error; err != nil {
+					return err
+				}
+			case models.StagedChanged:
+				if err := recordChange(tx, *change.Before, &id); err != nil {
+					return err
+				}
+				if err := tx.Model(change.Before).Select(change.Columns).Updates(change.After).Error; err != nil {
+					return err
+				}
+			case models.StagedRemoved:
+				if err := recordDeletion(tx, *change.Before, id); err != nil {
+					return err
+				}
+				if err := tx.Delete(change.Before).Error; err != nil {
+					return err
+				}
+			case models.StagedDeactivated:
+				if err := recordChange(tx, *change.Before, &id); err != nil {
+					return err
+				}
+				if err := tx.Model(change.Before).Update("is_active", false).Error; err != nil {
+					return err
+				}
+			}
+		}
+		summary = diff.Summary
+		err = tx.Model(&models.Import{}).Where("id = ?", id).Updates(map[string]interface{}{
+			"rows_inserted": summary.New,
+			"rows_updated":  summary.Changed + summary.Deactivated,
+			"rows_deleted":  summary.Removed,
+			"rows_skipped":  summary.Unchanged,
+		}).Error
+		if err != nil {
+			return err
+		}
+		return tx.Where("import_id = ?", id).Delete(&models.StagedRow{}).Error
+	})
+	if err != nil {
+		return models.StagedSummary{}, err
+	}
+	return summary, nil
+}
+
+// RejectStagedImport discards the rows of a staged import without touching the users
+// table. It returns ErrNotStaged when the import is not waiting for approval.
+func (r *Repository) RejectStagedImport(id, reviewer string) error {
+	return r.Db.Transaction(func(tx *gorm.DB) error {
+		if err := review(tx, id, models.ImportRejected, reviewer); err != nil {
+			return err
+		}
+		return tx.Where("import_id = ?", id).Delete(&models.StagedRow{}).Error
+	})
+}
+
+// DiscardStagedImport removes the staged rows of an import, e.g. after a sync preview or
+// a failed sync. An import still waiting for approval is moved to state.
+func (r *Repository) DiscardStagedImport(id, state string) error {
+	return r.Db.Transaction(func(tx *gorm.DB) error {
+		err := tx.Model(&models.Import{}).Where("id = ? AND state = ?", id, models.ImportStaged).Update("state", state).Error
+		if err != nil {
+			return err
+		}
+		return tx.Where("import_id = ?", id).Delete(&models.StagedRow{}).Error
+	})
+}
+
+// review moves a staged import to state. Checking the state in the same statement keeps
+// two reviewers from deciding on one import.
+func review(tx *gorm.DB, id, state, reviewer string) error {
+	updates := map[string]interface{}{"state": state}
+	if reviewer != "" {
+		updates["reviewed_by"] = reviewer
+		updates["reviewed_at"] = time.Now()
+	}
+	res := tx.Model(&models.Import{}).Where("id = ? AND state = ?", id, models.ImportStaged).Updates(updates)
+	if res.Error != nil {
+		return res.Error
+	}
+	if res.RowsAffected == 0 {
+		return ErrNotStaged
+	}
+	return nil
+}
+
+// recordDeletion saves a user an import deletes, so reverting the import recreates it.
+func recordDeletion(tx *gorm.DB, before models.User, importID string) error {
+	data, err := json.Marshal(before)
+	if err != nil {
+		return err
+	}
+	return tx.Create(&models.ImportChange{ImportID: importID, UserID: before.Id, Before: string(data), Deleted: true}).Error
+}
@@ -9,10 +9,36 @@ import (
 // RegisterRoutes registers all API routes and maps them to the respective controller methods.
 func RegisterRoutes(router *gin.Engine, controller *controllers.Controller) {
 	router.POST("/upload", controller.UploadCSV)
+	router.POST("/upload/json", controller.UploadJSON)
+	router.POST("/validate", controller.ValidateCSV)
+	router.POST("/sync", controller.SyncCSV)
+	router.POST("/uploads", controller.CreateUpload)
+	router.GET("/uploads/:id", controller.GetUpload)
+	router.PATCH("/uploads/:id", controller.AppendUpload)
+	router.DELETE("/uploads/:id", controller.AbortUpload)
+	router.POST("/uploads/:id/complete", controller.CompleteUpload)
 	router.GET("/list", controller.ListRecords)
 	router.GET("/listByPages", controller.ListRecordsByPages)
 	router.GET("/search", controller.SearchRecords)
+	router.GET("/attributes", controller.ListAttributes)
 	router.POST("/add", controller.AddRecord)
 	router.DELETE("/delete/:id", controller.DeleteRecord)
 	router.GET("/logs", controller.GetLogs)
+	router.GET("/jobs", controller.ListJobs)
+	router.GET("/jobs/:id", controller.GetJob)
+	router.GET("/jobs/:id/errors", controller.GetJobErrors)
+	router.POST("/jobs/:id/cancel", controller.CancelJob)
+	router.GET("/imports", controller.ListImports)
+	router.GET("/imports/:id", controller.GetImport)
+	router.DELETE("/imports/:id", controller.RevertImport)
+	router.POST("/imports/:id/resume", controller.ResumeImport)
+	router.POST("/imports/:id/reimport", controller.ReimportImport)
+	router.GET("/imports/:id/diff", controller.DiffImport)
+	router.POST("/imports/:id/approve", controller.ApproveImport)
+	router.POST("/imports/:id/reject", controller.RejectImport)
+	router.GET("/blobs/:sha256", controller.GetBlob)
+	router.GET("/quarantine", controller.ListQuarantine)
+	router.PUT("/quarantine/:id", controller.UpdateQuarantinedRow)
+	router.POST("/quarantine/:id/resubmit", controller.ResubmitQuarantinedRow)
+	router.POST("/quarantine/resubmit", controller.ResubmitQuarantine)
 }
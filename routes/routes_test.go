@@ -25,6 +25,75 @@ func (m *MockService) QueryUpdates(ctx *gin.Context) {
 }
 func (m *MockService) AddRecord(ctx *gin.Context)    { ctx.JSON(200, gin.H{"message": "AddRecord"}) }
 func (m *MockService) DeleteRecord(ctx *gin.Context) { ctx.JSON(200, gin.H{"message": "DeleteRecord"}) }
+func (m *MockService) GetJob(ctx *gin.Context)       { ctx.JSON(200, gin.H{"message": "GetJob"}) }
+func (m *MockService) ListJobs(ctx *gin.Context)     { ctx.JSON(200, gin.H{"message": "ListJobs"}) }
+func (m *MockService) GetJobErrors(ctx *gin.Context) { ctx.JSON(200, gin.H{"message": "GetJobErrors"}) }
+func (m *MockService) ValidateCSV(ctx *gin.Context)  { ctx.JSON(200, gin.H{"message": "ValidateCSV"}) }
+func (m *MockService) UploadJSON(ctx *gin.Context)   { ctx.JSON(200, gin.H{"message": "UploadJSON"}) }
+func (m *MockService) ListImports(ctx *gin.Context)  { ctx.JSON(200, gin.H{"message": "ListImports"}) }
+func (m *MockService) GetImport(ctx *gin.Context)    { ctx.JSON(200, gin.H{"message": "GetImport"}) }
+func (m *MockService) RevertImport(ctx *gin.Context) { ctx.JSON(200, gin.H{"message": "RevertImport"}) }
+func (m *MockService) ListQuarantine(ctx *gin.Context) {
+	ctx.JSON(200, gin.H{"message": "ListQuarantine"})
+}
+func (m *MockService) UpdateQuarantinedRow(ctx *gin.Context) {
+	ctx.JSON(200, gin.H{"message": "UpdateQuarantinedRow"})
+}
+func (m *MockService) ResubmitQuarantinedRow(ctx *gin.Context) {
+	ctx.JSON(200, gin.H{"message": "ResubmitQuarantinedRow"})
+}
+func (m *MockService) ResubmitQuarantine(ctx *gin.Context) {
+	ctx.JSON(200, gin.H{"message": "ResubmitQuarantine"})
+}
+func (m *MockService) CancelJob(ctx *gin.Context) {
+	ctx.JSON(200, gin.H{"message": "CancelJob"})
+}
+func (m *MockService) ResumeImport(ctx *gin.Context) {
+	ctx.JSON(200, gin.H{"message": "ResumeImport"})
+}
+
+func (m *MockService) SyncCSV(ctx *gin.Context) {
+	ctx.JSON(200, gin.H{"message": "SyncCSV"})
+}
+
+func (m *MockService) ListAttributes(ctx *gin.Context) {
+	ctx.JSON(200, gin.H{"message": "ListAttributes"})
+}
+
+func (m *MockService) DiffImport(ctx *gin.Context) {
+	ctx.JSON(200, gin.H{"message": "DiffImport"})
+}
+
+func (m *MockService) ApproveImport(ctx *gin.Context) {
+	ctx.JSON(200, gin.H{"message": "ApproveImport"})
+}
+
+func (m *MockService) RejectImport(ctx *gin.Context) {
+	ctx.JSON(200, gin.H{"message": "RejectImport"})
+}
+
+func (m *MockService) ReimportImport(ctx *gin.Context) {
+	ctx.JSON(200, gin.H{"message": "ReimportImport"})
+}
+
+func (m *MockService) GetBlob(ctx *gin.Context) {
+	ctx.JSON(200, gin.H{"message": "GetBlob"})
+}
+func (m *MockService) CreateUpload(ctx *gin.Context) {
+	ctx.JSON(200, gin.H{"message": "CreateUpload"})
+}
+func (m *MockService) GetUpload(ctx *gin.Context) {
+	ctx.JSON(200, gin.H{"message": "GetUpload"})
+}
+func (m *MockService) AppendUpload(ctx *gin.Context) {
+	ctx.JSON(200, gin.H{"message": "AppendUpload"})
+}
+func (m *MockService) CompleteUpload(ctx *gin.Context) {
+	ctx.JSON(200, gin.H{"message": "CompleteUpload"})
+}
+func (m *MockService) AbortUpload(ctx *gin.Context) {
+	ctx.JSON(200, gin.H{"message": "AbortUpload"})
+}
 
 func TestRegisterRoutes(t *testing.T) {
 	// Initialize a Gin router
@@ -45,11 +114,37 @@ func TestRegisterRoutes(t *testing.T) {
 		expected string
 	}{
 		{"POST", "/upload", "UploadCSV"},
+		{"POST", "/upload/json", "UploadJSON"},
+		{"POST", "/validate", "ValidateCSV"},
+		{"POST", "/uploads", "CreateUpload"},
+		{"GET", "/uploads/abc", "GetUpload"},
+		{"PATCH", "/uploads/abc", "AppendUpload"},
+		{"DELETE", "/uploads/abc", "AbortUpload"},
+		{"POST", "/uploads/abc/complete", "CompleteUpload"},
 		{"GET", "/list", "ListRecords"},
 		{"GET", "/listByPages", "ListRecordsByPages"},
 		{"GET", "/search", "SearchRecords"},
+		{"GET", "/attributes", "ListAttributes"},
 		{"POST", "/add", "AddRecord"},
 		{"DELETE", "/delete/1", "DeleteRecord"},
+		{"GET", "/jobs", "ListJobs"},
+		{"GET", "/jobs/abc", "GetJob"},
+		{"GET", "/jobs/abc/errors", "GetJobErrors"},
+		{"POST", "/jobs/abc/cancel", "CancelJob"},
+		{"GET", "/imports", "ListImports"},
+		{"GET", "/imports/abc", "GetImport"},
+		{"DELETE", "/imports/abc", "RevertImport"},
+		{"POST", "/imports/abc/resume", "ResumeImport"},
+		{"POST", "/imports/abc/reimport", "ReimportImport"},
+		{"GET", "/imports/abc/diff", "DiffImport"},
+		{"POST", "/sync", "SyncCSV"},
+		{"POST", "/imports/abc/approve", "ApproveImport"},
+		{"POST", "/imports/abc/reject", "RejectImport"},
+		{"GET", "/blobs/abc", "GetBlob"},
+		{"GET", "/quarantine", "ListQuarantine"},
+		{"PUT", "/quarantine/1", "UpdateQuarantinedRow"},
+		{"POST", "/quarantine/1/resubmit", "ResubmitQuarantinedRow"},
+		{"POST", "/quarantine/resubmit", "ResubmitQuarantine"},
 	}
 
 	// Test each route
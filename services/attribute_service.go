@@ -0,0 +1,55 @@
+package services
+
+import (
+	"csv-microservice/models"
+	repository "csv-microservice/repositories"
+	"csv-microservice/utils"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+// registerAttributes defines the custom attributes of an import's attribute columns before
+// its rows are written. A failure is logged; the values are stored on the users anyway.
+func (s *Service) registerAttributes(task *importTask) {
+	if task.importID == nil || len(task.columns.attributes) == 0 {
+		return
+	}
+	attributes := make([]models.Attribute, len(task.columns.attributes))
+	for i, column := range task.columns.attributes {
+		attributes[i] = models.Attribute{Name: column.name, Header: column.header, ImportID: *task.importID}
+	}
+	if err := s.Repo.RegisterAttributes(attributes); err != nil {
+		utils.LogError("registerAttributes", "Failed to register attributes of import "+*task.importID, err)
+	}
+}
+
+// attributeFilters returns the custom attribute filters of a search, given as
+// ?attributes[name]=value, keyed for Repo.QueryRecords.
+func attributeFilters(ctx *gin.Context) map[string]string {
+	filters := make(map[string]string)
+	for name, value := range ctx.QueryMap("attributes") {
+		filters[repository.AttributeParamPrefix+normalizeHeader(name)] = value
+	}
+	return filters
+}
+
+// ListAttributes lists the custom attributes imports have defined. CSV columns that map to
+// no User field are stored under these names in the attributes of each user, and can be
+// searched with GET /search?attributes[name]=value.
+func (s *Service) ListAttributes(ctx *gin.Context) {
+	attributes, err := s.Repo.ListAttributes()
+	if err != nil {
+		utils.LogError("ListAttributes", "Error fetching attributes from database", err)
+		ctx.JSON(http.StatusInternalServerError, gin.H{
+			"status":  "error",
+			"message": "Failed to fetch attributes",
+		})
+		return
+	}
+
+	ctx.JSON(http.StatusOK, gin.H{
+		"status": "success",
+		"data":   attributes,
+	})
+}
@@ -0,0 +1,134 @@
+package services
+
+import (
+	"csv-microservice/mock"
+	"csv-microservice/models"
+	"csv-microservice/utils"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestUploadCSV_Attributes(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockRepo := mock.NewMockRepositoryInterface(ctrl)
+	service := NewService(mockRepo)
+	utils.InitLogger()
+
+	router := gin.Default()
+	router.POST("/upload", service.UploadCSV)
+
+	content := "first_name,last_name,email,Cost Center,Location\nJohn,Doe,john@example.com,CC-42,Berlin\nJane,Roe,jane@example.com,,\n"
+
+	var registered []models.Attribute
+	var saved models.Import
+	expectNewUploads(mockRepo)
+	expectChunks(mockRepo)
+	mockRepo.EXPECT().SaveImport(gomock.Any()).DoAndReturn(func(record *models.Import) error {
+		saved = *record
+		return nil
+	}).Times(2)
+	mockRepo.EXPECT().RegisterAttributes(gomock.Any()).DoAndReturn(func(attributes []models.Attribute) error {
+		registered = attributes
+		return nil
+	})
+	mockRepo.EXPECT().BulkInsert(importedUsers{
+		{FirstName: "John", LastName: "Doe", Email: "john@example.com", Attributes: models.Attributes{"cost_center": "CC-42", "location": "Berlin"}},
+		{FirstName: "Jane", LastName: "Roe", Email: "jane@example.com"},
+	}).Return(nil)
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, newUploadRequest(t, "/upload", "users.csv", content, nil))
+	assert.Equal(t, http.StatusAccepted, w.Code)
+	for _, job := range service.Jobs.List(10) {
+		stored, _ := service.Jobs.Get(job.ID)
+		stored.Wait()
+	}
+
+	assert.Equal(t, models.JobCompleted, saved.State)
+	assert.Equal(t, []models.Attribute{
+		{Name: "cost_center", Header: "Cost Center", ImportID: saved.ID},
+		{Name: "location", Header: "Location", ImportID: saved.ID},
+	}, registered)
+}
+
+func TestQuarantineSource_Attributes(t *testing.T) {
+	rows := []models.QuarantinedRow{
+		{ID: 1, Fields: map[string]string{FieldFirstName: "John", FieldEmail: "john@example.com", "location": "Berlin"}},
+		{ID: 2, Fields: map[string]string{FieldFirstName: "Jane", "cost_center": "CC-42"}},
+	}
+	header := quarantineHeader(rows)
+	assert.Equal(t, append(append([]string{}, userFields...), "cost_center", "location"), header)
+
+	columns, err := resolveColumns(header, MappingProfile{})
+	assert.NoError(t, err)
+	reader, err := quarantineSource(header, rows).open()
+	assert.NoError(t, err)
+	reader.Read() // Header
+	record, line, err := reader.Read()
+	assert.NoError(t, err)
+	assert.Equal(t, 1, line)
+	user, err := buildUser(record, columns, parserFor(LocaleUS))
+	assert.NoError(t, err)
+	assert.Equal(t, models.Attributes{"location": "Berlin"}, user.Attributes)
+}
+
+func TestListAttributes(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockRepo := mock.NewMockRepositoryInterface(ctrl)
+	service := NewService(mockRepo)
+	utils.InitLogger()
+
+	router := gin.Default()
+	router.GET("/attributes", service.ListAttributes)
+
+	created := time.Date(2026, 10, 1, 12, 0, 0, 0, time.UTC)
+	tests := []struct {
+		name           string
+		mockSetup      func()
+		expectedStatus int
+		expectedBody   string
+	}{
+		{
+			name: "Success",
+			mockSetup: func() {
+				mockRepo.EXPECT().ListAttributes().Return([]models.Attribute{
+					{Name: "cost_center", Header: "Cost Center", ImportID: "abc", Users: 12, CreatedAt: created},
+				}, nil)
+			},
+			expectedStatus: http.StatusOK,
+			expectedBody:   `{"status":"success","data":[{"name":"cost_center","header":"Cost Center","import_id":"abc","users":12,"created_at":"2026-10-01T12:00:00Z"}]}`,
+		},
+		{
+			name: "Database error",
+			mockSetup: func() {
+				mockRepo.EXPECT().ListAttributes().Return(nil, errors.New("db error"))
+			},
+			expectedStatus: http.StatusInternalServerError,
+			expectedBody:   `{"status":"error","message":"Failed to fetch attributes"}`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.mockSetup()
+
+			w := httptest.NewRecorder()
+			req, _ := http.NewRequest(http.MethodGet, "/attributes", nil)
+			router.ServeHTTP(w, req)
+
+			assert.Equal(t, tt.expectedStatus, w.Code)
+			assert.JSONEq(t, tt.expectedBody, w.Body.String())
+		})
+	}
+}
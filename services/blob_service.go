@@ -0,0 +1,309 @@
+package services
+
+import (
+	"context"
+	"csv-microservice/utils"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// blobSweepInterval is how often blobs past their retention are removed.
+const blobSweepInterval = time.Hour
+
+// BlobStore archives uploaded files under their SHA-256 checksum, so a file uploaded
+// several times is stored once. A blob is kept for the retention period after it was
+// last uploaded.
+type BlobStore struct {
+	mu        sync.Mutex // Held while a blob is stored or the archive is swept
+	dir       string
+	retention time.Duration // 0 keeps blobs forever
+}
+
+// NewBlobStore opens the archive in dir, creating the directory if needed.
+func NewBlobStore(dir string, retention time.Duration) (*BlobStore, error) {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, err
+	}
+	return &BlobStore{dir: dir, retention: retention}, nil
+}
+
+// validBlobKey reports whether key is a hex encoded SHA-256 checksum.
+func validBlobKey(key string) bool {
+	decoded, err := hex.DecodeString(key)
+	return err == nil && len(decoded) == 32 && key == strings.ToLower(key)
+}
+
+// path returns where the blob with the given key is stored. Blobs are spread over
+// subdirectories named after the first two characters of the key.
+func (b *BlobStore) path(key string) string {
+	return filepath.Join(b.dir, key[:2], key)
+}
+
+// Put archives the file at path under key, the file's SHA-256 checksum. A blob that is
+// already archived is kept and its retention starts again.
+func (b *BlobStore) Put(path, key string) error {
+	if !validBlobKey(key) {
+		return fmt.Errorf("invalid blob key %q", key)
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	dest := b.path(key)
+	if _, err := os.Stat(dest); err == nil {
+		now := time.Now()
+		return os.Chtimes(dest, now, now)
+	}
+	if err := os.MkdirAll(filepath.Dir(dest), 0o755); err != nil {
+		return err
+	}
+
+	src, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer src.Close()
+	// Copy next to the blob first, so a partial copy is never served.
+	tmp, err := os.CreateTemp(filepath.Dir(dest), ".tmp-*")
+	if err != nil {
+		return err
+	}
+	_, err = io.Copy(tmp, src)
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		err = os.Rename(tmp.Name(), dest)
+	}
+	if err != nil {
+		os.Remove(tmp.Name())
+	}
+	return err
+}
+
+// Open opens the blob with the given key. A blob that was never archived or has expired
+// gives an error matching fs.ErrNotExist.
+func (b *BlobStore) Open(key string) (*os.File, error) {
+	if !validBlobKey(key) {
+		return nil, fs.ErrNotExist
+	}
+	return os.Open(b.path(key))
+}
+
+// Expire removes the blobs last uploaded before now minus the retention period, along
+// with copies abandoned half-way. It returns the number of blobs removed.
+func (b *BlobStore) Expire(now time.Time) int {
+	if b.retention <= 0 {
+		return 0
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	cutoff := now.Add(-b.retention)
+	removed := 0
+	filepath.WalkDir(b.dir, func(path string, entry fs.DirEntry, err error) error {
+		if err != nil || entry.IsDir() {
+			return nil
+		}
+		info, err := entry.Info()
+		if err != nil || !info.ModTime().Before(cutoff) {
+			return nil
+		}
+		if os.Remove(path) == nil && validBlobKey(entry.Name()) {
+			removed++
+		}
+		return nil
+	})
+	return removed
+}
+
+// Run removes expired blobs until ctx is cancelled.
+func (b *BlobStore) Run(ctx context.Context) {
+	ticker := time.NewTicker(blobSweepInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if removed := b.Expire(time.Now()); removed > 0 {
+				utils.LogInfo("BlobStore", fmt.Sprintf("Removed %d expired blobs", removed))
+			}
+		}
+	}
+}
+
+// archiveUpload stores the uploaded file at path in the blob archive and records its key
+// in upload. A failure is logged but does not stop the import, which is then recorded
+// without a blob.
+func (s *Service) archiveUpload(source, path string, upload *uploadInfo) {
+	if s.Blobs == nil {
+		return
+	}
+	if err := s.Blobs.Put(path, upload.SHA256); err != nil {
+		utils.LogError(source, "Failed to archive "+upload.Filename, err)
+		return
+	}
+	upload.Blob = upload.SHA256
+}
+
+// isJSONUpload reports whether an archived upload was sent to POST /upload/json.
+func isJSONUpload(filename string) bool {
+	name := strings.ToLower(filename)
+	return strings.HasSuffix(name, ".json") || strings.HasSuffix(name, ".ndjson")
+}
+
+// GetBlob downloads an archived upload by its SHA-256 checksum, as linked from the blob
+// field of an import.
+func (s *Service) GetBlob(ctx *gin.Context) {
+	key := ctx.Param("sha256")
+	if s.Blobs == nil {
+		ctx.JSON(http.StatusNotFound, gin.H{
+			"status":  "error",
+			"message": "Blob not found",
+		})
+		return
+	}
+	file, err := s.Blobs.Open(key)
+	if errors.Is(err, fs.ErrNotExist) {
+		ctx.JSON(http.StatusNotFound, gin.H{
+			"status":  "error",
+			"message": "Blob not found",
+		})
+		return
+	}
+	if err != nil {
+		utils.LogError("GetBlob", "Failed to open blob "+key, err)
+		ctx.JSON(http.StatusInternalServerError, gin.H{
+			"status":  "error",
+			"message": "Failed to read blob",
+		})
+		return
+	}
+	defer file.Close()
+	info, err := file.Stat()
+	if err != nil {
+		utils.LogError("GetBlob", "Failed to read blob "+key, err)
+		ctx.JSON(http.StatusInternalServerError, gin.H{
+			"status":  "error",
+			"message": "Failed to read blob",
+		})
+		return
+	}
+	ctx.DataFromReader(http.StatusOK, info.Size(), "application/octet-stream", file, nil)
+}
+
+// ReimportImport imports the archived file of an earlier import again, e.g. after a
+// mapping or validation rule was fixed. The mapping profile, dialect and import options
+// are taken from this request, with the same fields as POST /upload, not from the earlier
+// import, which is left as it is. Every table of an archive is imported again. Like
+// POST /upload it honours the Idempotency-Key header.
+func (s *Service) ReimportImport(ctx *gin.Context) {
+	s.idempotent(ctx, "ReimportImport", func() {
+		s.reimport(ctx)
+	})
+}
+
+func (s *Service) reimport(ctx *gin.Context) {
+	id := ctx.Param("id")
+	record, err := s.Repo.GetImport(id)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		utils.LogWarn("ReimportImport", "Import not found: "+id)
+		ctx.JSON(http.StatusNotFound, gin.H{
+			"status":  "error",
+			"message": "Import not found",
+		})
+		return
+	}
+	if err != nil {
+		utils.LogError("ReimportImport", "Error fetching import from database", err)
+		ctx.JSON(http.StatusInternalServerError, gin.H{
+			"status":  "error",
+			"message": "Failed to fetch import",
+		})
+		return
+	}
+	if record.Blob == "" {
+		ctx.JSON(http.StatusConflict, gin.H{
+			"status":  "error",
+			"message": "The file of this import was not archived",
+		})
+		return
+	}
+
+	request, err := s.parseUploadRequest(ctx, false)
+	if err != nil {
+		utils.LogWarn("ReimportImport", err.Error())
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	// The file was imported before; importing it again is the point.
+	request.opts.Force = true
+
+	var blob *os.File
+	if s.Blobs != nil {
+		blob, err = s.Blobs.Open(record.Blob)
+	} else {
+		err = fs.ErrNotExist
+	}
+	if errors.Is(err, fs.ErrNotExist) {
+		utils.LogWarn("ReimportImport", fmt.Sprintf("Archived file of import %s is gone", id))
+		ctx.JSON(http.StatusGone, gin.H{
+			"status":  "error",
+			"message": "Archived file is no longer available",
+		})
+		return
+	}
+	if err != nil {
+		utils.LogError("ReimportImport", "Failed to open archived file of import "+id, err)
+		ctx.JSON(http.StatusInternalServerError, gin.H{
+			"status":  "error",
+			"message": "Failed to read archived file",
+		})
+		return
+	}
+	defer blob.Close()
+
+	// Import a copy, so the blob may expire while the import runs.
+	tmp, err := os.CreateTemp(s.UploadDir, "upload-*")
+	if err != nil {
+		utils.LogError("ReimportImport", "Failed to create temporary file", err)
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to store file"})
+		return
+	}
+	upload, err := storeUpload(tmp, blob, s.uploader(ctx))
+	if err == nil && upload.SHA256 != record.Blob {
+		err = fmt.Errorf("checksum is %s", upload.SHA256)
+	}
+	if err != nil {
+		os.Remove(tmp.Name())
+		utils.LogError("ReimportImport", "Failed to copy archived file of import "+id, err)
+		ctx.JSON(http.StatusInternalServerError, gin.H{
+			"status":  "error",
+			"message": "Failed to read archived file",
+		})
+		return
+	}
+
+	filename := record.Upload
+	if filename == "" {
+		filename = record.Filename
+	}
+	utils.LogInfo("ReimportImport", fmt.Sprintf("Re-importing %s of import %s", filename, id))
+	if isJSONUpload(filename) {
+		s.importJSON(ctx, "ReimportImport", filename, tmp.Name(), upload, request.profile, request.opts)
+		return
+	}
+	s.importUpload(ctx, "ReimportImport", filename, tmp.Name(), upload, request)
+}
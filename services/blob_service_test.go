@@ -0,0 +1,171 @@
+package services
+
+import (
+	"crypto/sha256"
+	"csv-microservice/mock"
+	"csv-microservice/models"
+	"csv-microservice/utils"
+	"encoding/hex"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/assert"
+	"gorm.io/gorm"
+)
+
+// writeBlobSource writes content to a temporary file and returns its path and checksum.
+func writeBlobSource(t *testing.T, content string) (string, string) {
+	path := filepath.Join(t.TempDir(), "upload")
+	assert.NoError(t, os.WriteFile(path, []byte(content), 0644))
+	sum := sha256.Sum256([]byte(content))
+	return path, hex.EncodeToString(sum[:])
+}
+
+func TestBlobStore(t *testing.T) {
+	store, err := NewBlobStore(t.TempDir(), time.Hour)
+	assert.NoError(t, err)
+	path, key := writeBlobSource(t, "first_name,last_name,email\n")
+
+	assert.NoError(t, store.Put(path, key))
+	assert.NoError(t, store.Put(path, key), "Storing the same content again is a no-op")
+	assert.Error(t, store.Put(path, "not-a-checksum"))
+
+	file, err := store.Open(key)
+	assert.NoError(t, err)
+	content, _ := io.ReadAll(file)
+	file.Close()
+	assert.Equal(t, "first_name,last_name,email\n", string(content))
+
+	_, err = store.Open("../../etc/passwd")
+	assert.ErrorIs(t, err, os.ErrNotExist)
+
+	assert.Equal(t, 0, store.Expire(time.Now()))
+	assert.Equal(t, 1, store.Expire(time.Now().Add(2*time.Hour)))
+	_, err = store.Open(key)
+	assert.ErrorIs(t, err, os.ErrNotExist)
+}
+
+func TestReimportImport(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockRepo := mock.NewMockRepositoryInterface(ctrl)
+	service := NewService(mockRepo)
+	service.UploadDir = t.TempDir()
+	blobs, err := NewBlobStore(t.TempDir(), time.Hour)
+	assert.NoError(t, err)
+	service.Blobs = blobs
+	service.Profiles["crm"] = MappingProfile{
+		Name:     "crm",
+		Aliases:  map[string][]string{FieldEmail: {"work_mail"}},
+		Required: []string{FieldEmail},
+	}
+	utils.InitLogger()
+
+	router := gin.Default()
+	router.POST("/upload", service.UploadCSV)
+	router.POST("/imports/:id/reimport", service.ReimportImport)
+	router.GET("/blobs/:sha256", service.GetBlob)
+
+	content := "first_name,last_name,mail,work_mail\nJohn,Doe,john@home.example.com,john@example.com\n"
+	sum := sha256.Sum256([]byte(content))
+	key := hex.EncodeToString(sum[:])
+
+	waitForJob := func(body []byte) *Job {
+		var resp struct {
+			JobID string `json:"job_id"`
+		}
+		assert.NoError(t, json.Unmarshal(body, &resp))
+		job, ok := service.Jobs.Get(resp.JobID)
+		assert.True(t, ok)
+		job.Wait()
+		return job
+	}
+
+	// The first import takes the private address; its file is archived
+	var saved models.Import
+	expectNewUploads(mockRepo)
+	expectChunks(mockRepo)
+	mockRepo.EXPECT().SaveImport(gomock.Any()).DoAndReturn(func(record *models.Import) error {
+		saved = *record
+		return nil
+	}).Times(2)
+	mockRepo.EXPECT().RegisterAttributes(gomock.Any()).Return(nil).Times(2)
+	mockRepo.EXPECT().BulkInsert(importedUsers{{FirstName: "John", LastName: "Doe", Email: "john@home.example.com",
+		Attributes: models.Attributes{"work_mail": "john@example.com"}}}).Return(nil)
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, newUploadRequest(t, "/upload", "users.csv", content, nil))
+	assert.Equal(t, http.StatusAccepted, w.Code)
+	waitForJob(w.Body.Bytes())
+	assert.Equal(t, key, saved.Blob)
+	assert.Equal(t, "users.csv", saved.Upload)
+
+	w = httptest.NewRecorder()
+	req, _ := http.NewRequest(http.MethodGet, "/blobs/"+key, nil)
+	router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, content, w.Body.String())
+
+	t.Run("Reimport with another profile", func(t *testing.T) {
+		mockRepo.EXPECT().GetImport(saved.ID).Return(saved, nil)
+		mockRepo.EXPECT().SaveImport(gomock.Any()).Return(nil).AnyTimes()
+		mockRepo.EXPECT().BulkInsert(importedUsers{{FirstName: "John", LastName: "Doe", Email: "john@example.com",
+			Attributes: models.Attributes{"mail": "john@home.example.com"}}}).Return(nil)
+
+		w := httptest.NewRecorder()
+		req := newUploadRequest(t, "/imports/"+saved.ID+"/reimport", "", "", map[string]string{"profile": "crm"})
+		router.ServeHTTP(w, req)
+		assert.Equal(t, http.StatusAccepted, w.Code)
+		job := waitForJob(w.Body.Bytes())
+		assert.Equal(t, models.JobCompleted, job.Snapshot().State)
+		assert.NotEqual(t, saved.ID, job.Snapshot().ID, "The earlier import is left as it is")
+	})
+
+	tests := []struct {
+		name           string
+		record         models.Import
+		err            error
+		expectedStatus int
+		expectedBody   string
+	}{
+		{
+			name:           "Not archived",
+			record:         models.Import{ID: "abc", Filename: "users.csv", State: models.JobCompleted},
+			expectedStatus: http.StatusConflict,
+			expectedBody:   `{"status":"error","message":"The file of this import was not archived"}`,
+		},
+		{
+			name:           "Blob expired",
+			record:         models.Import{ID: "abc", Filename: "users.csv", State: models.JobCompleted, Blob: hex.EncodeToString(make([]byte, 32))},
+			expectedStatus: http.StatusGone,
+			expectedBody:   `{"status":"error","message":"Archived file is no longer available"}`,
+		},
+		{
+			name:           "Not found",
+			err:            gorm.ErrRecordNotFound,
+			expectedStatus: http.StatusNotFound,
+			expectedBody:   `{"status":"error","message":"Import not found"}`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockRepo.EXPECT().GetImport("abc").Return(tt.record, tt.err)
+
+			w := httptest.NewRecorder()
+			req, _ := http.NewRequest(http.MethodPost, "/imports/abc/reimport", nil)
+			router.ServeHTTP(w, req)
+
+			assert.Equal(t, tt.expectedStatus, w.Code)
+			assert.JSONEq(t, tt.expectedBody, w.Body.String())
+		})
+	}
+}
@@ -0,0 +1,449 @@
+package services
+
+import (
+	"bufio"
+	"context"
+	"crypto/sha256"
+	"csv-microservice/models"
+	"csv-microservice/utils"
+	"encoding/hex"
+	"fmt"
+	"hash"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// uploadOffsetHeader carries the byte offset a chunk is appended at. Responses carry the
+// offset the next chunk must use.
+const uploadOffsetHeader = "Upload-Offset"
+
+// uploadSweepInterval is how often expired chunked uploads are removed.
+const uploadSweepInterval = time.Minute
+
+// sessionFilePattern names the files chunked uploads are assembled in. A completed upload
+// is moved to an upload-* file, which belongs to its import.
+const sessionFilePattern = "session-*"
+
+// uploadSession is a chunked upload being assembled in a file.
+type uploadSession struct {
+	mu     sync.Mutex // Held while a chunk is appended or the upload is completed
+	data   models.UploadSession
+	path   string
+	hash   hash.Hash // SHA-256 of the bytes received so far
+	closed bool      // Completed, aborted or expired; the file is gone or owned by an import
+}
+
+// append writes a chunk to the end of the file. Bytes written before a read or write
+// error are kept and counted, so the client can continue from the new offset.
+func (u *uploadSession) append(r io.Reader) error {
+	file, err := os.OpenFile(u.path, os.O_WRONLY|os.O_APPEND, 0)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	buf := make([]byte, 32*1024)
+	for {
+		n, readErr := r.Read(buf)
+		if n > 0 {
+			written, err := file.Write(buf[:n])
+			u.hash.Write(buf[:written])
+			u.data.Offset += int64(written)
+			if err != nil {
+				return err
+			}
+		}
+		if readErr == io.EOF {
+			return nil
+		}
+		if readErr != nil {
+			return readErr
+		}
+	}
+}
+
+// UploadStore keeps the chunked uploads in progress. Uploads idle for longer than the TTL
+// expire and their data is removed.
+type UploadStore struct {
+	mu       sync.Mutex
+	ttl      time.Duration
+	sessions map[string]*uploadSession
+}
+
+func NewUploadStore(ttl time.Duration) *UploadStore {
+	return &UploadStore{ttl: ttl, sessions: make(map[string]*uploadSession)}
+}
+
+// Create starts an upload assembled in a new file in dir.
+func (us *UploadStore) Create(dir string, data models.UploadSession) (*uploadSession, error) {
+	file, err := os.CreateTemp(dir, sessionFilePattern)
+	if err != nil {
+		return nil, err
+	}
+	file.Close()
+
+	now := time.Now()
+	data.ID = newJobID()
+	data.Offset = 0
+	data.CreatedAt = now
+	data.ExpiresAt = now.Add(us.ttl)
+	session := &uploadSession{data: data, path: file.Name(), hash: sha256.New()}
+
+	us.mu.Lock()
+	defer us.mu.Unlock()
+	us.sessions[data.ID] = session
+	return session, nil
+}
+
+// Get looks up an upload in progress.
+func (us *UploadStore) Get(id string) (*uploadSession, bool) {
+	us.mu.Lock()
+	defer us.mu.Unlock()
+	session, ok := us.sessions[id]
+	return session, ok
+}
+
+// touch moves the expiry of an upload forward after activity.
+func (us *UploadStore) touch(session *uploadSession) {
+	session.data.ExpiresAt = time.Now().Add(us.ttl)
+}
+
+// close ends an upload. When discard is set its file is removed; otherwise the caller
+// takes the file over.
+func (us *UploadStore) close(session *uploadSession, discard bool) {
+	us.mu.Lock()
+	delete(us.sessions, session.data.ID)
+	us.mu.Unlock()
+	session.closed = true
+	if discard {
+		os.Remove(session.path)
+	}
+}
+
+// Expire removes the uploads that expired before now along with their files. Uploads
+// receiving a chunk are left for the next sweep.
+func (us *UploadStore) Expire(now time.Time) int {
+	us.mu.Lock()
+	sessions := make([]*uploadSession, 0, len(us.sessions))
+	for _, session := range us.sessions {
+		sessions = append(sessions, session)
+	}
+	us.mu.Unlock()
+
+	removed := 0
+	for _, session := range sessions {
+		if !session.mu.TryLock() {
+			continue
+		}
+		if !session.closed && session.data.ExpiresAt.Before(now) {
+			us.close(session, true)
+			removed++
+		}
+		session.mu.Unlock()
+	}
+	return removed
+}
+
+// RemoveAbandoned removes the session files in dir that belong to no upload in progress
+// and were last written more than the TTL before now. Uploads live only in memory, so the
+// files of those interrupted by a restart are left behind otherwise.
+func (us *UploadStore) RemoveAbandoned(dir string, now time.Time) (int, error) {
+	if dir == "" {
+		dir = os.TempDir() // Where os.CreateTemp puts them
+	}
+	paths, err := filepath.Glob(filepath.Join(dir, sessionFilePattern))
+	if err != nil {
+		return 0, err
+	}
+	us.mu.Lock()
+	active := make(map[string]bool, len(us.sessions))
+	for _, session := range us.sessions {
+		active[session.path] = true
+	}
+	us.mu.Unlock()
+
+	removed := 0
+	for _, path := range paths {
+		info, err := os.Stat(path)
+		if err != nil || active[path] || !info.ModTime().Before(now.Add(-us.ttl)) {
+			continue
+		}
+		if err := os.Remove(path); err != nil {
+			return removed, err
+		}
+		removed++
+	}
+	return removed, nil
+}
+
+// adoptFile moves the file of a completed upload to an upload-* file for its import, so
+// RemoveAbandoned leaves it alone.
+func adoptFile(session *uploadSession) error {
+	file, err := os.CreateTemp(filepath.Dir(session.path), "upload-*")
+	if err != nil {
+		return err
+	}
+	file.Close()
+	if err := os.Rename(session.path, file.Name()); err != nil {
+		os.Remove(file.Name())
+		return err
+	}
+	session.path = file.Name()
+	return nil
+}
+
+// Run removes expired uploads until ctx is cancelled.
+func (us *UploadStore) Run(ctx context.Context) {
+	ticker := time.NewTicker(uploadSweepInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if removed := us.Expire(time.Now()); removed > 0 {
+				utils.LogInfo("UploadStore", fmt.Sprintf("Removed %d expired uploads", removed))
+			}
+		}
+	}
+}
+
+// lockUpload looks up the upload named in the path and locks it, responding with an error
+// when it does not exist or another request holds it.
+func (s *Service) lockUpload(ctx *gin.Context) (*uploadSession, bool) {
+	id := ctx.Param("id")
+	session, ok := s.Uploads.Get(id)
+	if !ok {
+		ctx.JSON(http.StatusNotFound, gin.H{
+			"status":  "error",
+			"message": "Upload not found",
+		})
+		return nil, false
+	}
+	if !session.mu.TryLock() {
+		ctx.JSON(http.StatusConflict, gin.H{
+			"status":  "error",
+			"message": "Upload is busy with another request",
+		})
+		return nil, false
+	}
+	if session.closed {
+		session.mu.Unlock()
+		ctx.JSON(http.StatusNotFound, gin.H{
+			"status":  "error",
+			"message": "Upload not found",
+		})
+		return nil, false
+	}
+	return session, true
+}
+
+// CreateUpload starts a chunked upload for files too large for one request. The body
+// names the file and the SHA-256 checksum the assembled file must have, and may announce
+// its size. Chunks are then sent with PATCH /uploads/:id.
+func (s *Service) CreateUpload(ctx *gin.Context) {
+	var body struct {
+		Filename string `json:"filename"`
+		Size     int64  `json:"size"`
+		SHA256   string `json:"sha256"`
+	}
+	if err := ctx.ShouldBindJSON(&body); err != nil || body.Filename == "" || body.Size < 0 {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "Request body must name a filename and a sha256 checksum"})
+		return
+	}
+	checksum, err := hex.DecodeString(body.SHA256)
+	if err != nil || len(checksum) != sha256.Size {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "sha256 must be a hex encoded SHA-256 checksum"})
+		return
+	}
+	if !supportedUpload(body.Filename) {
+		utils.LogWarn("CreateUpload", "Invalid file format: "+body.Filename)
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "Only CSV files (.csv, .csv.gz, or .zip archives of CSV files) and Excel workbooks (.xlsx) are allowed."})
+		return
+	}
+
+	session, err := s.Uploads.Create(s.UploadDir, models.UploadSession{
+		Filename: body.Filename,
+		Size:     body.Size,
+		SHA256:   hex.EncodeToString(checksum),
+		Uploader: s.uploader(ctx),
+	})
+	if err != nil {
+		utils.LogError("CreateUpload", "Failed to create upload file", err)
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to store file"})
+		return
+	}
+
+	utils.LogInfo("CreateUpload", fmt.Sprintf("Started upload %s for file: %s", session.data.ID, body.Filename))
+	ctx.Header("Location", "/uploads/"+session.data.ID)
+	ctx.Header(uploadOffsetHeader, "0")
+	ctx.JSON(http.StatusCreated, gin.H{
+		"status": "success",
+		"data":   session.data,
+	})
+}
+
+// GetUpload reports how much of an upload has arrived, so an interrupted client knows
+// where to continue.
+func (s *Service) GetUpload(ctx *gin.Context) {
+	session, ok := s.lockUpload(ctx)
+	if !ok {
+		return
+	}
+	defer session.mu.Unlock()
+
+	ctx.Header(uploadOffsetHeader, strconv.FormatInt(session.data.Offset, 10))
+	ctx.JSON(http.StatusOK, gin.H{
+		"status": "success",
+		"data":   session.data,
+	})
+}
+
+// AppendUpload appends the request body to an upload. The Upload-Offset header must
+// match the bytes received so far, so a chunk sent twice is refused rather than
+// duplicated. Bytes past the announced size are never stored: a chunk whose
+// Content-Length exceeds it is refused whole, and a chunk of unknown length is cut at it.
+func (s *Service) AppendUpload(ctx *gin.Context) {
+	offset, err := strconv.ParseInt(ctx.GetHeader(uploadOffsetHeader), 10, 64)
+	if err != nil || offset < 0 {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "Missing or invalid Upload-Offset header"})
+		return
+	}
+	session, ok := s.lockUpload(ctx)
+	if !ok {
+		return
+	}
+	defer session.mu.Unlock()
+
+	if offset != session.data.Offset {
+		ctx.Header(uploadOffsetHeader, strconv.FormatInt(session.data.Offset, 10))
+		ctx.JSON(http.StatusConflict, gin.H{
+			"status":  "error",
+			"message": "Upload-Offset does not match the bytes received",
+			"offset":  session.data.Offset,
+		})
+		return
+	}
+
+	remaining := session.data.Size - session.data.Offset
+	if session.data.Size > 0 && ctx.Request.ContentLength > remaining {
+		s.refuseOversizedChunk(ctx, session)
+		return
+	}
+	var chunk io.Reader = ctx.Request.Body
+	var body *bufio.Reader
+	if session.data.Size > 0 {
+		body = bufio.NewReader(ctx.Request.Body)
+		chunk = io.LimitReader(body, remaining)
+	}
+	err = session.append(chunk)
+	s.Uploads.touch(session)
+	ctx.Header(uploadOffsetHeader, strconv.FormatInt(session.data.Offset, 10))
+	if err != nil {
+		utils.LogError("AppendUpload", "Failed to store chunk of upload "+session.data.ID, err)
+		ctx.JSON(http.StatusInternalServerError, gin.H{
+			"status":  "error",
+			"message": "Failed to store chunk",
+			"offset":  session.data.Offset,
+		})
+		return
+	}
+	if body != nil {
+		if _, err := body.Peek(1); err == nil {
+			s.refuseOversizedChunk(ctx, session)
+			return
+		}
+	}
+
+	ctx.JSON(http.StatusOK, gin.H{
+		"status": "success",
+		"data":   session.data,
+	})
+}
+
+// refuseOversizedChunk responds to a chunk that goes past the announced size of an upload.
+func (s *Service) refuseOversizedChunk(ctx *gin.Context, session *uploadSession) {
+	utils.LogWarn("AppendUpload", fmt.Sprintf("Refused data past the announced size of upload %s", session.data.ID))
+	ctx.Header(uploadOffsetHeader, strconv.FormatInt(session.data.Offset, 10))
+	ctx.JSON(http.StatusRequestEntityTooLarge, gin.H{
+		"status":  "error",
+		"message": fmt.Sprintf("Upload is larger than the announced %d bytes", session.data.Size),
+		"offset":  session.data.Offset,
+	})
+}
+
+// CompleteUpload checks the assembled file against the announced size and checksum and
+// imports it. It takes the same import options as POST /upload, e.g.
+// /uploads/:id/complete?mode=upsert_email. The upload is consumed either way, also by a
+// dry run, so a client retrying after a timeout should send an Idempotency-Key header.
+func (s *Service) CompleteUpload(ctx *gin.Context) {
+	s.idempotent(ctx, "CompleteUpload", func() {
+		s.completeUpload(ctx)
+	})
+}
+
+func (s *Service) completeUpload(ctx *gin.Context) {
+	session, ok := s.lockUpload(ctx)
+	if !ok {
+		return
+	}
+	defer session.mu.Unlock()
+
+	data := session.data
+	if data.Size > 0 && data.Offset != data.Size {
+		ctx.JSON(http.StatusConflict, gin.H{
+			"status":  "error",
+			"message": fmt.Sprintf("Upload is incomplete: received %d of %d bytes", data.Offset, data.Size),
+			"offset":  data.Offset,
+		})
+		return
+	}
+	checksum := hex.EncodeToString(session.hash.Sum(nil))
+	if checksum != data.SHA256 {
+		utils.LogWarn("CompleteUpload", fmt.Sprintf("Checksum mismatch for upload %s: expected %s, got %s", data.ID, data.SHA256, checksum))
+		ctx.JSON(http.StatusUnprocessableEntity, gin.H{
+			"status":  "error",
+			"message": "Checksum mismatch",
+			"sha256":  checksum,
+		})
+		return
+	}
+	request, err := s.parseUploadRequest(ctx, false)
+	if err != nil {
+		utils.LogWarn("CompleteUpload", err.Error())
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if err := adoptFile(session); err != nil {
+		utils.LogError("CompleteUpload", "Failed to store file", err)
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to store file"})
+		return
+	}
+	s.Uploads.close(session, false)
+	utils.LogInfo("CompleteUpload", fmt.Sprintf("Received file: %s (%d bytes in upload %s)", data.Filename, data.Offset, data.ID))
+	s.importUpload(ctx, "CompleteUpload", data.Filename, session.path, uploadInfo{Size: data.Offset, SHA256: checksum, Uploader: data.Uploader}, request)
+}
+
+// AbortUpload discards an upload and the data received so far.
+func (s *Service) AbortUpload(ctx *gin.Context) {
+	session, ok := s.lockUpload(ctx)
+	if !ok {
+		return
+	}
+	defer session.mu.Unlock()
+
+	s.Uploads.close(session, true)
+	utils.LogInfo("AbortUpload", "Aborted upload "+session.data.ID)
+	ctx.JSON(http.StatusOK, gin.H{
+		"status":  "success",
+		"message": "Upload aborted",
+	})
+}
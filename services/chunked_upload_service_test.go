@@ -0,0 +1,241 @@
+package services
+
+import (
+	"crypto/sha256"
+	"csv-microservice/mock"
+	"csv-microservice/models"
+	"csv-microservice/utils"
+	"encoding/hex"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/assert"
+)
+
+func newChunkedUploadRouter(service *Service) *gin.Engine {
+	router := gin.Default()
+	router.POST("/uploads", service.CreateUpload)
+	router.GET("/uploads/:id", service.GetUpload)
+	router.PATCH("/uploads/:id", service.AppendUpload)
+	router.DELETE("/uploads/:id", service.AbortUpload)
+	router.POST("/uploads/:id/complete", service.CompleteUpload)
+	return router
+}
+
+// createUpload starts a chunked upload of content and returns its ID.
+func createUpload(t *testing.T, router *gin.Engine, filename, content string) string {
+	sum := sha256.Sum256([]byte(content))
+	body := `{"filename":"` + filename + `","size":` + strconv.Itoa(len(content)) + `,"sha256":"` + hex.EncodeToString(sum[:]) + `"}`
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest(http.MethodPost, "/uploads", strings.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusCreated, w.Code)
+	var resp struct {
+		Data models.UploadSession `json:"data"`
+	}
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &resp))
+	assert.Equal(t, "/uploads/"+resp.Data.ID, w.Header().Get("Location"))
+	return resp.Data.ID
+}
+
+func appendChunk(router *gin.Engine, id string, offset int, chunk string) *httptest.ResponseRecorder {
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest(http.MethodPatch, "/uploads/"+id, strings.NewReader(chunk))
+	req.Header.Set("Upload-Offset", strconv.Itoa(offset))
+	router.ServeHTTP(w, req)
+	return w
+}
+
+func TestChunkedUpload(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockRepo := mock.NewMockRepositoryInterface(ctrl)
+	service := NewService(mockRepo)
+	service.UploadDir = t.TempDir()
+	utils.InitLogger()
+	router := newChunkedUploadRouter(service)
+
+	content := "first_name,last_name,email\nJohn,Doe,john@example.com\nJane,Roe,jane@example.com\n"
+	first, second := content[:30], content[30:]
+
+	t.Run("Assemble and import", func(t *testing.T) {
+		id := createUpload(t, router, "users.csv", content)
+
+		w := appendChunk(router, id, 0, first)
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Equal(t, "30", w.Header().Get("Upload-Offset"))
+
+		// A chunk sent again after a lost response is refused
+		w = appendChunk(router, id, 0, first)
+		assert.Equal(t, http.StatusConflict, w.Code)
+		assert.JSONEq(t, `{"status":"error","message":"Upload-Offset does not match the bytes received","offset":30}`, w.Body.String())
+
+		w = httptest.NewRecorder()
+		req, _ := http.NewRequest(http.MethodGet, "/uploads/"+id, nil)
+		router.ServeHTTP(w, req)
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Equal(t, "30", w.Header().Get("Upload-Offset"))
+
+		w = httptest.NewRecorder()
+		req, _ = http.NewRequest(http.MethodPost, "/uploads/"+id+"/complete", nil)
+		router.ServeHTTP(w, req)
+		assert.Equal(t, http.StatusConflict, w.Code)
+		assert.Contains(t, w.Body.String(), "Upload is incomplete: received 30 of")
+
+		w = appendChunk(router, id, 30, second)
+		assert.Equal(t, http.StatusOK, w.Code)
+
+		expectNewUploads(mockRepo)
+		var saved models.Import
+		mockRepo.EXPECT().SaveImport(gomock.Any()).DoAndReturn(func(record *models.Import) error {
+			saved = *record
+			return nil
+		}).AnyTimes()
+		mockRepo.EXPECT().BulkInsert(importedUsers{
+			{FirstName: "John", LastName: "Doe", Email: "john@example.com"},
+			{FirstName: "Jane", LastName: "Roe", Email: "jane@example.com"},
+		}).Return(nil)
+		expectChunks(mockRepo)
+
+		w = httptest.NewRecorder()
+		req, _ = http.NewRequest(http.MethodPost, "/uploads/"+id+"/complete?mode=insert", nil)
+		router.ServeHTTP(w, req)
+		assert.Equal(t, http.StatusAccepted, w.Code)
+		var resp struct {
+			JobID string `json:"job_id"`
+		}
+		assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &resp))
+		job, _ := service.Jobs.Get(resp.JobID)
+		job.Wait()
+
+		assert.Equal(t, models.JobCompleted, job.Snapshot().State)
+		assert.Equal(t, int64(len(content)), saved.Size)
+		sum := sha256.Sum256([]byte(content))
+		assert.Equal(t, hex.EncodeToString(sum[:]), saved.SHA256)
+
+		_, ok := service.Uploads.Get(id)
+		assert.False(t, ok, "A completed upload is consumed")
+	})
+
+	t.Run("Checksum mismatch", func(t *testing.T) {
+		id := createUpload(t, router, "users.csv", content)
+		appendChunk(router, id, 0, strings.ToUpper(content))
+
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest(http.MethodPost, "/uploads/"+id+"/complete", nil)
+		router.ServeHTTP(w, req)
+		assert.Equal(t, http.StatusUnprocessableEntity, w.Code)
+		assert.Contains(t, w.Body.String(), `"message":"Checksum mismatch"`)
+	})
+
+	t.Run("Too much data", func(t *testing.T) {
+		id := createUpload(t, router, "users.csv", content)
+
+		w := appendChunk(router, id, 0, content+"extra")
+		assert.Equal(t, http.StatusRequestEntityTooLarge, w.Code)
+		assert.Equal(t, "0", w.Header().Get("Upload-Offset"), "Nothing of the chunk is stored")
+		session, _ := service.Uploads.Get(id)
+		assert.Equal(t, int64(0), session.data.Offset)
+
+		w = appendChunk(router, id, 0, content)
+		assert.Equal(t, http.StatusOK, w.Code, "The upload can go on")
+	})
+
+	t.Run("Too much data of unknown length", func(t *testing.T) {
+		id := createUpload(t, router, "users.csv", content)
+
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest(http.MethodPatch, "/uploads/"+id, strings.NewReader(content+"extra"))
+		req.ContentLength = -1
+		req.Header.Set("Upload-Offset", "0")
+		router.ServeHTTP(w, req)
+		assert.Equal(t, http.StatusRequestEntityTooLarge, w.Code)
+		assert.Equal(t, strconv.Itoa(len(content)), w.Header().Get("Upload-Offset"), "Only the bytes up to the announced size are stored")
+	})
+
+	t.Run("Abort", func(t *testing.T) {
+		id := createUpload(t, router, "users.csv", content)
+		session, _ := service.Uploads.Get(id)
+
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest(http.MethodDelete, "/uploads/"+id, nil)
+		router.ServeHTTP(w, req)
+		assert.Equal(t, http.StatusOK, w.Code)
+		_, err := os.Stat(session.path)
+		assert.True(t, os.IsNotExist(err))
+
+		w = appendChunk(router, id, 0, first)
+		assert.Equal(t, http.StatusNotFound, w.Code)
+		assert.JSONEq(t, `{"status":"error","message":"Upload not found"}`, w.Body.String())
+	})
+
+	t.Run("Invalid requests", func(t *testing.T) {
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest(http.MethodPost, "/uploads", strings.NewReader(`{"filename":"users.csv","sha256":"abc"}`))
+		req.Header.Set("Content-Type", "application/json")
+		router.ServeHTTP(w, req)
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+		assert.JSONEq(t, `{"error":"sha256 must be a hex encoded SHA-256 checksum"}`, w.Body.String())
+
+		id := createUpload(t, router, "users.csv", content)
+		w = httptest.NewRecorder()
+		req, _ = http.NewRequest(http.MethodPatch, "/uploads/"+id, strings.NewReader(first))
+		router.ServeHTTP(w, req)
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+		assert.JSONEq(t, `{"error":"Missing or invalid Upload-Offset header"}`, w.Body.String())
+	})
+}
+
+func TestUploadStore_Expire(t *testing.T) {
+	store := NewUploadStore(time.Hour)
+	session, err := store.Create(t.TempDir(), models.UploadSession{Filename: "users.csv"})
+	assert.NoError(t, err)
+
+	assert.Equal(t, 0, store.Expire(time.Now()))
+	_, ok := store.Get(session.data.ID)
+	assert.True(t, ok)
+
+	assert.Equal(t, 1, store.Expire(time.Now().Add(2*time.Hour)))
+	_, ok = store.Get(session.data.ID)
+	assert.False(t, ok)
+	_, err = os.Stat(session.path)
+	assert.True(t, os.IsNotExist(err), "The partial file is removed")
+}
+
+func TestUploadStore_RemoveAbandoned(t *testing.T) {
+	dir := t.TempDir()
+	store := NewUploadStore(time.Hour)
+	session, err := store.Create(dir, models.UploadSession{Filename: "users.csv"})
+	assert.NoError(t, err)
+
+	old := time.Now().Add(-2 * time.Hour)
+	abandoned := filepath.Join(dir, "session-1")
+	recent := filepath.Join(dir, "session-2")
+	imported := filepath.Join(dir, "upload-1")
+	for _, path := range []string{abandoned, recent, imported} {
+		assert.NoError(t, os.WriteFile(path, []byte("id\n"), 0644))
+	}
+	for _, path := range []string{abandoned, imported, session.path} {
+		assert.NoError(t, os.Chtimes(path, old, old))
+	}
+
+	removed, err := store.RemoveAbandoned(dir, time.Now())
+	assert.NoError(t, err)
+	assert.Equal(t, 1, removed)
+	assert.NoFileExists(t, abandoned)
+	assert.FileExists(t, recent, "a recent file may belong to another instance")
+	assert.FileExists(t, imported, "the upload of an import is kept")
+	assert.FileExists(t, session.path, "an upload in progress is kept")
+}
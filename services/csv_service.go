@@ -1,17 +1,20 @@
 package services
 
 import (
+	"context"
 	"csv-microservice/models"
 	repository "csv-microservice/repositories"
 	"csv-microservice/utils"
-	"encoding/csv"
+	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"net/http"
-	"path/filepath"
+	"os"
 	"strconv"
 	"strings"
 	"sync"
+	"time"
 
 	"github.com/gin-gonic/gin"
 	"github.com/sirupsen/logrus"
@@ -26,12 +29,54 @@ type ServiceInterface interface {
 	QueryUpdates(ctx *gin.Context)
 	AddRecord(ctx *gin.Context)
 	DeleteRecord(ctx *gin.Context)
+	GetJob(ctx *gin.Context)
+	ListJobs(ctx *gin.Context)
+	GetJobErrors(ctx *gin.Context)
+	ValidateCSV(ctx *gin.Context)
+	UploadJSON(ctx *gin.Context)
+	ListImports(ctx *gin.Context)
+	GetImport(ctx *gin.Context)
+	RevertImport(ctx *gin.Context)
+	ListQuarantine(ctx *gin.Context)
+	UpdateQuarantinedRow(ctx *gin.Context)
+	ResubmitQuarantinedRow(ctx *gin.Context)
+	ResubmitQuarantine(ctx *gin.Context)
+	CancelJob(ctx *gin.Context)
+	ResumeImport(ctx *gin.Context)
+	DiffImport(ctx *gin.Context)
+	SyncCSV(ctx *gin.Context)
+	ApproveImport(ctx *gin.Context)
+	RejectImport(ctx *gin.Context)
+	ReimportImport(ctx *gin.Context)
+	GetBlob(ctx *gin.Context)
+	ListAttributes(ctx *gin.Context)
+	CreateUpload(ctx *gin.Context)
+	GetUpload(ctx *gin.Context)
+	AppendUpload(ctx *gin.Context)
+	CompleteUpload(ctx *gin.Context)
+	AbortUpload(ctx *gin.Context)
 	// GetLogs(ctx *gin.Context)
 }
 
 // Implement ServiceInterface
 type Service struct {
-	Repo repository.RepositoryInterface
+	Repo       repository.RepositoryInterface
+	Jobs       *JobStore
+	Profiles   map[string]MappingProfile // Named CSV mapping profiles selectable per upload
+	Transforms []TransformRule           // Applied to every imported row before it is parsed
+	UploadDir  string                    // Where uploads are stored while imported; empty for the temp directory
+	Uploads    *UploadStore              // Chunked uploads being assembled
+	Blobs      *BlobStore                // Archive of uploaded files for re-imports; nil disables it
+	// How long uploads of imports that did not complete are kept for resuming; 0 keeps them forever
+	ResumeRetention time.Duration
+	// Names of the people who approve staged imports, by API token; empty to identify
+	// reviewers like uploaders
+	Reviewers map[string]string
+
+	Scheduler     *Scheduler // Runs the chunks of every import on a shared pool of workers
+	BatchSize     int        // Rows per chunk written with INSERT
+	CopyBatchSize int        // Rows per chunk written with COPY
+	keys          *keyLocks  // Held by chunks that look up users before writing them
 }
 
 var db *gorm.DB
@@ -40,108 +85,806 @@ var logs = logrus.New()
 // Initialize PostgreSQL DB connection (Using GORM)
 func InitDatabase(database *gorm.DB) {
 	db = database
-	db.AutoMigrate(&models.User{})
+	err := db.AutoMigrate(&models.User{}, &models.Import{}, &models.ImportChange{}, &models.ImportChunk{}, &models.QuarantinedRow{}, &models.IdempotencyKey{}, &models.StagedRow{}, &models.Attribute{})
+	if err != nil {
+		// E.g. the unique index on users.email while duplicate emails are stored
+		logs.Errorf("Failed to migrate database: %v", err)
+	}
 }
 
 func NewService(repo repository.RepositoryInterface) *Service {
-	return &Service{Repo: repo}
+	return &Service{
+		Repo:     repo,
+		Jobs:     NewJobStore(),
+		Uploads:  NewUploadStore(24 * time.Hour),
+		Profiles: map[string]MappingProfile{DefaultMappingProfile.Name: DefaultMappingProfile},
+
+		Scheduler:     NewScheduler(defaultWorkers),
+		BatchSize:     insertBatchSize,
+		CopyBatchSize: copyBatchSize,
+		keys:          newKeyLocks(),
+	}
+}
+
+// csvRow is a raw CSV record together with its line number in the source file.
+type csvRow struct {
+	Line   int
+	Values []string
+}
+
+// importChunk is a run of consecutive data rows that one worker parses and writes as a
+// single batch.
+type importChunk struct {
+	offset int // Index of the first data row, counted from 0 after the header
+	rows   []csvRow
+	unread []models.RowError // Rows of the chunk the reader could not read
+}
+
+// size counts the data rows the chunk covers.
+func (c importChunk) size() int {
+	return len(c.rows) + len(c.unread)
+}
+
+// pendingRow is a parsed record waiting in a batch for insertion.
+type pendingRow struct {
+	row     csvRow
+	record  models.UserRecord
+	changes []TransformChange // Made by the transform rules; only kept for a dry run
+}
+
+// importOptions controls how an upload is written to the database.
+type importOptions struct {
+	Mode        string // Conflict policy, one of the models.Mode* constants
+	Merge       bool   // On update, only overwrite columns whose cell is non-empty
+	Transaction string // models.TxBestEffort or models.TxAtomic
+	Loader      string // models.LoaderGorm or models.LoaderCopy
+	DryRun      bool   // Parse and validate only; nothing is written to the database
+	Preview     int    // Number of parsed records returned by a dry run
+	Locale      string // How numbers, booleans and dates are written, one of the Locale* constants
+	Force       bool   // Import a file even if the same content was imported before
+	Stage       bool   // Load into the staging area and wait for approval instead of writing users
+	Missing     string // What applying a staged import does with users missing from the file, see models.MissingKeep
 }
 
-// CSV Upload and Parsing using Goroutines
-func processRecords(recordChan <-chan []string, batchSize int, s *Service, wg *sync.WaitGroup) {
-	defer wg.Done()
-	var batch []models.User
+var defaultImportOptions = importOptions{Mode: models.ModeInsert, Transaction: models.TxBestEffort, Loader: models.LoaderGorm, Preview: 10, Locale: LocaleUS, Missing: models.MissingKeep}
 
-	for record := range recordChan {
-		if len(record) < 11 {
-			logs.Warn("Skipping malformed record: ", record)
+// Default batch sizes per loader. COPY pays off with much larger batches than INSERT.
+const (
+	insertBatchSize = 100
+	copyBatchSize   = 5000
+)
+
+// importTask bundles what the workers need to process one upload.
+type importTask struct {
+	job     *Job
+	source  importSource
+	columns columnMap
+	parser  fieldParser
+	opts    importOptions
+	ctx     context.Context
+	cancel  context.CancelFunc // Stops reading and writing, e.g. after the first error of an atomic import
+
+	upload     uploadInfo
+	importID   *string              // Stored on every inserted user; nil for a dry run
+	quarantine bool                 // Store rejected rows for editing and resubmission
+	resume     *resumeState         // How to reopen the upload; nil when it is not kept
+	committed  []models.ImportChunk // Chunks an earlier run committed, skipped when reading
+	// Lines an earlier run already quarantined, which are not stored again
+	quarantined map[int]bool
+
+	unquarantinedMu sync.Mutex
+	unquarantined   []models.RowError // Rejected rows not yet stored in the quarantine
+
+	previewMu         sync.Mutex
+	preview           []models.User      // First parsed records of a dry run
+	previewTransforms []rowTransforms    // Changes the transform rules made to the previewed records
+	planned           models.WriteResult // What a dry run would write
+	plannedKeys       map[string]bool    // Keys of the users a dry run would insert
+}
+
+func newImportTask(job *Job, columns columnMap, opts importOptions, source importSource) *importTask {
+	ctx, cancel := context.WithCancel(context.Background())
+	parser := parserFor(opts.Locale)
+	if source.typed {
+		parser = parser.typed()
+	}
+	task := &importTask{job: job, source: source, columns: columns, parser: parser, opts: opts, ctx: ctx, cancel: cancel}
+	job.mu.Lock()
+	job.stop = cancel
+	job.mu.Unlock()
+	if !opts.DryRun {
+		id := job.Snapshot().ID
+		task.importID = &id
+		// A rolled back atomic import is fixed and uploaded again as a whole, and so is a
+		// staged one, whose quarantined rows would bypass the approval.
+		task.quarantine = opts.Transaction != models.TxAtomic && !opts.Stage
+	}
+	return task
+}
+
+// reject records a failed row and returns it. Atomic imports stop at the first one; dry
+// runs report them all.
+func (t *importTask) reject(line int, values []string, reason string) models.RowError {
+	t.job.rejectRow(line, values, reason)
+	if t.rollsBack() {
+		t.cancel()
+	}
+	rowErr := models.RowError{Line: line, Values: values, Reason: reason}
+	if t.quarantine && !t.quarantined[line] {
+		t.unquarantinedMu.Lock()
+		t.unquarantined = append(t.unquarantined, rowErr)
+		t.unquarantinedMu.Unlock()
+	}
+	return rowErr
+}
+
+// rollsBack reports whether a failed row aborts the whole import.
+func (t *importTask) rollsBack() bool {
+	return t.opts.Transaction == models.TxAtomic && !t.opts.DryRun
+}
+
+// resumable reports whether the import commits chunk by chunk, so it can be resumed after
+// an interruption. Atomic imports commit all rows at once or none.
+func (t *importTask) resumable() bool {
+	return t.opts.Transaction != models.TxAtomic && !t.opts.DryRun
+}
+
+// processChunk parses the rows of a chunk and writes them. It runs on a scheduler worker.
+func processChunk(chunk importChunk, s *Service, task *importTask) {
+	columns := task.columns
+	if task.ctx.Err() != nil {
+		return // Import stopped; drop the chunks still queued
+	}
+
+	var batch []pendingRow
+	rejected := chunk.unread
+	for _, record := range chunk.rows {
+		if len(record.Values) < columns.columns {
+			logs.Warn("Skipping malformed record: ", record.Values)
+			rejected = append(rejected, task.reject(record.Line, record.Values, fmt.Sprintf("too few columns: expected %d, got %d", columns.columns, len(record.Values))))
+			continue
+		}
+		values, changes := transformRecord(s.Transforms, record.Values, columns, task.opts.DryRun)
+		recordData, err := buildUser(values, columns, task.parser)
+		if err != nil {
+			logs.Warn("Skipping invalid record: ", record.Values)
+			rejected = append(rejected, task.reject(record.Line, record.Values, err.Error()))
 			continue
 		}
-		recordData := models.User{
-			Id:         parseInt(record[0]),
-			FirstName:  record[1],
-			LastName:   record[2],
-			Email:      record[3],
-			Age:        parseInt(record[4]),
-			Gender:     record[5],
-			Department: record[6],
-			Company:    record[7],
-			Salary:     parseFloat(record[8]),
-			DateJoined: record[9],
-			IsActive:   parseBool(record[10]),
-		}
-
-		batch = append(batch, recordData)
-
-		// Insert batch when size limit is reached
-		if len(batch) >= batchSize {
-			if err := s.Repo.BulkInsert(batch); err != nil {
-				logs.Error("Error during batch insertion: ", err)
+		recordData.ImportID = task.importID
+
+		batch = append(batch, pendingRow{
+			row:     record,
+			record:  models.UserRecord{User: recordData, Fields: columns.presentFields(values)},
+			changes: changes,
+		})
+	}
+
+	// A cancelled import still writes the chunk it has parsed; a failed atomic one is rolled back anyway.
+	if task.rollsBack() && task.ctx.Err() != nil {
+		return
+	}
+	s.writeChunk(chunk, batch, rejected, task)
+	if task.quarantine {
+		s.quarantineRows(task, false)
+	}
+}
+
+// rowFailure is a parsed row the database refused.
+type rowFailure struct {
+	row    csvRow
+	reason string
+}
+
+// writeChunk writes the parsed rows of a chunk. For resumable imports the rows and the
+// chunk record commit in one transaction, COPY included, so a resumed import skips
+// exactly the chunks that went in. rejected holds the rows of the chunk that were already
+// refused, which are stored with the chunk record.
+func (s *Service) writeChunk(chunk importChunk, batch []pendingRow, rejected []models.RowError, task *importTask) {
+	job := task.job
+	if task.opts.DryRun {
+		task.addPreview(batch)
+		s.planBatch(batch, task)
+		return
+	}
+	if !task.resumable() {
+		result, failures := s.writeBatch(batch, task.opts)
+		job.addResult(result)
+		for _, failure := range failures {
+			task.reject(failure.row.Line, failure.row.Values, failure.reason)
+		}
+		return
+	}
+
+	if locksKeys(task.opts) {
+		unlock := s.keys.lock(conflictKeys(batch, task.opts.Mode))
+		defer unlock()
+	}
+	record := models.ImportChunk{ImportID: *task.importID, Offset: chunk.offset, Rows: chunk.size()}
+	var result models.WriteResult
+	var failures []rowFailure
+	err := s.Repo.WriteChunk(&record, func(repo repository.RepositoryInterface) error {
+		txService := *s
+		txService.Repo = repo
+		result, failures = txService.writeBatch(batch, task.opts)
+		record.Inserted, record.Updated, record.Skipped = result.Inserted, result.Updated, result.Skipped
+		record.Errors = rejected
+		for _, failure := range failures {
+			record.Errors = append(record.Errors, models.RowError{Line: failure.row.Line, Values: failure.row.Values, Reason: failure.reason})
+		}
+		record.Failed = len(record.Errors)
+		return nil
+	})
+	if err != nil {
+		// Nothing of the chunk was committed, so a resume tries it again.
+		logs.Error("Error committing chunk: ", err)
+		result, failures = models.WriteResult{}, nil
+		for _, pending := range batch {
+			failures = append(failures, rowFailure{row: pending.row, reason: "database error: " + err.Error()})
+		}
+	} else {
+		job.commitChunk(record.Offset, record.Rows)
+	}
+	job.addResult(result)
+	for _, failure := range failures {
+		task.reject(failure.row.Line, failure.row.Values, failure.reason)
+	}
+}
+
+// planBatch counts what writing a batch would do, for a dry run. Stored users are looked
+// up read-only under the import's mode. A user an earlier row of the file would insert
+// counts as stored, since the import would have written it by then.
+func (s *Service) planBatch(batch []pendingRow, task *importTask) {
+	mode := task.opts.Mode
+	found := make([]bool, len(batch))
+	if mode != models.ModeInsert {
+		records := make([]models.UserRecord, len(batch))
+		for i, pending := range batch {
+			records[i] = pending.record
+		}
+		var err error
+		found, err = s.Repo.FindExistingUsers(records, mode)
+		if err != nil {
+			logs.Error("Error looking up existing users: ", err)
+			for _, pending := range batch {
+				task.reject(pending.row.Line, pending.row.Values, "database error: "+err.Error())
 			}
-			batch = batch[:0] // Clear the batch
+			return
 		}
 	}
 
-	// Insert remaining records
-	if len(batch) > 0 {
-		if err := s.Repo.BulkInsert(batch); err != nil {
-			logs.Error("Error during final batch insertion: ", err)
+	task.previewMu.Lock()
+	defer task.previewMu.Unlock()
+	if task.plannedKeys == nil {
+		task.plannedKeys = make(map[string]bool)
+	}
+	for i, pending := range batch {
+		key := conflictKey(pending.record.User, mode)
+		switch {
+		case !found[i] && (key == "" || !task.plannedKeys[key]):
+			task.planned.Inserted++
+			if key != "" {
+				task.plannedKeys[key] = true
+			}
+		case mode == models.ModeSkipExisting:
+			task.planned.Skipped++
+		default:
+			task.planned.Updated++
 		}
 	}
 }
 
+// writeBatch writes a batch in one call. If that fails the rows are retried one by one,
+// so only the rows the database actually refuses are returned as failed.
+func (s *Service) writeBatch(batch []pendingRow, opts importOptions) (models.WriteResult, []rowFailure) {
+	if len(batch) == 0 {
+		return models.WriteResult{}, nil
+	}
+	records := make([]models.UserRecord, len(batch))
+	for i, pending := range batch {
+		records[i] = pending.record
+	}
+	result, err := s.write(records, opts)
+	if err == nil {
+		return result, nil
+	}
+	logs.Error("Error during batch insertion, retrying rows individually: ", err)
+
+	// Single rows are retried with plain inserts; a COPY per row would only be slower.
+	rowOpts := opts
+	rowOpts.Loader = models.LoaderGorm
+	var total models.WriteResult
+	var failures []rowFailure
+	for _, pending := range batch {
+		result, err := s.write([]models.UserRecord{pending.record}, rowOpts)
+		if err != nil {
+			failures = append(failures, rowFailure{row: pending.row, reason: "database error: " + err.Error()})
+			continue
+		}
+		total.Inserted += result.Inserted
+		total.Updated += result.Updated
+		total.Skipped += result.Skipped
+	}
+	return total, failures
+}
+
+// write stores records using the conflict policy. Plain inserts go through BulkInsert,
+// or CopyInsert when the COPY loader is selected. Staged imports write to the staging
+// area, so nothing counts as written yet.
+func (s *Service) write(records []models.UserRecord, opts importOptions) (models.WriteResult, error) {
+	if opts.Stage {
+		return models.WriteResult{}, s.Repo.StageRows(stagedRows(records, opts), opts.Mode)
+	}
+	if opts.Mode != models.ModeInsert {
+		return s.Repo.UpsertBatch(records, opts.Mode, opts.Merge)
+	}
+
+	users := make([]models.User, len(records))
+	for i, record := range records {
+		users[i] = record.User
+	}
+	if opts.Loader == models.LoaderCopy {
+		copied, err := s.Repo.CopyInsert(context.Background(), users)
+		if err != nil {
+			return models.WriteResult{}, err
+		}
+		return models.WriteResult{Inserted: int(copied)}, nil
+	}
+	if err := s.Repo.BulkInsert(users); err != nil {
+		return models.WriteResult{}, err
+	}
+	return models.WriteResult{Inserted: len(users)}, nil
+}
+
+// parseImportOptions reads the "mode", "merge", "transaction", "loader", "locale", "force",
+// "stage", "missing", "dry_run" and "preview" fields. dry_run and preview may also be given as query parameters.
+func parseImportOptions(ctx *gin.Context) (importOptions, error) {
+	return overrideImportOptions(ctx, defaultImportOptions)
+}
+
+// overrideImportOptions reads the fields parseImportOptions reads over opts.
+func overrideImportOptions(ctx *gin.Context, opts importOptions) (importOptions, error) {
+	opts.Mode = importParam(ctx, "mode", opts.Mode)
+	switch opts.Mode {
+	case models.ModeInsert, models.ModeUpsertID, models.ModeUpsertEmail, models.ModeSkipExisting:
+	default:
+		return importOptions{}, fmt.Errorf("invalid mode %q: expected one of %s, %s, %s, %s", opts.Mode,
+			models.ModeInsert, models.ModeUpsertID, models.ModeUpsertEmail, models.ModeSkipExisting)
+	}
+
+	if merge := importParam(ctx, "merge", ""); merge != "" {
+		parsed, err := strconv.ParseBool(merge)
+		if err != nil {
+			return importOptions{}, fmt.Errorf("invalid merge value %q", merge)
+		}
+		opts.Merge = parsed
+	}
+
+	opts.Transaction = importParam(ctx, "transaction", opts.Transaction)
+	if opts.Transaction != models.TxBestEffort && opts.Transaction != models.TxAtomic {
+		return importOptions{}, fmt.Errorf("invalid transaction %q: expected %s or %s", opts.Transaction, models.TxBestEffort, models.TxAtomic)
+	}
+
+	opts.Loader = importParam(ctx, "loader", opts.Loader)
+	switch {
+	case opts.Loader != models.LoaderGorm && opts.Loader != models.LoaderCopy:
+		return importOptions{}, fmt.Errorf("invalid loader %q: expected %s or %s", opts.Loader, models.LoaderGorm, models.LoaderCopy)
+	case opts.Loader == models.LoaderCopy && opts.Mode != models.ModeInsert:
+		return importOptions{}, fmt.Errorf("loader %s only supports mode %s", models.LoaderCopy, models.ModeInsert)
+	case opts.Loader == models.LoaderCopy && opts.Transaction == models.TxAtomic:
+		return importOptions{}, fmt.Errorf("loader %s does not support %s transactions", models.LoaderCopy, models.TxAtomic)
+	}
+
+	opts.Locale = importParam(ctx, "locale", opts.Locale)
+	if err := validateLocale(opts.Locale); err != nil {
+		return importOptions{}, err
+	}
+
+	if force := importParam(ctx, "force", ""); force != "" {
+		parsed, err := strconv.ParseBool(force)
+		if err != nil {
+			return importOptions{}, fmt.Errorf("invalid force value %q", force)
+		}
+		opts.Force = parsed
+	}
+
+	if stage := importParam(ctx, "stage", ""); stage != "" {
+		parsed, err := strconv.ParseBool(stage)
+		if err != nil {
+			return importOptions{}, fmt.Errorf("invalid stage value %q", stage)
+		}
+		opts.Stage = parsed
+	}
+	if opts.Stage && opts.Mode != models.ModeUpsertID && opts.Mode != models.ModeUpsertEmail {
+		return importOptions{}, fmt.Errorf("stage requires mode %s or %s to match rows with users", models.ModeUpsertID, models.ModeUpsertEmail)
+	}
+	opts.Missing = importParam(ctx, "missing", opts.Missing)
+	switch opts.Missing {
+	case models.MissingKeep, models.MissingDelete, models.MissingDeactivate:
+	default:
+		return importOptions{}, fmt.Errorf("invalid missing %q: expected %s, %s or %s", opts.Missing, models.MissingKeep, models.MissingDelete, models.MissingDeactivate)
+	}
+
+	if dryRun := ctx.DefaultQuery("dry_run", ctx.PostForm("dry_run")); dryRun != "" {
+		parsed, err := strconv.ParseBool(dryRun)
+		if err != nil {
+			return importOptions{}, fmt.Errorf("invalid dry_run value %q", dryRun)
+		}
+		opts.DryRun = parsed
+	}
+	if preview := ctx.DefaultQuery("preview", ctx.PostForm("preview")); preview != "" {
+		parsed, err := strconv.Atoi(preview)
+		if err != nil || parsed < 0 || parsed > 1000 {
+			return importOptions{}, fmt.Errorf("invalid preview value %q: expected 0 to 1000", preview)
+		}
+		opts.Preview = parsed
+	}
+	return opts, nil
+}
+
+// importParam reads an import option from the form, falling back to the query string
+// for request bodies that are not forms.
+func importParam(ctx *gin.Context, name, defaultValue string) string {
+	if value, ok := ctx.GetPostForm(name); ok {
+		return value
+	}
+	return ctx.DefaultQuery(name, defaultValue)
+}
+
+// mappingProfile picks the mapping profile for an upload: an inline "mapping" form
+// field (JSON) takes precedence over a named "profile", which defaults to "default".
+func (s *Service) mappingProfile(ctx *gin.Context) (MappingProfile, error) {
+	if inline := ctx.PostForm("mapping"); inline != "" {
+		var profile MappingProfile
+		if err := json.Unmarshal([]byte(inline), &profile); err != nil {
+			return MappingProfile{}, fmt.Errorf("invalid mapping: %w", err)
+		}
+		if profile.Name == "" {
+			profile.Name = "inline"
+		}
+		if err := validateProfile(profile); err != nil {
+			return MappingProfile{}, err
+		}
+		return profile, nil
+	}
+
+	name := ctx.DefaultPostForm("profile", DefaultMappingProfile.Name)
+	profile, ok := s.Profiles[name]
+	if !ok {
+		return MappingProfile{}, fmt.Errorf("unknown mapping profile %q", name)
+	}
+	return profile, nil
+}
+
+// UploadCSV queues an uploaded file for import. A retry sent with the same
+// Idempotency-Key header gets the response of the first upload.
 func (s *Service) UploadCSV(ctx *gin.Context) {
+	s.idempotent(ctx, "UploadCSV", func() {
+		s.handleUpload(ctx, "UploadCSV", false)
+	})
+}
+
+// handleUpload stores and checks an uploaded CSV file, then either validates it on the
+// spot (dry run) or queues it as a background import job. Gzip files are decompressed
+// on the fly and every CSV inside a zip archive becomes its own job.
+func (s *Service) handleUpload(ctx *gin.Context, source string, dryRun bool) {
 	file, header, err := ctx.Request.FormFile("file")
 	if err != nil {
-		utils.LogError("UploadCSV", "Failed to get file", err)
+		utils.LogError(source, "Failed to get file", err)
 		ctx.JSON(http.StatusBadRequest, gin.H{"error": "Failed to get file"})
 		return
 	}
 	defer file.Close()
-	utils.LogInfo("UploadCSV", "Received file: "+header.Filename)
+	utils.LogInfo(source, "Received file: "+header.Filename)
 
-	if filepath.Ext(header.Filename) != ".csv" {
-		utils.LogWarn("UploadCSV", "Invalid file format: "+header.Filename)
-		ctx.JSON(http.StatusBadRequest, gin.H{"error": "Only CSV files are allowed."})
+	if !supportedUpload(header.Filename) {
+		utils.LogWarn(source, "Invalid file format: "+header.Filename)
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "Only CSV files (.csv, .csv.gz, or .zip archives of CSV files) and Excel workbooks (.xlsx) are allowed."})
+		return
+	}
+
+	request, err := s.parseUploadRequest(ctx, dryRun)
+	if err != nil {
+		utils.LogWarn(source, err.Error())
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	path, upload, ok := s.saveUploadedFile(ctx, source, file)
+	if !ok {
+		return
+	}
+	s.importUpload(ctx, source, header.Filename, path, upload, request)
+}
+
+// saveUploadedFile stores a copy of an uploaded multipart file, which is removed once the
+// request ends, and returns its path. It responds with an error when that fails.
+func (s *Service) saveUploadedFile(ctx *gin.Context, source string, file io.Reader) (string, uploadInfo, bool) {
+	tmp, err := os.CreateTemp(s.UploadDir, "upload-*")
+	if err != nil {
+		utils.LogError(source, "Failed to create temporary file", err)
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to store file"})
+		return "", uploadInfo{}, false
+	}
+	upload, err := storeUpload(tmp, file, s.uploader(ctx))
+	if err != nil {
+		os.Remove(tmp.Name())
+		utils.LogError(source, "Failed to store uploaded file", err)
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to store file"})
+		return "", uploadInfo{}, false
+	}
+	return tmp.Name(), upload, true
+}
+
+// uploadRequest holds the import settings sent along with an upload.
+type uploadRequest struct {
+	profile MappingProfile
+	opts    importOptions
+	dialect csvDialect
+	sheet   string
+}
+
+// parseUploadRequest reads the import settings of an upload from the form or query string.
+func (s *Service) parseUploadRequest(ctx *gin.Context, dryRun bool) (uploadRequest, error) {
+	profile, err := s.mappingProfile(ctx)
+	if err != nil {
+		return uploadRequest{}, err
+	}
+	opts, err := parseImportOptions(ctx)
+	if err != nil {
+		return uploadRequest{}, err
+	}
+	opts.DryRun = opts.DryRun || dryRun
+	dialect, err := parseDialect(ctx)
+	if err != nil {
+		return uploadRequest{}, err
+	}
+	return uploadRequest{profile: profile, opts: opts, dialect: dialect, sheet: ctx.PostForm("sheet")}, nil
+}
+
+// importUpload starts the import of an upload stored at path, or validates it for a dry
+// run, and responds. It takes over the stored file and removes it when it is no longer
+// needed.
+func (s *Service) importUpload(ctx *gin.Context, source, filename, path string, upload uploadInfo, request uploadRequest) {
+	profile, opts, dialect := request.profile, request.opts, request.dialect
+	if s.duplicateUpload(ctx, source, upload, opts) {
+		os.Remove(path)
+		return
+	}
+	sources, err := uploadSources(path, filename, request.sheet, dialect)
+	if err != nil {
+		os.Remove(path)
+		utils.LogWarn(source, fmt.Sprintf("Rejected file %s: %s", filename, err.Error()))
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "Invalid upload: " + err.Error()})
+		return
+	}
+
+	upload.Filename = filename
+	if !opts.DryRun {
+		s.archiveUpload(source, path, &upload)
+	}
+
+	// Check the headers before accepting the upload so mapping problems are reported immediately.
+	var tasks []*importTask
+	rejected := []gin.H{}
+	for _, src := range sources {
+		columns, err := resolveSourceColumns(src, profile)
+		if err != nil {
+			utils.LogWarn(source, fmt.Sprintf("Rejected file %s: %s", src.Name, err.Error()))
+			rejected = append(rejected, gin.H{"file": src.Name, "error": "Invalid CSV header: " + err.Error()})
+			continue
+		}
+		job := newJob(src.Name, opts)
+		if !opts.DryRun {
+			job = s.Jobs.Create(src.Name, opts)
+		}
+		task := newImportTask(job, columns, opts, src)
+		task.upload = upload
+		task.resume = &resumeState{
+			Path:     path,
+			Filename: filename,
+			Source:   src.Name,
+			Sheet:    request.sheet,
+			Dialect:  dialect,
+			Profile:  profile,
+			Options:  opts,
+			Shared:   len(sources) > 1,
+		}
+		tasks = append(tasks, task)
+	}
+	archive := isArchive(filename)
+	if len(tasks) == 0 {
+		os.Remove(path)
+		if !archive {
+			ctx.JSON(http.StatusBadRequest, gin.H{"error": rejected[0]["error"]})
+			return
+		}
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "No importable CSV files in archive", "rejected": rejected})
+		return
+	}
+
+	if opts.DryRun {
+		defer os.Remove(path)
+		s.validateUpload(ctx, tasks, rejected, archive)
 		return
 	}
 
-	csvReader := csv.NewReader(file)
-	recordChan := make(chan []string, 1000)
 	var wg sync.WaitGroup
-	numWorkers := 10
-	batchSize := 100 // Set batch size for bulk insertion
+	jobs := make([]gin.H, len(tasks))
+	for i, task := range tasks {
+		snapshot := task.job.Snapshot()
+		jobs[i] = gin.H{"file": snapshot.Filename, "job_id": snapshot.ID}
+		utils.LogInfo(source, fmt.Sprintf("Queued import job %s for file: %s", snapshot.ID, snapshot.Filename))
 
-	// Start worker goroutines
-	for i := 0; i < numWorkers; i++ {
 		wg.Add(1)
-		go processRecords(recordChan, batchSize, s, &wg)
+		go func(task *importTask) {
+			defer wg.Done()
+			s.runImport(task)
+		}(task)
 	}
+	// The stored upload is shared by every job of an archive. It is kept while one of them
+	// can be resumed.
+	go func() {
+		wg.Wait()
+		if !keepUpload(tasks) {
+			os.Remove(path)
+		}
+	}()
 
-	// Read and send records to channel
+	if !archive {
+		ctx.JSON(http.StatusAccepted, gin.H{
+			"status":  "accepted",
+			"message": "File uploaded and queued for processing",
+			"job_id":  jobs[0]["job_id"],
+		})
+		return
+	}
+	ctx.JSON(http.StatusAccepted, gin.H{
+		"status":   "accepted",
+		"message":  fmt.Sprintf("Archive uploaded, %d files queued for processing", len(jobs)),
+		"jobs":     jobs,
+		"rejected": rejected,
+	})
+}
+
+// runImport feeds one source through the worker pipeline.
+// Atomic imports run on a reserved worker inside one transaction that is rolled back on the first failed row.
+func (s *Service) runImport(task *importTask) {
+	defer task.cancel()
+	job := task.job
+	job.start()
+	s.saveImport(task)
+	s.registerAttributes(task)
+
+	var err error
+	if task.opts.Transaction == models.TxAtomic {
+		// The transaction holds a connection until it ends, so it takes a worker as long as
+		// the transaction runs.
+		queue := s.Scheduler.reserve()
+		unlock := func() {}
+		if locksKeys(task.opts) {
+			unlock = s.keys.lockAll()
+		}
+		err = s.Repo.Transaction(func(repo repository.RepositoryInterface) error {
+			txService := *s
+			txService.Repo = repo
+			return txService.importFile(task, queue)
+		})
+		queue.close() // In case the transaction failed to begin
+		queue.release()
+		unlock()
+		if err != nil {
+			job.rollback()
+		}
+	} else {
+		err = s.importFile(task, s.Scheduler.open(0))
+	}
+	if task.quarantine {
+		s.quarantineRows(task, true)
+	}
+
+	job.finish(err)
+	s.saveImport(task)
+	if errors.Is(err, errImportCancelled) {
+		utils.LogInfo("runImport", "Import cancelled: "+job.Snapshot().Filename)
+		return
+	}
+	if err != nil {
+		utils.LogError("runImport", "Import failed: "+job.Snapshot().Filename, err)
+		return
+	}
+	utils.LogInfo("runImport", "File processed successfully: "+job.Snapshot().Filename)
+}
+
+// importFile reads the task's CSV source in chunks and runs them on queue, which it
+// closes once they are done.
+func (s *Service) importFile(task *importTask, queue *importQueue) error {
+	job := task.job
+	reader, err := task.source.open()
+	if err != nil {
+		queue.close()
+		utils.LogError("importFile", "Failed to open stored file", err)
+		return err
+	}
+	defer reader.Close()
+	if aware, ok := reader.(columnAware); ok {
+		aware.useColumns(task.columns)
+	}
+
+	batchSize := s.BatchSize // Set batch size for bulk insertion
+	if task.opts.Loader == models.LoaderCopy {
+		batchSize = s.CopyBatchSize
+	}
+
+	// Read records into chunks, skipping the header that was already resolved and the
+	// chunks an earlier run committed
+	committed := task.committed
+	index, skip := 0, 0 // Data rows read so far; rows of a committed chunk still to skip
+	chunk := importChunk{}
+	flush := func() {
+		if chunk.size() > 0 {
+			queued := chunk
+			queue.submit(func() { processChunk(queued, s, task) })
+		}
+		chunk = importChunk{offset: index}
+	}
 	skipHeader := true
-	for {
-		record, err := csvReader.Read()
+	for task.ctx.Err() == nil {
+		record, line, err := reader.Read()
 		if err == io.EOF {
 			break
 		}
-		if err != nil {
-			// log.Error("Error reading CSV row: ", err)
-			utils.LogError("UploadCSV", "Error reading CSV row", err)
+		if err == nil && skipHeader {
+			skipHeader = false
 			continue
 		}
-		if skipHeader {
-			skipHeader = false
+		if err != nil && skipHeader {
+			utils.LogError("importFile", "Error reading CSV row", err)
+			job.addRead(1)
+			task.reject(line, record, err.Error())
 			continue
 		}
-		recordChan <- record
+
+		if skip == 0 && len(committed) > 0 && committed[0].Offset == index {
+			flush()
+			skip = committed[0].Rows
+			committed = committed[1:]
+		}
+		index++
+		if skip > 0 {
+			if skip--; skip == 0 {
+				chunk.offset = index
+			}
+			continue
+		}
+
+		job.addRead(1)
+		if err != nil {
+			// log.Error("Error reading CSV row: ", err)
+			utils.LogError("importFile", "Error reading CSV row", err)
+			chunk.unread = append(chunk.unread, task.reject(line, record, err.Error()))
+		} else {
+			chunk.rows = append(chunk.rows, csvRow{Line: line, Values: record})
+		}
+		if chunk.size() == batchSize {
+			flush()
+		}
+	}
+	if task.ctx.Err() == nil {
+		flush() // A cancelled import leaves the last partial chunk for a resume
 	}
 
-	close(recordChan) // Signal workers to stop
-	wg.Wait()         // Wait for all workers to finish
+	queue.close() // Wait for the queued chunks to finish
 
-	utils.LogInfo("UploadCSV", "File processed successfully: "+header.Filename)
-	ctx.JSON(http.StatusOK, gin.H{"status": "success", "message": "File uploaded and records stored"})
+	if job.isCancelled() {
+		return errImportCancelled
+	}
+	if task.rollsBack() {
+		if rowErrors := job.RowErrors(); len(rowErrors) > 0 {
+			return fmt.Errorf("import rolled back at line %d: %s", rowErrors[0].Line, rowErrors[0].Reason)
+		}
+	}
+	return nil
 }
 
 func (s *Service) ListAllEntries(ctx *gin.Context) {
@@ -202,13 +945,16 @@ func (s *Service) ListEntriesByPages(ctx *gin.Context) {
 
 }
 
+// QueryUpdates searches users by first name (?keyword=) and custom attributes
+// (?attributes[name]=value). At least one of them is required.
 func (s *Service) QueryUpdates(ctx *gin.Context) {
 	keyword := ctx.Query("keyword")
-	if keyword == "" {
-		utils.LogWarn("QueryUpdates", "Keyword is required but not provided")
+	attributes := attributeFilters(ctx)
+	if keyword == "" && len(attributes) == 0 {
+		utils.LogWarn("QueryUpdates", "Neither a keyword nor an attribute filter was provided")
 		ctx.JSON(http.StatusBadRequest, gin.H{
 			"status":  "error",
-			"message": "Keyword is required",
+			"message": "Keyword or attribute filter is required",
 		})
 		return
 	}
@@ -229,8 +975,12 @@ func (s *Service) QueryUpdates(ctx *gin.Context) {
 	utils.LogInfo("QueryUpdates", fmt.Sprintf("Request received with keyword: %s, page: %d, limit: %d", keyword, page, limit))
 
 	// Build query parameters
-	queryParams := map[string]interface{}{
-		"first_name": keyword,
+	queryParams := map[string]interface{}{}
+	if keyword != "" {
+		queryParams["first_name"] = keyword
+	}
+	for key, value := range attributes {
+		queryParams[key] = value
 	}
 
 	// Fetch matching records with pagination
@@ -246,7 +996,14 @@ func (s *Service) QueryUpdates(ctx *gin.Context) {
 
 	// Fetch total count for metadata
 	var total int64
-	if err := db.Model(&models.User{}).Where("LOWER(first_name) LIKE ?", "%"+strings.ToLower(keyword)+"%").Count(&total).Error; err != nil {
+	count := db.Model(&models.User{})
+	if keyword != "" {
+		count = count.Where("LOWER(first_name) LIKE ?", "%"+strings.ToLower(keyword)+"%")
+	}
+	for key, value := range attributes {
+		count = count.Where("attributes @> jsonb_build_object(?::text, ?::text)", strings.TrimPrefix(key, repository.AttributeParamPrefix), value)
+	}
+	if err := count.Count(&total).Error; err != nil {
 		utils.LogError("QueryUpdates", "Failed to count records", err)
 		ctx.JSON(http.StatusInternalServerError, gin.H{
 			"status":  "error",
@@ -272,7 +1029,15 @@ func (s *Service) QueryUpdates(ctx *gin.Context) {
 	utils.LogInfo("QueryUpdates", fmt.Sprintf("Response sent with total records: %d for keyword: %s", total, keyword))
 }
 
+// AddRecord inserts one user. A retry sent with the same Idempotency-Key header gets the
+// response of the first request instead of inserting the user again.
 func (s *Service) AddRecord(ctx *gin.Context) {
+	s.idempotent(ctx, "AddRecord", func() {
+		s.addRecord(ctx)
+	})
+}
+
+func (s *Service) addRecord(ctx *gin.Context) {
 	var user models.User
 
 	// Parse the JSON body into the User struct
@@ -289,6 +1054,9 @@ func (s *Service) AddRecord(ctx *gin.Context) {
 		return
 	}
 
+	// Only imports attach users to an import, since reverting it deletes them.
+	user.ImportID = nil
+
 	utils.LogInfo("AddRecord", "Attempting to insert record")
 
 	// Insert the record into the database
@@ -366,24 +1134,3 @@ func (s *Service) DeleteRecord(ctx *gin.Context) {
 // func (s *Service) GetLogs(ctx *gin.Context) {
 // 	// Implementation
 // }
-
-// Helper function to parse integers safely
-func parseInt(str string) int {
-	val, _ := strconv.Atoi(strings.TrimSpace(str))
-	return val
-}
-
-// Helper function to parse a float64 from a string
-func parseFloat(value string) float64 {
-	num, err := strconv.ParseFloat(value, 64)
-	if err != nil {
-		return 0.0 // Default value if parsing fails
-	}
-	return num
-}
-
-// Helper function to parse a boolean from a string
-func parseBool(value string) bool {
-	// For simplicity, let's return true if the string is "true" (case insensitive)
-	return value == "true" || value == "TRUE"
-}
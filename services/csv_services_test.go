@@ -2,9 +2,12 @@ package services
 
 import (
 	"bytes"
+	"context"
 	"csv-microservice/mock"
 	"csv-microservice/models"
+	repository "csv-microservice/repositories"
 	"csv-microservice/utils"
+	"encoding/json"
 	"errors"
 	"mime/multipart"
 	"net/http"
@@ -498,6 +501,31 @@ func TestAddRecord_ValidRecord(t *testing.T) {
 	}`, w.Body.String())
 }
 
+func TestAddRecord_IgnoresImportID(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockRepo := mock.NewMockRepositoryInterface(ctrl)
+	service := NewService(mockRepo)
+	utils.InitLogger()
+
+	router := gin.Default()
+	router.POST("/records", service.AddRecord)
+
+	mockRepo.EXPECT().InsertRecord(gomock.Any(), gomock.Any()).DoAndReturn(func(_ context.Context, record interface{}) error {
+		assert.Nil(t, record.(*models.User).ImportID)
+		return nil
+	})
+
+	req, _ := http.NewRequest("POST", "/records", strings.NewReader(`{"first_name":"John","email":"john@example.com","import_id":"abc"}`))
+	req.Header.Set("Content-Type", "application/json")
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.NotContains(t, w.Body.String(), "import_id")
+}
+
 func TestAddRecord_InvalidRequestBody(t *testing.T) {
 	ctrl := gomock.NewController(t)
 	defer ctrl.Finish()
@@ -610,6 +638,7 @@ func TestUploadCSV(t *testing.T) {
 
 	// Mock repository
 	mockRepo := mock.NewMockRepositoryInterface(ctrl)
+	expectImportAudit(mockRepo)
 	mockService := NewService(mockRepo) // Inject the mock repository
 
 	// Initialize the logger
@@ -624,9 +653,12 @@ func TestUploadCSV(t *testing.T) {
 		name           string
 		fileContent    string
 		fileName       string
+		fields         map[string]string
 		mockSetup      func()
 		expectedStatus int
 		expectedBody   string
+		expectedJob    models.ImportJob
+		expectedErrors []models.RowError
 	}{
 		{
 			name:        "Valid CSV Upload",
@@ -635,8 +667,8 @@ func TestUploadCSV(t *testing.T) {
 			mockSetup: func() {
 				mockRepo.EXPECT().BulkInsert(gomock.Any()).Return(nil).Times(1) // Expecting BulkInsert to be called once
 			},
-			expectedStatus: http.StatusOK,
-			expectedBody:   `{"status":"success","message":"File uploaded and records stored"}`,
+			expectedStatus: http.StatusAccepted,
+			expectedJob:    models.ImportJob{State: models.JobCompleted, RowsRead: 1, RowsInserted: 1},
 		},
 		{
 			name:           "Invalid File Format (Non-CSV)",
@@ -644,15 +676,192 @@ func TestUploadCSV(t *testing.T) {
 			fileName:       "invalid.txt",
 			mockSetup:      func() {},
 			expectedStatus: http.StatusBadRequest,
-			expectedBody:   `{"error":"Only CSV files are allowed."}`,
+			expectedBody:   `{"error":"Only CSV files (.csv, .csv.gz, or .zip archives of CSV files) and Excel workbooks (.xlsx) are allowed."}`,
 		},
 		{
 			name:           "Empty CSV File",
 			fileContent:    "",
 			fileName:       "empty.csv",
 			mockSetup:      func() {},
-			expectedStatus: http.StatusOK,
-			expectedBody:   `{"status":"success","message":"File uploaded and records stored"}`,
+			expectedStatus: http.StatusBadRequest,
+			expectedBody:   `{"error":"Invalid CSV header: file is empty, expected a header row"}`,
+		},
+		{
+			name:           "Missing Required Columns",
+			fileContent:    "id,first_name,age\n1,John,30",
+			fileName:       "missing.csv",
+			mockSetup:      func() {},
+			expectedStatus: http.StatusBadRequest,
+			expectedBody:   `{"error":"Invalid CSV header: missing required columns: last_name, email"}`,
+		},
+		{
+			name:           "Unknown Mapping Profile",
+			fileContent:    "id,first_name,last_name,email\n1,John,Doe,john.doe@example.com",
+			fileName:       "profile.csv",
+			fields:         map[string]string{"profile": "vendor"},
+			mockSetup:      func() {},
+			expectedStatus: http.StatusBadRequest,
+			expectedBody:   `{"error":"unknown mapping profile \"vendor\""}`,
+		},
+		{
+			name:        "Reordered Columns With Aliases",
+			fileContent: "Email Address,Surname,First Name,Notes,Salary\njohn.doe@example.com,Doe,John,vip,5000",
+			fileName:    "reordered.csv",
+			mockSetup: func() {
+				mockRepo.EXPECT().RegisterAttributes(gomock.Len(1)).Return(nil).Times(1)
+				mockRepo.EXPECT().BulkInsert(importedUsers{
+					{FirstName: "John", LastName: "Doe", Email: "john.doe@example.com", Salary: 5000, Attributes: models.Attributes{"notes": "vip"}},
+				}).Return(nil).Times(1)
+			},
+			expectedStatus: http.StatusAccepted,
+			expectedJob:    models.ImportJob{State: models.JobCompleted, RowsRead: 1, RowsInserted: 1},
+		},
+		{
+			name:           "Invalid Mode",
+			fileContent:    "id,first_name,last_name,email\n1,John,Doe,john.doe@example.com",
+			fileName:       "mode.csv",
+			fields:         map[string]string{"mode": "replace"},
+			mockSetup:      func() {},
+			expectedStatus: http.StatusBadRequest,
+			expectedBody:   `{"error":"invalid mode \"replace\": expected one of insert, upsert_id, upsert_email, skip_existing"}`,
+		},
+		{
+			name:        "Upsert By Email With Merge",
+			fileContent: "id,first_name,last_name,email,department\n,John,Doe,john.doe@example.com,\n,Jane,Roe,jane@example.com,HR",
+			fileName:    "upsert.csv",
+			fields:      map[string]string{"mode": models.ModeUpsertEmail, "merge": "true"},
+			mockSetup: func() {
+				// Rows may be spread over several workers, so answer per record
+				expected := map[string]models.UserRecord{
+					"john.doe@example.com": {User: models.User{FirstName: "John", LastName: "Doe", Email: "john.doe@example.com"}, Fields: []string{"first_name", "last_name", "email"}},
+					"jane@example.com":     {User: models.User{FirstName: "Jane", LastName: "Roe", Email: "jane@example.com", Department: "HR"}, Fields: []string{"first_name", "last_name", "email", "department"}},
+				}
+				mockRepo.EXPECT().UpsertBatch(gomock.Any(), models.ModeUpsertEmail, true).DoAndReturn(
+					func(records []models.UserRecord, mode string, merge bool) (models.WriteResult, error) {
+						var result models.WriteResult
+						for _, record := range records {
+							assert.NotNil(t, record.User.ImportID)
+							record.User.ImportID = nil
+							assert.Equal(t, expected[record.User.Email], record)
+							if record.User.Email == "john.doe@example.com" {
+								result.Updated++
+							} else {
+								result.Inserted++
+							}
+						}
+						return result, nil
+					}).MinTimes(1).MaxTimes(2)
+			},
+			expectedStatus: http.StatusAccepted,
+			expectedJob:    models.ImportJob{State: models.JobCompleted, Mode: models.ModeUpsertEmail, RowsRead: 2, RowsInserted: 1, RowsUpdated: 1},
+		},
+		{
+			name:        "Skip Existing",
+			fileContent: "id,first_name,last_name,email\n1,John,Doe,john.doe@example.com",
+			fileName:    "skip.csv",
+			fields:      map[string]string{"mode": models.ModeSkipExisting},
+			mockSetup: func() {
+				mockRepo.EXPECT().UpsertBatch(gomock.Any(), models.ModeSkipExisting, false).Return(models.WriteResult{Skipped: 1}, nil).Times(1)
+			},
+			expectedStatus: http.StatusAccepted,
+			expectedJob:    models.ImportJob{State: models.JobCompleted, Mode: models.ModeSkipExisting, RowsRead: 1, RowsSkipped: 1},
+		},
+		{
+			name:        "Atomic Import",
+			fileContent: "id,first_name,last_name,email\n1,John,Doe,john.doe@example.com\n2,Jane,Roe,jane@example.com",
+			fileName:    "atomic.csv",
+			fields:      map[string]string{"transaction": models.TxAtomic},
+			mockSetup: func() {
+				mockRepo.EXPECT().Transaction(gomock.Any()).DoAndReturn(func(fn func(repository.RepositoryInterface) error) error {
+					return fn(mockRepo)
+				}).Times(1)
+				mockRepo.EXPECT().BulkInsert(gomock.Len(2)).Return(nil).Times(1)
+			},
+			expectedStatus: http.StatusAccepted,
+			expectedJob:    models.ImportJob{State: models.JobCompleted, RowsRead: 2, RowsInserted: 2},
+		},
+		{
+			name:        "Atomic Import Rolled Back",
+			fileContent: "id,first_name,last_name,email,age\n1,John,Doe,john.doe@example.com,30\n2,Jim,Poe,jim@example.com,40\n3,Jane,Roe,jane@example.com,old",
+			fileName:    "atomic_rollback.csv",
+			fields:      map[string]string{"transaction": models.TxAtomic},
+			mockSetup: func() {
+				mockRepo.EXPECT().Transaction(gomock.Any()).DoAndReturn(func(fn func(repository.RepositoryInterface) error) error {
+					return fn(mockRepo)
+				}).Times(1)
+			},
+			expectedStatus: http.StatusAccepted,
+			expectedJob: models.ImportJob{
+				State:      models.JobFailed,
+				RowsRead:   3,
+				RowsFailed: 1,
+				Error:      `import rolled back at line 4: age: invalid integer "old"`,
+				FirstError: &models.RowError{Line: 4, Values: []string{"3", "Jane", "Roe", "jane@example.com", "old"}, Reason: `age: invalid integer "old"`},
+			},
+			expectedErrors: []models.RowError{
+				{Line: 4, Values: []string{"3", "Jane", "Roe", "jane@example.com", "old"}, Reason: `age: invalid integer "old"`},
+			},
+		},
+		{
+			name:           "Invalid Transaction",
+			fileContent:    "id,first_name,last_name,email\n1,John,Doe,john.doe@example.com",
+			fileName:       "transaction.csv",
+			fields:         map[string]string{"transaction": "partial"},
+			mockSetup:      func() {},
+			expectedStatus: http.StatusBadRequest,
+			expectedBody:   `{"error":"invalid transaction \"partial\": expected best_effort or atomic"}`,
+		},
+		{
+			name:        "COPY Loader",
+			fileContent: "id,first_name,last_name,email\n1,John,Doe,john.doe@example.com",
+			fileName:    "copy.csv",
+			fields:      map[string]string{"loader": models.LoaderCopy},
+			mockSetup: func() {
+				mockRepo.EXPECT().CopyInsert(gomock.Any(), importedUsers{
+					{Id: 1, FirstName: "John", LastName: "Doe", Email: "john.doe@example.com"},
+				}).Return(int64(1), nil).Times(1)
+			},
+			expectedStatus: http.StatusAccepted,
+			expectedJob:    models.ImportJob{State: models.JobCompleted, RowsRead: 1, RowsInserted: 1},
+		},
+		{
+			name:        "COPY Loader Falls Back Per Row",
+			fileContent: "id,first_name,last_name,email\n1,John,Doe,john.doe@example.com",
+			fileName:    "copy_fallback.csv",
+			fields:      map[string]string{"loader": models.LoaderCopy},
+			mockSetup: func() {
+				mockRepo.EXPECT().CopyInsert(gomock.Any(), gomock.Any()).Return(int64(0), errors.New("duplicate key")).Times(1)
+				mockRepo.EXPECT().BulkInsert(gomock.Len(1)).Return(errors.New("duplicate key")).Times(1)
+			},
+			expectedStatus: http.StatusAccepted,
+			expectedJob:    models.ImportJob{State: models.JobCompleted, RowsRead: 1, RowsFailed: 1},
+			expectedErrors: []models.RowError{
+				{Line: 2, Values: []string{"1", "John", "Doe", "john.doe@example.com"}, Reason: "database error: duplicate key"},
+			},
+		},
+		{
+			name:           "COPY Loader With Upsert",
+			fileContent:    "id,first_name,last_name,email\n1,John,Doe,john.doe@example.com",
+			fileName:       "copy_upsert.csv",
+			fields:         map[string]string{"loader": models.LoaderCopy, "mode": models.ModeUpsertID},
+			mockSetup:      func() {},
+			expectedStatus: http.StatusBadRequest,
+			expectedBody:   `{"error":"loader copy only supports mode insert"}`,
+		},
+		{
+			name:        "Inline Mapping",
+			fileContent: "CONTACT,GIVEN,FAMILY\njane@example.com,Jane,Roe",
+			fileName:    "inline.csv",
+			fields: map[string]string{
+				"mapping": `{"aliases":{"email":["contact"],"first_name":["given"],"last_name":["family"]},"required":["email"]}`,
+			},
+			mockSetup: func() {
+				mockRepo.EXPECT().BulkInsert(importedUsers{
+					{FirstName: "Jane", LastName: "Roe", Email: "jane@example.com"},
+				}).Return(nil).Times(1)
+			},
+			expectedStatus: http.StatusAccepted,
+			expectedJob:    models.ImportJob{State: models.JobCompleted, RowsRead: 1, RowsInserted: 1},
 		},
 		{
 			name:        "Malformed CSV Data (Missing Field)",
@@ -662,8 +871,35 @@ func TestUploadCSV(t *testing.T) {
 				// Assuming the service attempts to insert malformed data
 				mockRepo.EXPECT().BulkInsert(gomock.Any()).Return(nil).Times(1)
 			},
-			expectedStatus: http.StatusOK,
-			expectedBody:   `{"status":"success","message":"File uploaded and records stored"}`, // Assuming we still process despite errors in data
+			expectedStatus: http.StatusAccepted,
+			expectedJob:    models.ImportJob{State: models.JobCompleted, RowsRead: 1, RowsInserted: 1}, // Assuming we still process despite errors in data
+		},
+		{
+			name:        "Database Error",
+			fileContent: "id,first_name,last_name,email,age,gender,department,company,salary,date_joined,is_active\n1,John,Doe,john.doe@example.com,30,Male,Engineering,TechCorp,100000,2025-01-01,true",
+			fileName:    "dberror.csv",
+			mockSetup: func() {
+				// The failed batch is retried row by row before the row is rejected
+				mockRepo.EXPECT().BulkInsert(gomock.Any()).Return(errors.New("db error")).Times(2)
+			},
+			expectedStatus: http.StatusAccepted,
+			expectedJob:    models.ImportJob{State: models.JobCompleted, RowsRead: 1, RowsFailed: 1},
+			expectedErrors: []models.RowError{
+				{Line: 2, Values: strings.Split("1,John,Doe,john.doe@example.com,30,Male,Engineering,TechCorp,100000,2025-01-01,true", ","), Reason: "database error: db error"},
+			},
+		},
+		{
+			name:           "Rejected Rows",
+			fileContent:    "id,first_name,last_name,email,age,gender,department,company,salary,date_joined,is_active\n1,John,Doe\nabc,Jane,Doe,jane@example.com,30,Female,HR,TechCorp,1000,2025-01-01,true\n3,Jim,Doe,jim@example.com,30,Male,HR,TechCorp,lots,2025-01-01,true",
+			fileName:       "rejected.csv",
+			mockSetup:      func() {},
+			expectedStatus: http.StatusAccepted,
+			expectedJob:    models.ImportJob{State: models.JobCompleted, RowsRead: 3, RowsFailed: 3},
+			expectedErrors: []models.RowError{
+				{Line: 2, Values: []string{"1", "John", "Doe"}, Reason: "too few columns: expected 11, got 3"},
+				{Line: 3, Values: strings.Split("abc,Jane,Doe,jane@example.com,30,Female,HR,TechCorp,1000,2025-01-01,true", ","), Reason: `id: invalid integer "abc"`},
+				{Line: 4, Values: strings.Split("3,Jim,Doe,jim@example.com,30,Male,HR,TechCorp,lots,2025-01-01,true", ","), Reason: `salary: invalid number "lots"`},
+			},
 		},
 	}
 
@@ -681,6 +917,9 @@ func TestUploadCSV(t *testing.T) {
 			assert.NoError(t, err, "Failed to create form file")
 			_, err = part.Write([]byte(tt.fileContent))
 			assert.NoError(t, err, "Failed to write file content")
+			for key, value := range tt.fields {
+				assert.NoError(t, writer.WriteField(key, value))
+			}
 
 			// Close the writer to finalize the multipart form
 			writer.Close()
@@ -695,7 +934,41 @@ func TestUploadCSV(t *testing.T) {
 
 			// Assertions
 			assert.Equal(t, tt.expectedStatus, w.Code)
-			assert.JSONEq(t, tt.expectedBody, w.Body.String())
+			if tt.expectedStatus != http.StatusAccepted {
+				assert.JSONEq(t, tt.expectedBody, w.Body.String())
+				return
+			}
+
+			// Wait for the background job and check its counters
+			var resp struct {
+				Status string `json:"status"`
+				JobID  string `json:"job_id"`
+			}
+			assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &resp))
+			assert.Equal(t, "accepted", resp.Status)
+
+			job, ok := mockService.Jobs.Get(resp.JobID)
+			assert.True(t, ok, "Job should be registered")
+			job.Wait()
+
+			snapshot := job.Snapshot()
+			assert.Equal(t, tt.expectedJob.State, snapshot.State)
+			assert.Equal(t, tt.expectedJob.RowsRead, snapshot.RowsRead)
+			assert.Equal(t, tt.expectedJob.RowsInserted, snapshot.RowsInserted)
+			assert.Equal(t, tt.expectedJob.RowsUpdated, snapshot.RowsUpdated)
+			assert.Equal(t, tt.expectedJob.RowsSkipped, snapshot.RowsSkipped)
+			assert.Equal(t, tt.expectedJob.RowsFailed, snapshot.RowsFailed)
+			assert.Equal(t, tt.fileName, snapshot.Filename)
+			if tt.expectedJob.Mode != "" {
+				assert.Equal(t, tt.expectedJob.Mode, snapshot.Mode)
+			}
+			assert.NotNil(t, snapshot.FinishedAt)
+			assert.Equal(t, tt.expectedJob.Error, snapshot.Error)
+			assert.Equal(t, tt.expectedJob.FirstError, snapshot.FirstError)
+			assert.Equal(t, len(tt.expectedErrors), len(job.RowErrors()))
+			if len(tt.expectedErrors) > 0 {
+				assert.Equal(t, tt.expectedErrors, job.RowErrors())
+			}
 		})
 	}
 }
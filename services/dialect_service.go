@@ -0,0 +1,257 @@
+package services
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/gin-gonic/gin"
+	"golang.org/x/text/encoding/charmap"
+	"golang.org/x/text/encoding/unicode"
+	"golang.org/x/text/transform"
+)
+
+// Text encodings a CSV upload can be read in.
+const (
+	EncodingUTF8        = "utf-8"
+	EncodingUTF16LE     = "utf-16le"
+	EncodingUTF16BE     = "utf-16be"
+	EncodingWindows1252 = "windows-1252"
+	EncodingISO88591    = "iso-8859-1"
+)
+
+// sniffSize is how much of a CSV file is inspected to detect its encoding and delimiter.
+const sniffSize = 64 * 1024
+
+// delimiterCandidates are the delimiters tried by detection, in order of preference.
+var delimiterCandidates = []rune{',', ';', '\t', '|'}
+
+var utf8BOM = []byte{0xEF, 0xBB, 0xBF}
+
+// csvDialect describes how a CSV file is decoded and split into fields. A zero
+// Delimiter or empty Encoding is detected from the start of the file.
+type csvDialect struct {
+	Delimiter  rune
+	LazyQuotes bool // Allow quotes inside unquoted fields and unescaped quotes in quoted fields
+	Comment    rune // Lines starting with this character are skipped; 0 disables comments
+	Encoding   string
+}
+
+// parseDialect reads the delimiter, lazy_quotes, comment and encoding form fields.
+func parseDialect(ctx *gin.Context) (csvDialect, error) {
+	var dialect csvDialect
+
+	if delimiter := importParam(ctx, "delimiter", ""); delimiter != "" {
+		parsed, err := parseDelimiter(delimiter)
+		if err != nil {
+			return csvDialect{}, fmt.Errorf("invalid delimiter %q: %w", delimiter, err)
+		}
+		dialect.Delimiter = parsed
+	}
+
+	if lazyQuotes := importParam(ctx, "lazy_quotes", ""); lazyQuotes != "" {
+		parsed, err := strconv.ParseBool(lazyQuotes)
+		if err != nil {
+			return csvDialect{}, fmt.Errorf("invalid lazy_quotes value %q", lazyQuotes)
+		}
+		dialect.LazyQuotes = parsed
+	}
+
+	if comment := importParam(ctx, "comment", ""); comment != "" {
+		parsed, err := parseDelimiter(comment)
+		if err != nil {
+			return csvDialect{}, fmt.Errorf("invalid comment %q: %w", comment, err)
+		}
+		if parsed == dialect.Delimiter {
+			return csvDialect{}, fmt.Errorf("invalid comment %q: must differ from the delimiter", comment)
+		}
+		dialect.Comment = parsed
+	}
+
+	if encoding := importParam(ctx, "encoding", ""); encoding != "" {
+		parsed, ok := normalizeEncoding(encoding)
+		if !ok {
+			return csvDialect{}, fmt.Errorf("invalid encoding %q: expected one of %s, %s, %s, %s, %s", encoding,
+				EncodingUTF8, EncodingUTF16LE, EncodingUTF16BE, EncodingWindows1252, EncodingISO88591)
+		}
+		dialect.Encoding = parsed
+	}
+	return dialect, nil
+}
+
+// parseDelimiter accepts a single character, or "tab" and "\t" for a tab.
+func parseDelimiter(value string) (rune, error) {
+	if value == "tab" || value == `\t` {
+		return '\t', nil
+	}
+	r, size := utf8.DecodeRuneInString(value)
+	if size != len(value) || r == utf8.RuneError {
+		return 0, errors.New("expected a single character")
+	}
+	if r == '"' || r == '\r' || r == '\n' {
+		return 0, errors.New("quotes and line breaks cannot be used")
+	}
+	return r, nil
+}
+
+func normalizeEncoding(value string) (string, bool) {
+	switch strings.ToLower(strings.TrimSpace(value)) {
+	case "utf-8", "utf8":
+		return EncodingUTF8, true
+	case "utf-16", "utf16", "utf-16le", "utf16le":
+		return EncodingUTF16LE, true
+	case "utf-16be", "utf16be":
+		return EncodingUTF16BE, true
+	case "windows-1252", "cp1252":
+		return EncodingWindows1252, true
+	case "iso-8859-1", "latin1", "latin-1":
+		return EncodingISO88591, true
+	}
+	return "", false
+}
+
+// resolve fills in the settings left for detection from a sample of the raw file.
+func (d csvDialect) resolve(sample []byte) (csvDialect, error) {
+	if d.Encoding == "" {
+		d.Encoding = detectEncoding(sample)
+	}
+	if d.Delimiter == 0 {
+		decoded, _ := io.ReadAll(d.decode(bufio.NewReader(bytes.NewReader(sample))))
+		d.Delimiter = detectDelimiter(string(decoded), d.Comment, len(sample) == sniffSize)
+		if d.Comment == d.Delimiter {
+			return csvDialect{}, fmt.Errorf("invalid comment %q: must differ from the detected delimiter", string(d.Comment))
+		}
+	}
+	return d, nil
+}
+
+// decode returns the file as UTF-8 text without a byte order mark.
+func (d csvDialect) decode(r *bufio.Reader) io.Reader {
+	switch d.Encoding {
+	case EncodingUTF16LE:
+		return transform.NewReader(r, unicode.UTF16(unicode.LittleEndian, unicode.UseBOM).NewDecoder())
+	case EncodingUTF16BE:
+		return transform.NewReader(r, unicode.UTF16(unicode.BigEndian, unicode.UseBOM).NewDecoder())
+	case EncodingWindows1252:
+		return charmap.Windows1252.NewDecoder().Reader(r)
+	case EncodingISO88591:
+		return charmap.ISO8859_1.NewDecoder().Reader(r)
+	default:
+		if prefix, _ := r.Peek(len(utf8BOM)); bytes.Equal(prefix, utf8BOM) {
+			r.Discard(len(utf8BOM))
+		}
+		return r
+	}
+}
+
+// detectEncoding guesses the encoding from a byte order mark, the zero bytes UTF-16
+// leaves in ASCII text, or whether the sample is valid UTF-8. Anything else is read as
+// Windows-1252, the usual encoding of spreadsheet exports on Windows.
+func detectEncoding(sample []byte) string {
+	switch {
+	case bytes.HasPrefix(sample, utf8BOM):
+		return EncodingUTF8
+	case bytes.HasPrefix(sample, []byte{0xFF, 0xFE}):
+		return EncodingUTF16LE
+	case bytes.HasPrefix(sample, []byte{0xFE, 0xFF}):
+		return EncodingUTF16BE
+	}
+
+	if len(sample) >= 4 {
+		var evenZeros, oddZeros int
+		for i, b := range sample {
+			if b != 0 {
+				continue
+			}
+			if i%2 == 0 {
+				evenZeros++
+			} else {
+				oddZeros++
+			}
+		}
+		half := len(sample) / 2
+		switch {
+		case oddZeros > half*3/4 && evenZeros == 0:
+			return EncodingUTF16LE
+		case evenZeros > half*3/4 && oddZeros == 0:
+			return EncodingUTF16BE
+		}
+	}
+
+	if len(sample) == sniffSize {
+		// The sample may end in the middle of a character.
+		start := len(sample) - 1
+		for start > 0 && start > len(sample)-utf8.UTFMax && !utf8.RuneStart(sample[start]) {
+			start--
+		}
+		if !utf8.FullRune(sample[start:]) {
+			sample = sample[:start]
+		}
+	}
+	if utf8.Valid(sample) {
+		return EncodingUTF8
+	}
+	return EncodingWindows1252
+}
+
+// detectDelimiter picks the candidate that occurs in the header line and the same number
+// of times on the most following lines. Ties go to the candidate found more often in the
+// header, then to the earlier candidate. A comma is assumed when nothing matches.
+func detectDelimiter(text string, comment rune, truncated bool) rune {
+	lines := strings.Split(text, "\n")
+	if truncated && len(lines) > 1 {
+		lines = lines[:len(lines)-1] // The last line may be cut short
+	}
+	var sampled []string
+	for _, line := range lines {
+		line = strings.TrimRight(line, "\r")
+		if strings.TrimSpace(line) == "" || (comment != 0 && strings.HasPrefix(line, string(comment))) {
+			continue
+		}
+		sampled = append(sampled, line)
+		if len(sampled) == 20 {
+			break
+		}
+	}
+	if len(sampled) == 0 {
+		return ','
+	}
+
+	best, bestScore, bestCount := ',', 0, 0
+	for _, candidate := range delimiterCandidates {
+		count := countUnquoted(sampled[0], candidate)
+		if count == 0 {
+			continue
+		}
+		score := 0
+		for _, line := range sampled[1:] {
+			if countUnquoted(line, candidate) == count {
+				score++
+			}
+		}
+		if score > bestScore || (score == bestScore && count > bestCount) {
+			best, bestScore, bestCount = candidate, score, count
+		}
+	}
+	return best
+}
+
+// countUnquoted counts a character outside double-quoted sections of a line.
+func countUnquoted(line string, char rune) int {
+	count := 0
+	quoted := false
+	for _, r := range line {
+		switch {
+		case r == '"':
+			quoted = !quoted
+		case r == char && !quoted:
+			count++
+		}
+	}
+	return count
+}
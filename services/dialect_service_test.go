@@ -0,0 +1,180 @@
+package services
+
+import (
+	"csv-microservice/mock"
+	"csv-microservice/models"
+	"csv-microservice/utils"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/assert"
+	"golang.org/x/text/encoding/charmap"
+	"golang.org/x/text/encoding/unicode"
+)
+
+func encodeText(t *testing.T, text string, encoder interface {
+	String(string) (string, error)
+}) string {
+	encoded, err := encoder.String(text)
+	assert.NoError(t, err)
+	return encoded
+}
+
+func TestDetectEncoding(t *testing.T) {
+	tests := []struct {
+		name     string
+		sample   string
+		expected string
+	}{
+		{"Plain ASCII", "id,name\n1,John\n", EncodingUTF8},
+		{"UTF-8 with BOM", "\ufeffid,name\n", EncodingUTF8},
+		{"UTF-8 accents", "name\nJosé\n", EncodingUTF8},
+		{"Windows-1252 accents", encodeText(t, "name\nJosé\n", charmap.Windows1252.NewEncoder()), EncodingWindows1252},
+		{"UTF-16LE with BOM", encodeText(t, "id,name\n", unicode.UTF16(unicode.LittleEndian, unicode.UseBOM).NewEncoder()), EncodingUTF16LE},
+		{"UTF-16BE with BOM", encodeText(t, "id,name\n", unicode.UTF16(unicode.BigEndian, unicode.UseBOM).NewEncoder()), EncodingUTF16BE},
+		{"UTF-16LE without BOM", encodeText(t, "id,name\n", unicode.UTF16(unicode.LittleEndian, unicode.IgnoreBOM).NewEncoder()), EncodingUTF16LE},
+		{"Empty", "", EncodingUTF8},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.expected, detectEncoding([]byte(tt.sample)))
+		})
+	}
+}
+
+func TestDetectDelimiter(t *testing.T) {
+	tests := []struct {
+		name     string
+		text     string
+		comment  rune
+		expected rune
+	}{
+		{"Comma", "id,name,email\n1,John,john@example.com\n", 0, ','},
+		{"Semicolon with decimal commas", "name;salary\nJohn;1234,50\nJane;99,00\n", 0, ';'},
+		{"Tab", "name\temail\nJohn\tjohn@example.com\n", 0, '\t'},
+		{"Pipe", "name|email\nJohn|john@example.com\n", 0, '|'},
+		{"Quoted commas", "name;note\nJohn;\"a, b, c\"\n", 0, ';'},
+		{"Single column", "email\njohn@example.com\n", 0, ','},
+		{"Comment lines skipped", "# exported; do not edit\nname|email\nJohn|john@example.com\n", '#', '|'},
+		{"Empty", "", 0, ','},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, string(tt.expected), string(detectDelimiter(tt.text, tt.comment, false)))
+		})
+	}
+}
+
+func TestParseDelimiter(t *testing.T) {
+	for value, expected := range map[string]rune{";": ';', "tab": '\t', `\t`: '\t', "\t": '\t', "|": '|'} {
+		parsed, err := parseDelimiter(value)
+		assert.NoError(t, err)
+		assert.Equal(t, expected, parsed)
+	}
+	for _, value := range []string{",,", `"`, "\n"} {
+		_, err := parseDelimiter(value)
+		assert.Error(t, err, value)
+	}
+}
+
+func TestUploadCSV_Dialects(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockRepo := mock.NewMockRepositoryInterface(ctrl)
+	service := NewService(mockRepo)
+	utils.InitLogger()
+
+	router := gin.Default()
+	router.POST("/validate", service.ValidateCSV)
+
+	type validation struct {
+		Data struct {
+			RowsRead  int               `json:"rows_read"`
+			RowsValid int               `json:"rows_valid"`
+			Errors    []models.RowError `json:"errors"`
+			Preview   []models.User     `json:"preview"`
+		} `json:"data"`
+	}
+	validate := func(t *testing.T, content string, fields map[string]string) (int, validation) {
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, newUploadRequest(t, "/validate", "users.csv", content, fields))
+		var resp validation
+		if w.Code == http.StatusOK {
+			assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &resp))
+		}
+		return w.Code, resp
+	}
+
+	t.Run("Semicolon-delimited Windows-1252 export", func(t *testing.T) {
+		content := encodeText(t, "first_name;last_name;email;salary\nJosé;Müller;jose@example.com;1234.5\n", charmap.Windows1252.NewEncoder())
+		code, resp := validate(t, content, nil)
+
+		assert.Equal(t, http.StatusOK, code)
+		assert.Equal(t, []models.User{{FirstName: "José", LastName: "Müller", Email: "jose@example.com", Salary: 1234.5}}, resp.Data.Preview)
+	})
+
+	t.Run("UTF-16 tab-separated file", func(t *testing.T) {
+		content := encodeText(t, "first_name\tlast_name\temail\nZoë\tÅberg\tzoe@example.com\n", unicode.UTF16(unicode.LittleEndian, unicode.UseBOM).NewEncoder())
+		code, resp := validate(t, content, nil)
+
+		assert.Equal(t, http.StatusOK, code)
+		assert.Equal(t, []models.User{{FirstName: "Zoë", LastName: "Åberg", Email: "zoe@example.com"}}, resp.Data.Preview)
+	})
+
+	t.Run("UTF-8 BOM is not part of the first value", func(t *testing.T) {
+		code, resp := validate(t, "\ufefffirst_name,last_name,email\nJohn,Doe,john@example.com\n", nil)
+
+		assert.Equal(t, http.StatusOK, code)
+		assert.Equal(t, 1, resp.Data.RowsValid)
+	})
+
+	t.Run("Form fields override detection", func(t *testing.T) {
+		content := "// exported by HR\nfirst_name|last_name|email\nJo \"JJ\" Smith|Doe|jo@example.com\n// end\n"
+		code, resp := validate(t, content, map[string]string{"delimiter": "|", "comment": "/", "lazy_quotes": "true", "encoding": "utf-8"})
+
+		assert.Equal(t, http.StatusOK, code)
+		assert.Equal(t, 1, resp.Data.RowsRead)
+		assert.Equal(t, []models.User{{FirstName: `Jo "JJ" Smith`, LastName: "Doe", Email: "jo@example.com"}}, resp.Data.Preview)
+	})
+
+	t.Run("Strict quotes by default", func(t *testing.T) {
+		code, resp := validate(t, "first_name,last_name,email\nJo \"JJ\" Smith,Doe,jo@example.com\n", nil)
+
+		assert.Equal(t, http.StatusOK, code)
+		if assert.Len(t, resp.Data.Errors, 1) {
+			assert.Contains(t, resp.Data.Errors[0].Reason, "bare \" in non-quoted-field")
+		}
+	})
+
+	t.Run("Explicit encoding", func(t *testing.T) {
+		// Forcing Latin-1 reads each byte of the UTF-8 input as its own character.
+		code, resp := validate(t, "first_name,last_name,email\nJosÃ©,Doe,jose@example.com\n", map[string]string{"encoding": "latin1"})
+
+		assert.Equal(t, http.StatusOK, code)
+		assert.Equal(t, "JosÃ\u0083Â©", resp.Data.Preview[0].FirstName)
+	})
+
+	invalid := []struct {
+		fields   map[string]string
+		expected string
+	}{
+		{map[string]string{"delimiter": "::"}, `{"error":"invalid delimiter \"::\": expected a single character"}`},
+		{map[string]string{"encoding": "ebcdic"}, `{"error":"invalid encoding \"ebcdic\": expected one of utf-8, utf-16le, utf-16be, windows-1252, iso-8859-1"}`},
+		{map[string]string{"delimiter": ";", "comment": ";"}, `{"error":"invalid comment \";\": must differ from the delimiter"}`},
+		{map[string]string{"comment": ","}, `{"error":"Invalid CSV header: invalid comment \",\": must differ from the detected delimiter"}`},
+		{map[string]string{"lazy_quotes": "maybe"}, `{"error":"invalid lazy_quotes value \"maybe\""}`},
+	}
+	for _, tt := range invalid {
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, newUploadRequest(t, "/validate", "users.csv", "first_name,last_name,email\n", tt.fields))
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+		assert.JSONEq(t, tt.expected, w.Body.String())
+	}
+}
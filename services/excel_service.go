@@ -0,0 +1,152 @@
+package services
+
+import (
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+
+	"github.com/xuri/excelize/v2"
+)
+
+// excelSources selects one worksheet of an Excel workbook. sheet is a sheet name or a
+// 1-based sheet index; when empty the first sheet is used.
+func excelSources(storedPath, filename, sheet string) ([]importSource, error) {
+	workbook, err := excelize.OpenFile(storedPath)
+	if err != nil {
+		return nil, fmt.Errorf("invalid Excel file: %w", err)
+	}
+	defer workbook.Close()
+
+	name, err := selectSheet(workbook.GetSheetList(), sheet)
+	if err != nil {
+		return nil, err
+	}
+	return []importSource{{
+		Name:  filename + "/" + name,
+		open:  func() (recordReader, error) { return openExcelSheet(storedPath, name) },
+		typed: true,
+	}}, nil
+}
+
+// selectSheet resolves a sheet name or 1-based index. Names take precedence, so a sheet
+// called "2" is picked over the second sheet.
+func selectSheet(sheets []string, sheet string) (string, error) {
+	if len(sheets) == 0 {
+		return "", fmt.Errorf("workbook has no sheets")
+	}
+	sheet = strings.TrimSpace(sheet)
+	if sheet == "" {
+		return sheets[0], nil
+	}
+	for _, name := range sheets {
+		if name == sheet {
+			return name, nil
+		}
+	}
+	if idx, err := strconv.Atoi(sheet); err == nil && idx >= 1 && idx <= len(sheets) {
+		return sheets[idx-1], nil
+	}
+	return "", fmt.Errorf("sheet %q not found, workbook has sheets: %s", sheet, strings.Join(sheets, ", "))
+}
+
+// excelRecordReader reads the rows of one worksheet. Cells are read unformatted so
+// numbers keep their full precision; native dates in the DateJoined column are
+// converted once the column mapping is known, see useColumns.
+type excelRecordReader struct {
+	workbook *excelize.File
+	rows     *excelize.Rows
+	date1904 bool
+	dateCol  int // Column holding DateJoined, -1 when unknown
+	width    int // Number of header cells; rows with blank trailing cells are padded to it
+	line     int
+	header   bool // Whether the header row has been returned
+	done     bool
+}
+
+func openExcelSheet(storedPath, sheet string) (recordReader, error) {
+	workbook, err := excelize.OpenFile(storedPath)
+	if err != nil {
+		return nil, fmt.Errorf("invalid Excel file: %w", err)
+	}
+	rows, err := workbook.Rows(sheet)
+	if err != nil {
+		workbook.Close()
+		return nil, err
+	}
+	date1904 := false
+	if props, err := workbook.GetWorkbookProps(); err == nil && props.Date1904 != nil {
+		date1904 = *props.Date1904
+	}
+	return &excelRecordReader{workbook: workbook, rows: rows, date1904: date1904, dateCol: -1}, nil
+}
+
+// Read returns the next non-empty row. Blank rows are skipped, as a CSV reader skips
+// blank lines, but still count towards the row number.
+func (e *excelRecordReader) Read() ([]string, int, error) {
+	for !e.done {
+		if !e.rows.Next() {
+			e.done = true
+			if err := e.rows.Error(); err != nil {
+				return nil, e.line, err
+			}
+			break
+		}
+		e.line++
+		record, err := e.rows.Columns(excelize.Options{RawCellValue: true})
+		if err != nil {
+			return record, e.line, err
+		}
+		if blankRow(record) {
+			continue
+		}
+		if !e.header {
+			e.width = len(record)
+		}
+		// Trailing empty cells are not returned, unlike the empty fields of a CSV line
+		for len(record) < e.width {
+			record = append(record, "")
+		}
+		if e.header && e.dateCol >= 0 && e.dateCol < len(record) {
+			record[e.dateCol] = excelDate(record[e.dateCol], e.date1904)
+		}
+		e.header = true
+		return record, e.line, nil
+	}
+	return nil, e.line, io.EOF
+}
+
+// useColumns tells the reader which column holds DateJoined.
+func (e *excelRecordReader) useColumns(columns columnMap) {
+	if idx, ok := columns.fields[FieldDateJoined]; ok {
+		e.dateCol = idx
+	}
+}
+
+func (e *excelRecordReader) Close() error {
+	e.rows.Close()
+	return e.workbook.Close()
+}
+
+// excelDate converts a native Excel date serial to YYYY-MM-DD. Text cells, which are
+// not numbers, are returned unchanged.
+func excelDate(value string, date1904 bool) string {
+	serial, err := strconv.ParseFloat(strings.TrimSpace(value), 64)
+	if err != nil || serial <= 0 {
+		return value
+	}
+	date, err := excelize.ExcelDateToTime(serial, date1904)
+	if err != nil {
+		return value
+	}
+	return date.Format("2006-01-02")
+}
+
+func blankRow(record []string) bool {
+	for _, cell := range record {
+		if strings.TrimSpace(cell) != "" {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,230 @@
+package services
+
+import (
+	"bytes"
+	"csv-microservice/mock"
+	"csv-microservice/models"
+	"csv-microservice/utils"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/assert"
+	"github.com/xuri/excelize/v2"
+)
+
+// workbookContent builds an .xlsx file with a "Users" sheet holding a native date cell,
+// followed by an "Archive" sheet.
+func workbookContent(t *testing.T) string {
+	workbook := excelize.NewFile()
+	defer workbook.Close()
+
+	assert.NoError(t, workbook.SetSheetName("Sheet1", "Users"))
+	assert.NoError(t, workbook.SetSheetRow("Users", "A1", &[]interface{}{"ID", "First Name", "Last Name", "Email", "Salary", "Join Date", "Active"}))
+	assert.NoError(t, workbook.SetSheetRow("Users", "A2", &[]interface{}{1, "John", "Doe", "john@example.com", 50000.5, time.Date(2024, 1, 15, 0, 0, 0, 0, time.UTC), true}))
+	// Row 3 is left blank; row 4 has a date typed as text.
+	assert.NoError(t, workbook.SetSheetRow("Users", "A4", &[]interface{}{2, "Jane", "Roe", "jane@example.com", 60000, "2023-06-01", false}))
+
+	_, err := workbook.NewSheet("Archive")
+	assert.NoError(t, err)
+	assert.NoError(t, workbook.SetSheetRow("Archive", "A1", &[]interface{}{"first_name", "last_name", "email"}))
+	assert.NoError(t, workbook.SetSheetRow("Archive", "A2", &[]interface{}{"Old", "User", "old@example.com"}))
+
+	var buf bytes.Buffer
+	assert.NoError(t, workbook.Write(&buf))
+	return buf.String()
+}
+
+func TestSelectSheet(t *testing.T) {
+	sheets := []string{"Users", "2", "Archive"}
+
+	tests := []struct {
+		sheet    string
+		expected string
+		err      string
+	}{
+		{"", "Users", ""},
+		{"Archive", "Archive", ""},
+		{"3", "Archive", ""},
+		{"2", "2", ""}, // A sheet name wins over an index
+		{"1", "Users", ""},
+		{"4", "", `sheet "4" not found, workbook has sheets: Users, 2, Archive`},
+		{"users", "", `sheet "users" not found, workbook has sheets: Users, 2, Archive`},
+	}
+
+	for _, tt := range tests {
+		name, err := selectSheet(sheets, tt.sheet)
+		if tt.err != "" {
+			assert.EqualError(t, err, tt.err)
+			continue
+		}
+		assert.NoError(t, err)
+		assert.Equal(t, tt.expected, name)
+	}
+}
+
+func TestExcelRecordReader_BlankTrailingCells(t *testing.T) {
+	workbook := excelize.NewFile()
+	assert.NoError(t, workbook.SetSheetRow("Sheet1", "A1", &[]interface{}{"first_name", "last_name", "email", "is_active"}))
+	assert.NoError(t, workbook.SetSheetRow("Sheet1", "A2", &[]interface{}{"John", "Doe", "john@example.com"}))
+	path := filepath.Join(t.TempDir(), "users.xlsx")
+	assert.NoError(t, workbook.SaveAs(path))
+	workbook.Close()
+
+	reader, err := openExcelSheet(path, "Sheet1")
+	assert.NoError(t, err)
+	defer reader.Close()
+	_, _, err = reader.Read()
+	assert.NoError(t, err)
+	record, line, err := reader.Read()
+	assert.NoError(t, err)
+	assert.Equal(t, 2, line)
+	assert.Equal(t, []string{"John", "Doe", "john@example.com", ""}, record)
+}
+
+func TestExcelDate(t *testing.T) {
+	assert.Equal(t, "2024-01-15", excelDate("45306", false))
+	assert.Equal(t, "2024-01-15", excelDate("45306.75", false)) // Time of day is dropped
+	assert.Equal(t, "2028-01-16", excelDate("45306", true))
+	assert.Equal(t, "2023-06-01", excelDate("2023-06-01", false))
+	assert.Equal(t, "", excelDate("", false))
+}
+
+func TestUploadCSV_Excel(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockRepo := mock.NewMockRepositoryInterface(ctrl)
+	expectImportAudit(mockRepo)
+	service := NewService(mockRepo)
+	utils.InitLogger()
+
+	router := gin.Default()
+	router.POST("/upload", service.UploadCSV)
+	content := workbookContent(t)
+
+	t.Run("First sheet", func(t *testing.T) {
+		var inserted []models.User
+		mockRepo.EXPECT().BulkInsert(gomock.Any()).DoAndReturn(func(users []models.User) error {
+			inserted = append(inserted, users...)
+			return nil
+		}).MinTimes(1)
+
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, newUploadRequest(t, "/upload", "users.xlsx", content, nil))
+		assert.Equal(t, http.StatusAccepted, w.Code)
+
+		var resp struct {
+			JobID string `json:"job_id"`
+		}
+		assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &resp))
+		job, ok := service.Jobs.Get(resp.JobID)
+		assert.True(t, ok)
+		job.Wait()
+
+		snapshot := job.Snapshot()
+		assert.Equal(t, "users.xlsx/Users", snapshot.Filename)
+		assert.Equal(t, 2, snapshot.RowsInserted)
+		assert.ElementsMatch(t, []models.User{
+			{Id: 1, FirstName: "John", LastName: "Doe", Email: "john@example.com", Salary: 50000.5, DateJoined: "2024-01-15", IsActive: true},
+			{Id: 2, FirstName: "Jane", LastName: "Roe", Email: "jane@example.com", Salary: 60000, DateJoined: "2023-06-01"},
+		}, withoutImportIDs(inserted))
+	})
+
+	t.Run("Sheet by name in dry run", func(t *testing.T) {
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, newUploadRequest(t, "/upload?dry_run=true", "users.xlsx", content, map[string]string{"sheet": "Archive"}))
+		assert.Equal(t, http.StatusOK, w.Code)
+
+		var resp struct {
+			Data struct {
+				Filename  string        `json:"filename"`
+				RowsValid int           `json:"rows_valid"`
+				Preview   []models.User `json:"preview"`
+			} `json:"data"`
+		}
+		assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &resp))
+		assert.Equal(t, "users.xlsx/Archive", resp.Data.Filename)
+		assert.Equal(t, 1, resp.Data.RowsValid)
+		assert.Equal(t, []models.User{{FirstName: "Old", LastName: "User", Email: "old@example.com"}}, resp.Data.Preview)
+	})
+
+	t.Run("Row numbers skip blank rows", func(t *testing.T) {
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, newUploadRequest(t, "/upload?dry_run=true", "users.xlsx", content, map[string]string{
+			"sheet": "1",
+			// Reading last names as ages makes every data row fail.
+			"mapping": `{"aliases":{"age":["Last Name"]},"required":["email"]}`,
+		}))
+		assert.Equal(t, http.StatusOK, w.Code)
+
+		var resp struct {
+			Data struct {
+				Errors []models.RowError `json:"errors"`
+			} `json:"data"`
+		}
+		assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &resp))
+		if assert.Len(t, resp.Data.Errors, 2) {
+			assert.Equal(t, 2, resp.Data.Errors[0].Line)
+			assert.Equal(t, 4, resp.Data.Errors[1].Line)
+		}
+	})
+
+	t.Run("Unknown sheet", func(t *testing.T) {
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, newUploadRequest(t, "/upload", "users.xlsx", content, map[string]string{"sheet": "Missing"}))
+
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+		assert.JSONEq(t, `{"error":"Invalid upload: sheet \"Missing\" not found, workbook has sheets: Users, Archive"}`, w.Body.String())
+	})
+
+	t.Run("Not a workbook", func(t *testing.T) {
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, newUploadRequest(t, "/upload", "users.xlsx", "id,name\n", nil))
+
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+		assert.Contains(t, w.Body.String(), "Invalid upload: invalid Excel file")
+	})
+}
+
+func TestUploadCSV_ExcelLocale(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockRepo := mock.NewMockRepositoryInterface(ctrl)
+	expectImportAudit(mockRepo)
+	service := NewService(mockRepo)
+	utils.InitLogger()
+
+	router := gin.Default()
+	router.POST("/upload", service.UploadCSV)
+	content := workbookContent(t)
+
+	// Raw numbers use '.' as decimal point whatever the locale
+	var inserted []models.User
+	mockRepo.EXPECT().BulkInsert(gomock.Any()).DoAndReturn(func(users []models.User) error {
+		inserted = append(inserted, users...)
+		return nil
+	}).MinTimes(1)
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, newUploadRequest(t, "/upload", "users.xlsx", content, map[string]string{"locale": LocaleDE}))
+	assert.Equal(t, http.StatusAccepted, w.Code)
+
+	var resp struct {
+		JobID string `json:"job_id"`
+	}
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &resp))
+	job, ok := service.Jobs.Get(resp.JobID)
+	assert.True(t, ok)
+	job.Wait()
+
+	if assert.Equal(t, 2, job.Snapshot().RowsInserted) {
+		assert.ElementsMatch(t, []float64{50000.5, 60000}, []float64{inserted[0].Salary, inserted[1].Salary})
+	}
+}
@@ -0,0 +1,228 @@
+package services
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"csv-microservice/models"
+	"csv-microservice/utils"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// idempotencyKeyHeader names a request so a retry of it replays the first response.
+const idempotencyKeyHeader = "Idempotency-Key"
+
+// idempotencyReplayHeader marks a response replayed for a retried request.
+const idempotencyReplayHeader = "Idempotent-Replayed"
+
+// idempotencyKeyTTL is how long a key is remembered. A key sent again after that starts a
+// new request.
+const idempotencyKeyTTL = 24 * time.Hour
+
+// idempotencyKeyLease is how long a request may hold a key without storing a response.
+// A key still in progress after that belongs to a request whose process died, and the
+// next request with the key runs instead of being refused.
+const idempotencyKeyLease = 15 * time.Minute
+
+// maxIdempotencyKeyLength bounds the keys clients may send.
+const maxIdempotencyKeyLength = 255
+
+// recordingWriter keeps a copy of the response body written through it.
+type recordingWriter struct {
+	gin.ResponseWriter
+	body bytes.Buffer
+}
+
+func (w *recordingWriter) Write(data []byte) (int, error) {
+	w.body.Write(data)
+	return w.ResponseWriter.Write(data)
+}
+
+func (w *recordingWriter) WriteString(s string) (int, error) {
+	w.body.WriteString(s)
+	return w.ResponseWriter.WriteString(s)
+}
+
+// idempotent runs handler once per Idempotency-Key. A retry with the same key gets the
+// stored response of the first request; one sent while the first is still running is
+// refused, and one with another query string, form or body is rejected. Server errors and
+// panics are not stored, so the request can be retried. Requests without the header
+// always run.
+func (s *Service) idempotent(ctx *gin.Context, source string, handler func()) {
+	key := ctx.GetHeader(idempotencyKeyHeader)
+	if key == "" {
+		handler()
+		return
+	}
+	if len(key) > maxIdempotencyKeyLength {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("Idempotency-Key must be at most %d characters", maxIdempotencyKeyLength)})
+		return
+	}
+
+	fingerprint, release, err := s.requestFingerprint(ctx)
+	if err != nil {
+		utils.LogWarn(source, "Failed to read request: "+err.Error())
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "Failed to read request: " + err.Error()})
+		return
+	}
+	defer release()
+
+	record := models.IdempotencyKey{Key: key, Method: ctx.Request.Method, Path: ctx.Request.URL.Path, Fingerprint: fingerprint}
+	now := time.Now()
+	reserved, err := s.Repo.ReserveIdempotencyKey(&record, now.Add(-idempotencyKeyTTL), now.Add(-idempotencyKeyLease))
+	if err != nil {
+		utils.LogError(source, "Failed to reserve idempotency key", err)
+		ctx.JSON(http.StatusInternalServerError, gin.H{
+			"status":  "error",
+			"message": "Failed to check Idempotency-Key",
+		})
+		return
+	}
+	if !reserved {
+		switch {
+		case record.Method != ctx.Request.Method || record.Path != ctx.Request.URL.Path || record.Fingerprint != fingerprint:
+			ctx.JSON(http.StatusUnprocessableEntity, gin.H{
+				"status":  "error",
+				"message": "Idempotency-Key was already used for a different request",
+			})
+		case record.Status == 0:
+			ctx.JSON(http.StatusConflict, gin.H{
+				"status":  "error",
+				"message": "A request with this Idempotency-Key is still in progress",
+			})
+		default:
+			utils.LogInfo(source, "Replaying response for idempotency key "+key)
+			ctx.Header(idempotencyReplayHeader, "true")
+			ctx.Data(record.Status, record.ContentType, record.Body)
+		}
+		return
+	}
+
+	writer := &recordingWriter{ResponseWriter: ctx.Writer}
+	ctx.Writer = writer
+	defer func() {
+		if recovered := recover(); recovered != nil {
+			if err := s.Repo.DeleteIdempotencyKey(key); err != nil {
+				utils.LogError(source, "Failed to release idempotency key "+key, err)
+			}
+			panic(recovered)
+		}
+	}()
+	handler()
+
+	if writer.Status() >= http.StatusInternalServerError {
+		err = s.Repo.DeleteIdempotencyKey(key)
+	} else {
+		record.Status = writer.Status()
+		record.ContentType = writer.Header().Get("Content-Type")
+		record.Body = writer.body.Bytes()
+		err = s.Repo.SaveIdempotencyKey(&record)
+	}
+	if err != nil {
+		utils.LogError(source, "Failed to store response for idempotency key "+key, err)
+	}
+}
+
+// requestFingerprint hashes what a retry must repeat besides the method and path: the
+// query string, plus the form fields and files of a multipart upload or the body of any
+// other request. Reading the body consumes it, so it is replaced by a copy the handler
+// reads instead; release removes that copy.
+func (s *Service) requestFingerprint(ctx *gin.Context) (string, func(), error) {
+	hash := sha256.New()
+	fmt.Fprintf(hash, "%s\n", ctx.Request.URL.Query().Encode())
+	release := func() {}
+
+	if strings.HasPrefix(ctx.ContentType(), "multipart/form-data") {
+		// Hash the parsed form rather than the raw body, whose boundary changes per request
+		form, err := ctx.MultipartForm()
+		if err != nil {
+			return "", release, err
+		}
+		fields := make([]string, 0, len(form.Value))
+		for name := range form.Value {
+			fields = append(fields, name)
+		}
+		sort.Strings(fields)
+		for _, name := range fields {
+			fmt.Fprintf(hash, "%s=%q\n", name, form.Value[name])
+		}
+		files := make([]string, 0, len(form.File))
+		for name := range form.File {
+			files = append(files, name)
+		}
+		sort.Strings(files)
+		for _, name := range files {
+			for _, header := range form.File[name] {
+				fmt.Fprintf(hash, "%s:%q\n", name, header.Filename)
+				file, err := header.Open()
+				if err != nil {
+					return "", release, err
+				}
+				_, err = io.Copy(hash, file)
+				file.Close()
+				if err != nil {
+					return "", release, err
+				}
+			}
+		}
+	} else if ctx.Request.Body != nil && ctx.Request.Body != http.NoBody {
+		tmp, err := os.CreateTemp(s.UploadDir, "request-*")
+		if err != nil {
+			return "", release, err
+		}
+		release = func() {
+			tmp.Close()
+			os.Remove(tmp.Name())
+		}
+		if _, err := io.Copy(io.MultiWriter(tmp, hash), ctx.Request.Body); err != nil {
+			release()
+			return "", func() {}, err
+		}
+		if _, err := tmp.Seek(0, io.SeekStart); err != nil {
+			release()
+			return "", func() {}, err
+		}
+		ctx.Request.Body = tmp
+	}
+	return hex.EncodeToString(hash.Sum(nil)), release, nil
+}
+
+// duplicateUpload refuses an upload whose content was imported before, responding with
+// the earlier import. It returns false when the upload may go ahead: for a dry run, when
+// forced with ?force=true, or when no earlier import wrote rows from the same file.
+func (s *Service) duplicateUpload(ctx *gin.Context, source string, upload uploadInfo, opts importOptions) bool {
+	if opts.DryRun || opts.Force {
+		return false
+	}
+	earlier, err := s.Repo.FindImportBySHA256(upload.SHA256)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return false
+	}
+	if err != nil {
+		utils.LogError(source, "Failed to look up earlier imports", err)
+		ctx.JSON(http.StatusInternalServerError, gin.H{
+			"status":  "error",
+			"message": "Failed to check for earlier imports",
+		})
+		return true
+	}
+
+	utils.LogWarn(source, fmt.Sprintf("Refused upload of %s already imported as %s", earlier.Filename, earlier.ID))
+	ctx.JSON(http.StatusConflict, gin.H{
+		"status":    "error",
+		"message":   "This file was already imported; send force=true to import it again",
+		"import_id": earlier.ID,
+		"state":     earlier.State,
+	})
+	return true
+}
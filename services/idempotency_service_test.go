@@ -0,0 +1,209 @@
+package services
+
+import (
+	"context"
+	"csv-microservice/mock"
+	"csv-microservice/models"
+	"csv-microservice/utils"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAddRecord_IdempotencyKey(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockRepo := mock.NewMockRepositoryInterface(ctrl)
+	service := NewService(mockRepo)
+	utils.InitLogger()
+
+	router := gin.Default()
+	router.POST("/add", service.AddRecord)
+
+	add := func(key string) *httptest.ResponseRecorder {
+		req, _ := http.NewRequest(http.MethodPost, "/add", strings.NewReader(`{"first_name":"John","email":"john@example.com"}`))
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("Idempotency-Key", key)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w
+	}
+
+	t.Run("First request and retry", func(t *testing.T) {
+		var stored models.IdempotencyKey
+		mockRepo.EXPECT().ReserveIdempotencyKey(gomock.Any(), gomock.Any(), gomock.Any()).DoAndReturn(func(key *models.IdempotencyKey, expiredBefore, abandonedBefore time.Time) (bool, error) {
+			assert.Equal(t, "req-1", key.Key)
+			assert.WithinDuration(t, time.Now().Add(-idempotencyKeyTTL), expiredBefore, time.Minute)
+			assert.WithinDuration(t, time.Now().Add(-idempotencyKeyLease), abandonedBefore, time.Minute)
+			assert.NotEmpty(t, key.Fingerprint)
+			return true, nil
+		})
+		mockRepo.EXPECT().InsertRecord(gomock.Any(), gomock.Any()).Return(nil).Times(1)
+		mockRepo.EXPECT().SaveIdempotencyKey(gomock.Any()).DoAndReturn(func(key *models.IdempotencyKey) error {
+			stored = *key
+			return nil
+		})
+
+		first := add("req-1")
+		assert.Equal(t, http.StatusOK, first.Code)
+		assert.Equal(t, http.MethodPost, stored.Method)
+		assert.Equal(t, "/add", stored.Path)
+		assert.Equal(t, http.StatusOK, stored.Status)
+		assert.Equal(t, first.Body.String(), string(stored.Body))
+
+		// The retry is answered from the stored response without inserting again
+		mockRepo.EXPECT().ReserveIdempotencyKey(gomock.Any(), gomock.Any(), gomock.Any()).DoAndReturn(func(key *models.IdempotencyKey, expiredBefore, abandonedBefore time.Time) (bool, error) {
+			*key = stored
+			return false, nil
+		})
+		retry := add("req-1")
+		assert.Equal(t, http.StatusOK, retry.Code)
+		assert.Equal(t, "true", retry.Header().Get("Idempotent-Replayed"))
+		assert.Equal(t, first.Body.String(), retry.Body.String())
+	})
+
+	t.Run("Still running", func(t *testing.T) {
+		mockRepo.EXPECT().ReserveIdempotencyKey(gomock.Any(), gomock.Any(), gomock.Any()).DoAndReturn(func(key *models.IdempotencyKey, expiredBefore, abandonedBefore time.Time) (bool, error) {
+			*key = models.IdempotencyKey{Key: key.Key, Method: http.MethodPost, Path: "/add", Fingerprint: key.Fingerprint}
+			return false, nil
+		})
+
+		w := add("req-2")
+		assert.Equal(t, http.StatusConflict, w.Code)
+		assert.JSONEq(t, `{"status":"error","message":"A request with this Idempotency-Key is still in progress"}`, w.Body.String())
+	})
+
+	t.Run("Key of another request", func(t *testing.T) {
+		mockRepo.EXPECT().ReserveIdempotencyKey(gomock.Any(), gomock.Any(), gomock.Any()).DoAndReturn(func(key *models.IdempotencyKey, expiredBefore, abandonedBefore time.Time) (bool, error) {
+			*key = models.IdempotencyKey{Key: key.Key, Method: http.MethodPost, Path: "/upload", Status: http.StatusAccepted}
+			return false, nil
+		})
+
+		w := add("req-3")
+		assert.Equal(t, http.StatusUnprocessableEntity, w.Code)
+		assert.JSONEq(t, `{"status":"error","message":"Idempotency-Key was already used for a different request"}`, w.Body.String())
+	})
+
+	t.Run("Key of a request with another body", func(t *testing.T) {
+		mockRepo.EXPECT().ReserveIdempotencyKey(gomock.Any(), gomock.Any(), gomock.Any()).DoAndReturn(func(key *models.IdempotencyKey, expiredBefore, abandonedBefore time.Time) (bool, error) {
+			*key = models.IdempotencyKey{Key: key.Key, Method: http.MethodPost, Path: "/add", Fingerprint: "other", Status: http.StatusOK}
+			return false, nil
+		})
+
+		w := add("req-5")
+		assert.Equal(t, http.StatusUnprocessableEntity, w.Code)
+		assert.JSONEq(t, `{"status":"error","message":"Idempotency-Key was already used for a different request"}`, w.Body.String())
+	})
+
+	t.Run("Panic releases the key", func(t *testing.T) {
+		mockRepo.EXPECT().ReserveIdempotencyKey(gomock.Any(), gomock.Any(), gomock.Any()).Return(true, nil)
+		mockRepo.EXPECT().InsertRecord(gomock.Any(), gomock.Any()).DoAndReturn(func(ctx context.Context, record interface{}) error {
+			panic("lost connection")
+		})
+		mockRepo.EXPECT().DeleteIdempotencyKey("req-6").Return(nil)
+
+		w := add("req-6")
+		assert.Equal(t, http.StatusInternalServerError, w.Code)
+	})
+
+	t.Run("Server error releases the key", func(t *testing.T) {
+		mockRepo.EXPECT().ReserveIdempotencyKey(gomock.Any(), gomock.Any(), gomock.Any()).Return(true, nil)
+		mockRepo.EXPECT().InsertRecord(gomock.Any(), gomock.Any()).Return(errors.New("db error"))
+		mockRepo.EXPECT().DeleteIdempotencyKey("req-4").Return(nil)
+
+		w := add("req-4")
+		assert.Equal(t, http.StatusInternalServerError, w.Code)
+	})
+
+	t.Run("Key too long", func(t *testing.T) {
+		w := add(strings.Repeat("k", maxIdempotencyKeyLength+1))
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+	})
+}
+
+func TestUploadCSV_Duplicate(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockRepo := mock.NewMockRepositoryInterface(ctrl)
+	service := NewService(mockRepo)
+	utils.InitLogger()
+
+	router := gin.Default()
+	router.POST("/upload", service.UploadCSV)
+
+	content := "first_name,last_name,email\nJohn,Doe,john@example.com\n"
+
+	t.Run("Refused", func(t *testing.T) {
+		mockRepo.EXPECT().FindImportBySHA256(gomock.Any()).Return(models.Import{ID: "abc", Filename: "users.csv", State: models.JobCompleted}, nil)
+
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, newUploadRequest(t, "/upload", "users.csv", content, nil))
+
+		assert.Equal(t, http.StatusConflict, w.Code)
+		assert.JSONEq(t, `{"status":"error","message":"This file was already imported; send force=true to import it again","import_id":"abc","state":"completed"}`, w.Body.String())
+	})
+
+	t.Run("Forced", func(t *testing.T) {
+		expectImportAudit(mockRepo)
+		mockRepo.EXPECT().BulkInsert(gomock.Len(1)).Return(nil)
+
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, newUploadRequest(t, "/upload", "users.csv", content, map[string]string{"force": "true"}))
+
+		assert.Equal(t, http.StatusAccepted, w.Code)
+		for _, job := range service.Jobs.List(10) {
+			stored, _ := service.Jobs.Get(job.ID)
+			stored.Wait()
+		}
+	})
+}
+
+func TestRequestFingerprint(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	service := NewService(mock.NewMockRepositoryInterface(ctrl))
+	service.UploadDir = t.TempDir()
+
+	fingerprint := func(req *http.Request) string {
+		ctx, _ := gin.CreateTestContext(httptest.NewRecorder())
+		ctx.Request = req
+		fingerprint, release, err := service.requestFingerprint(ctx)
+		assert.NoError(t, err)
+		release()
+		return fingerprint
+	}
+
+	content := "first_name,last_name,email\nJohn,Doe,john@example.com\n"
+	fields := map[string]string{"mode": models.ModeUpsertEmail}
+	upload := fingerprint(newUploadRequest(t, "/upload", "users.csv", content, fields))
+
+	// Each request has its own multipart boundary, so only the form itself may count
+	assert.Equal(t, upload, fingerprint(newUploadRequest(t, "/upload", "users.csv", content, fields)))
+	assert.NotEqual(t, upload, fingerprint(newUploadRequest(t, "/upload", "users.csv", content+"Jane,Roe,jane@example.com\n", fields)))
+	assert.NotEqual(t, upload, fingerprint(newUploadRequest(t, "/upload", "users.csv", content, nil)))
+	assert.NotEqual(t, upload, fingerprint(newUploadRequest(t, "/upload?dry_run=true", "users.csv", content, fields)))
+
+	t.Run("Body stays readable", func(t *testing.T) {
+		ctx, _ := gin.CreateTestContext(httptest.NewRecorder())
+		ctx.Request, _ = http.NewRequest(http.MethodPost, "/upload/json", strings.NewReader(`[{"email":"john@example.com"}]`))
+		ctx.Request.Header.Set("Content-Type", "application/json")
+		_, release, err := service.requestFingerprint(ctx)
+		assert.NoError(t, err)
+		defer release()
+
+		body, err := io.ReadAll(ctx.Request.Body)
+		assert.NoError(t, err)
+		assert.Equal(t, `[{"email":"john@example.com"}]`, string(body))
+	})
+}
@@ -0,0 +1,248 @@
+package services
+
+import (
+	"crypto/sha256"
+	"crypto/subtle"
+	"csv-microservice/models"
+	"csv-microservice/utils"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// uploaderHeader identifies who sent an upload. Requests without it are recorded by client IP.
+const uploaderHeader = "X-Uploader"
+
+// uploadInfo describes the uploaded file an import came from.
+type uploadInfo struct {
+	Size     int64
+	SHA256   string
+	Uploader string
+	Filename string // Name the file was uploaded with
+	Blob     string // Key of the file in the blob archive; empty when it was not archived
+}
+
+// uploader identifies who sent a request: the reviewer whose token it carries, otherwise
+// the X-Uploader header or the client IP. Only a token is authenticated.
+func (s *Service) uploader(ctx *gin.Context) string {
+	if name, ok := s.authenticatedReviewer(ctx); ok {
+		return name
+	}
+	if name := ctx.GetHeader(uploaderHeader); name != "" {
+		return name
+	}
+	return ctx.ClientIP()
+}
+
+// authenticatedReviewer returns the name of the reviewer whose token the request carries
+// as "Authorization: Bearer <token>".
+func (s *Service) authenticatedReviewer(ctx *gin.Context) (string, bool) {
+	token, ok := strings.CutPrefix(ctx.GetHeader("Authorization"), "Bearer ")
+	if !ok || token == "" {
+		return "", false
+	}
+	for known, name := range s.Reviewers {
+		if subtle.ConstantTimeCompare([]byte(token), []byte(known)) == 1 {
+			return name, true
+		}
+	}
+	return "", false
+}
+
+// storeUpload copies an upload to tmp, hashing it on the way, and closes tmp.
+func storeUpload(tmp *os.File, r io.Reader, uploadedBy string) (uploadInfo, error) {
+	hash := sha256.New()
+	size, err := io.Copy(io.MultiWriter(tmp, hash), r)
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return uploadInfo{}, err
+	}
+	return uploadInfo{Size: size, SHA256: hex.EncodeToString(hash.Sum(nil)), Uploader: uploadedBy}, nil
+}
+
+// saveImport writes the current state of an import to the imports table. A failure is
+// logged but does not stop the import.
+func (s *Service) saveImport(task *importTask) {
+	snapshot := task.job.Snapshot()
+	record := models.Import{
+		ID:           snapshot.ID,
+		Filename:     snapshot.Filename,
+		Size:         task.upload.Size,
+		SHA256:       task.upload.SHA256,
+		Uploader:     task.upload.Uploader,
+		Upload:       task.upload.Filename,
+		Blob:         task.upload.Blob,
+		State:        snapshot.State,
+		Mode:         snapshot.Mode,
+		Merge:        task.opts.Merge,
+		Transaction:  snapshot.Transaction,
+		Loader:       snapshot.Loader,
+		Locale:       task.opts.Locale,
+		RowsRead:     snapshot.RowsRead,
+		RowsInserted: snapshot.RowsInserted,
+		RowsUpdated:  snapshot.RowsUpdated,
+		RowsSkipped:  snapshot.RowsSkipped,
+		RowsFailed:   snapshot.RowsFailed,
+		Checkpoint:   snapshot.Checkpoint,
+		Error:        snapshot.Error,
+		CreatedAt:    snapshot.CreatedAt,
+		StartedAt:    snapshot.StartedAt,
+		FinishedAt:   snapshot.FinishedAt,
+	}
+	if task.opts.Stage {
+		record.Missing = task.opts.Missing
+		if snapshot.State == models.JobCompleted {
+			record.State = models.ImportStaged
+		}
+	}
+	if task.resume != nil && task.resumable() && snapshot.State != models.JobCompleted {
+		state, _ := json.Marshal(task.resume)
+		record.ResumeState = string(state)
+	}
+	if err := s.Repo.SaveImport(&record); err != nil {
+		utils.LogError("saveImport", "Failed to record import "+snapshot.ID, err)
+	}
+}
+
+func (s *Service) ListImports(ctx *gin.Context) {
+	page, err := strconv.Atoi(ctx.DefaultQuery("page", "1"))
+	if err != nil || page < 1 {
+		page = 1
+	}
+	limit, err := strconv.Atoi(ctx.DefaultQuery("limit", "10"))
+	if err != nil || limit < 1 {
+		limit = 10
+	}
+
+	imports, total, err := s.Repo.ListImports((page-1)*limit, limit)
+	if err != nil {
+		utils.LogError("ListImports", "Error fetching imports from database", err)
+		ctx.JSON(http.StatusInternalServerError, gin.H{
+			"status":  "error",
+			"message": "Failed to fetch imports",
+		})
+		return
+	}
+	utils.LogInfo("ListImports", fmt.Sprintf("Fetched %d imports for page: %d with limit: %d", len(imports), page, limit))
+
+	ctx.JSON(http.StatusOK, gin.H{
+		"status": "success",
+		"data":   imports,
+		"meta": gin.H{
+			"page":  page,
+			"limit": limit,
+			"total": total,
+		},
+	})
+}
+
+func (s *Service) GetImport(ctx *gin.Context) {
+	id := ctx.Param("id")
+	record, err := s.Repo.GetImport(id)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		utils.LogWarn("GetImport", "Import not found: "+id)
+		ctx.JSON(http.StatusNotFound, gin.H{
+			"status":  "error",
+			"message": "Import not found",
+		})
+		return
+	}
+	if err != nil {
+		utils.LogError("GetImport", "Error fetching import from database", err)
+		ctx.JSON(http.StatusInternalServerError, gin.H{
+			"status":  "error",
+			"message": "Failed to fetch import",
+		})
+		return
+	}
+
+	ctx.JSON(http.StatusOK, gin.H{
+		"status": "success",
+		"data":   record,
+	})
+}
+
+// RevertImport removes the users an import inserted and restores the ones it updated.
+// With ?dry_run=true it only reports the counts.
+func (s *Service) RevertImport(ctx *gin.Context) {
+	id := ctx.Param("id")
+	dryRun, err := strconv.ParseBool(ctx.DefaultQuery("dry_run", "false"))
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("invalid dry_run value %q", ctx.Query("dry_run"))})
+		return
+	}
+
+	record, err := s.Repo.GetImport(id)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		utils.LogWarn("RevertImport", "Import not found: "+id)
+		ctx.JSON(http.StatusNotFound, gin.H{
+			"status":  "error",
+			"message": "Import not found",
+		})
+		return
+	}
+	if err != nil {
+		utils.LogError("RevertImport", "Error fetching import from database", err)
+		ctx.JSON(http.StatusInternalServerError, gin.H{
+			"status":  "error",
+			"message": "Failed to fetch import",
+		})
+		return
+	}
+	switch record.State {
+	case models.JobQueued, models.JobRunning:
+		ctx.JSON(http.StatusConflict, gin.H{
+			"status":  "error",
+			"message": "Import is still running",
+		})
+		return
+	case models.ImportReverted:
+		ctx.JSON(http.StatusConflict, gin.H{
+			"status":  "error",
+			"message": "Import was already reverted",
+		})
+		return
+	case models.ImportStaged:
+		ctx.JSON(http.StatusConflict, gin.H{
+			"status":  "error",
+			"message": "Import is waiting for approval; reject it instead",
+		})
+		return
+	case models.ImportRejected:
+		ctx.JSON(http.StatusConflict, gin.H{
+			"status":  "error",
+			"message": "Import was rejected",
+		})
+		return
+	}
+
+	result, err := s.Repo.RevertImport(id, dryRun)
+	if err != nil {
+		utils.LogError("RevertImport", "Failed to revert import "+id, err)
+		ctx.JSON(http.StatusInternalServerError, gin.H{
+			"status":  "error",
+			"message": "Failed to revert import",
+		})
+		return
+	}
+	if !dryRun {
+		utils.LogInfo("RevertImport", fmt.Sprintf("Reverted import %s: %d deleted, %d restored, %d missing", id, result.Deleted, result.Restored, result.Missing))
+	}
+
+	ctx.JSON(http.StatusOK, gin.H{
+		"status":  "success",
+		"dry_run": dryRun,
+		"data":    result,
+	})
+}
@@ -0,0 +1,329 @@
+package services
+
+import (
+	"crypto/sha256"
+	"csv-microservice/mock"
+	"csv-microservice/models"
+	repository "csv-microservice/repositories"
+	"csv-microservice/utils"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/assert"
+	"gorm.io/gorm"
+)
+
+// expectImportAudit accepts the import and quarantine records written while jobs run,
+// for uploads not imported before.
+func expectImportAudit(mockRepo *mock.MockRepositoryInterface) {
+	expectNewUploads(mockRepo)
+	mockRepo.EXPECT().SaveImport(gomock.Any()).Return(nil).AnyTimes()
+	mockRepo.EXPECT().QuarantineRows(gomock.Any()).Return(nil).AnyTimes()
+	expectChunks(mockRepo)
+}
+
+// expectNewUploads finds no earlier import for any upload.
+func expectNewUploads(mockRepo *mock.MockRepositoryInterface) {
+	mockRepo.EXPECT().FindImportBySHA256(gomock.Any()).Return(models.Import{}, gorm.ErrRecordNotFound).AnyTimes()
+}
+
+// expectChunks runs chunk writes straight against the mock repository.
+func expectChunks(mockRepo *mock.MockRepositoryInterface) {
+	mockRepo.EXPECT().WriteChunk(gomock.Any(), gomock.Any()).DoAndReturn(func(chunk *models.ImportChunk, write func(repo repository.RepositoryInterface) error) error {
+		return write(mockRepo)
+	}).AnyTimes()
+}
+
+// importedUsers matches users written by an import. Every user must carry an import ID,
+// which is otherwise ignored because job IDs are random.
+type importedUsers []models.User
+
+func (m importedUsers) Matches(x interface{}) bool {
+	users, ok := x.([]models.User)
+	if !ok || len(users) != len(m) {
+		return false
+	}
+	for i, user := range users {
+		if user.ImportID == nil {
+			return false
+		}
+		user.ImportID = nil
+		if !reflect.DeepEqual(user, m[i]) {
+			return false
+		}
+	}
+	return true
+}
+
+func (m importedUsers) String() string {
+	return fmt.Sprintf("imported users %v", []models.User(m))
+}
+
+// withoutImportIDs clears the import IDs of users written by an import.
+func withoutImportIDs(users []models.User) []models.User {
+	result := make([]models.User, len(users))
+	for i, user := range users {
+		user.ImportID = nil
+		result[i] = user
+	}
+	return result
+}
+
+func TestUploadCSV_RecordsImport(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockRepo := mock.NewMockRepositoryInterface(ctrl)
+	service := NewService(mockRepo)
+	utils.InitLogger()
+
+	router := gin.Default()
+	router.POST("/upload", service.UploadCSV)
+
+	expectNewUploads(mockRepo)
+	var saved []models.Import
+	mockRepo.EXPECT().SaveImport(gomock.Any()).DoAndReturn(func(record *models.Import) error {
+		saved = append(saved, *record)
+		return nil
+	}).Times(2)
+	var inserted []models.User
+	mockRepo.EXPECT().BulkInsert(gomock.Any()).DoAndReturn(func(users []models.User) error {
+		inserted = append(inserted, users...)
+		return nil
+	}).Times(1)
+	var chunks []models.ImportChunk
+	mockRepo.EXPECT().WriteChunk(gomock.Any(), gomock.Any()).DoAndReturn(func(chunk *models.ImportChunk, write func(repo repository.RepositoryInterface) error) error {
+		err := write(mockRepo)
+		chunks = append(chunks, *chunk)
+		return err
+	}).Times(1)
+
+	content := "first_name,last_name,email\nJohn,Doe,john@example.com\n"
+	req := newUploadRequest(t, "/upload", "users.csv", content, nil)
+	req.Header.Set("X-Uploader", "hr-sync")
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusAccepted, w.Code)
+
+	var resp struct {
+		JobID string `json:"job_id"`
+	}
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &resp))
+	job, _ := service.Jobs.Get(resp.JobID)
+	job.Wait()
+
+	if assert.Len(t, saved, 2) {
+		started, finished := saved[0], saved[1]
+		assert.Equal(t, resp.JobID, started.ID)
+		assert.Equal(t, models.JobRunning, started.State)
+		assert.NotNil(t, started.StartedAt)
+		assert.Nil(t, started.FinishedAt)
+		assert.Contains(t, started.ResumeState, `"filename":"users.csv"`, "The upload is kept until the import completes")
+
+		assert.Equal(t, resp.JobID, finished.ID)
+		assert.Equal(t, "users.csv", finished.Filename)
+		assert.Equal(t, int64(len(content)), finished.Size)
+		assert.Equal(t, fmt.Sprintf("%x", sha256.Sum256([]byte(content))), finished.SHA256)
+		assert.Equal(t, "hr-sync", finished.Uploader)
+		assert.Equal(t, models.JobCompleted, finished.State)
+		assert.Equal(t, models.ModeInsert, finished.Mode)
+		assert.Equal(t, 1, finished.RowsRead)
+		assert.Equal(t, 1, finished.RowsInserted)
+		assert.Equal(t, 1, finished.Checkpoint)
+		assert.Empty(t, finished.ResumeState)
+		assert.NotNil(t, finished.FinishedAt)
+	}
+	if assert.Len(t, inserted, 1) && assert.NotNil(t, inserted[0].ImportID) {
+		assert.Equal(t, resp.JobID, *inserted[0].ImportID)
+	}
+	assert.Equal(t, []models.ImportChunk{{ImportID: resp.JobID, Offset: 0, Rows: 1, Inserted: 1}}, chunks)
+}
+
+func TestListImports(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockRepo := mock.NewMockRepositoryInterface(ctrl)
+	service := NewService(mockRepo)
+	utils.InitLogger()
+
+	router := gin.Default()
+	router.GET("/imports", service.ListImports)
+
+	t.Run("Paginated", func(t *testing.T) {
+		mockRepo.EXPECT().ListImports(5, 5).Return([]models.Import{{ID: "abc", Filename: "users.csv", State: models.JobCompleted}}, int64(6), nil)
+
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest(http.MethodGet, "/imports?page=2&limit=5", nil)
+		router.ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusOK, w.Code)
+		var resp struct {
+			Data []models.Import `json:"data"`
+			Meta struct {
+				Page  int `json:"page"`
+				Limit int `json:"limit"`
+				Total int `json:"total"`
+			} `json:"meta"`
+		}
+		assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &resp))
+		assert.Equal(t, "abc", resp.Data[0].ID)
+		assert.Equal(t, 2, resp.Meta.Page)
+		assert.Equal(t, 6, resp.Meta.Total)
+	})
+
+	t.Run("Database error", func(t *testing.T) {
+		mockRepo.EXPECT().ListImports(0, 10).Return(nil, int64(0), errors.New("db error"))
+
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest(http.MethodGet, "/imports?page=x", nil)
+		router.ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusInternalServerError, w.Code)
+		assert.JSONEq(t, `{"status":"error","message":"Failed to fetch imports"}`, w.Body.String())
+	})
+}
+
+func TestGetImport(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockRepo := mock.NewMockRepositoryInterface(ctrl)
+	service := NewService(mockRepo)
+	utils.InitLogger()
+
+	router := gin.Default()
+	router.GET("/imports/:id", service.GetImport)
+
+	tests := []struct {
+		name           string
+		id             string
+		record         models.Import
+		err            error
+		expectedStatus int
+		expectedBody   string
+	}{
+		{"Found", "abc", models.Import{ID: "abc", Filename: "users.csv", RowsInserted: 3}, nil, http.StatusOK, `"rows_inserted":3`},
+		{"Not found", "nope", models.Import{}, gorm.ErrRecordNotFound, http.StatusNotFound, `{"message":"Import not found","status":"error"}`},
+		{"Database error", "abc", models.Import{}, errors.New("db error"), http.StatusInternalServerError, `{"message":"Failed to fetch import","status":"error"}`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockRepo.EXPECT().GetImport(tt.id).Return(tt.record, tt.err)
+
+			w := httptest.NewRecorder()
+			req, _ := http.NewRequest(http.MethodGet, "/imports/"+tt.id, nil)
+			router.ServeHTTP(w, req)
+
+			assert.Equal(t, tt.expectedStatus, w.Code)
+			assert.Contains(t, w.Body.String(), tt.expectedBody)
+		})
+	}
+}
+
+func TestRevertImport(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockRepo := mock.NewMockRepositoryInterface(ctrl)
+	service := NewService(mockRepo)
+	utils.InitLogger()
+
+	router := gin.Default()
+	router.DELETE("/imports/:id", service.RevertImport)
+
+	completed := models.Import{ID: "abc", State: models.JobCompleted}
+	tests := []struct {
+		name           string
+		target         string
+		mockSetup      func()
+		expectedStatus int
+		expectedBody   string
+	}{
+		{
+			name:   "Dry run",
+			target: "/imports/abc?dry_run=true",
+			mockSetup: func() {
+				mockRepo.EXPECT().GetImport("abc").Return(completed, nil)
+				mockRepo.EXPECT().RevertImport("abc", true).Return(models.RevertResult{Deleted: 3, Restored: 2}, nil)
+			},
+			expectedStatus: http.StatusOK,
+			expectedBody:   `{"status":"success","dry_run":true,"data":{"deleted":3,"restored":2,"missing":0}}`,
+		},
+		{
+			name:   "Revert",
+			target: "/imports/abc",
+			mockSetup: func() {
+				mockRepo.EXPECT().GetImport("abc").Return(models.Import{ID: "abc", State: models.JobFailed}, nil)
+				mockRepo.EXPECT().RevertImport("abc", false).Return(models.RevertResult{Deleted: 1, Missing: 1}, nil)
+			},
+			expectedStatus: http.StatusOK,
+			expectedBody:   `{"status":"success","dry_run":false,"data":{"deleted":1,"restored":0,"missing":1}}`,
+		},
+		{
+			name:   "Not found",
+			target: "/imports/nope",
+			mockSetup: func() {
+				mockRepo.EXPECT().GetImport("nope").Return(models.Import{}, gorm.ErrRecordNotFound)
+			},
+			expectedStatus: http.StatusNotFound,
+			expectedBody:   `{"status":"error","message":"Import not found"}`,
+		},
+		{
+			name:   "Still running",
+			target: "/imports/abc",
+			mockSetup: func() {
+				mockRepo.EXPECT().GetImport("abc").Return(models.Import{ID: "abc", State: models.JobRunning}, nil)
+			},
+			expectedStatus: http.StatusConflict,
+			expectedBody:   `{"status":"error","message":"Import is still running"}`,
+		},
+		{
+			name:   "Already reverted",
+			target: "/imports/abc",
+			mockSetup: func() {
+				mockRepo.EXPECT().GetImport("abc").Return(models.Import{ID: "abc", State: models.ImportReverted}, nil)
+			},
+			expectedStatus: http.StatusConflict,
+			expectedBody:   `{"status":"error","message":"Import was already reverted"}`,
+		},
+		{
+			name:   "Database error",
+			target: "/imports/abc",
+			mockSetup: func() {
+				mockRepo.EXPECT().GetImport("abc").Return(completed, nil)
+				mockRepo.EXPECT().RevertImport("abc", false).Return(models.RevertResult{}, errors.New("db error"))
+			},
+			expectedStatus: http.StatusInternalServerError,
+			expectedBody:   `{"status":"error","message":"Failed to revert import"}`,
+		},
+		{
+			name:           "Invalid dry_run",
+			target:         "/imports/abc?dry_run=maybe",
+			mockSetup:      func() {},
+			expectedStatus: http.StatusBadRequest,
+			expectedBody:   `{"error":"invalid dry_run value \"maybe\""}`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.mockSetup()
+
+			w := httptest.NewRecorder()
+			req, _ := http.NewRequest(http.MethodDelete, tt.target, nil)
+			router.ServeHTTP(w, req)
+
+			assert.Equal(t, tt.expectedStatus, w.Code)
+			assert.JSONEq(t, tt.expectedBody, w.Body.String())
+		})
+	}
+}
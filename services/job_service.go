@@ -0,0 +1,514 @@
+package services
+
+import (
+	"context"
+	"crypto/rand"
+	"csv-microservice/models"
+	"csv-microservice/utils"
+	"encoding/csv"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// maxJobs is the number of jobs kept in memory before the oldest finished ones are dropped.
+const maxJobs = 100
+
+// maxRowErrors is the number of rejected rows a job keeps in memory. RowsFailed counts
+// them all, and the quarantine holds the rest of the rows of imports that store them.
+const maxRowErrors = 1000
+
+// errImportCancelled ends an import that was cancelled through the API.
+var errImportCancelled = errors.New("import cancelled")
+
+// Job wraps an ImportJob with the locking needed to update it from worker goroutines.
+type Job struct {
+	mu        sync.Mutex
+	data      models.ImportJob
+	errors    []models.RowError
+	done      chan struct{}
+	stop      context.CancelFunc // Stops the import's reader and workers
+	cancelled bool
+	chunks    map[int]int // Committed chunks past the checkpoint: offset -> rows
+}
+
+// Snapshot returns a copy of the job's current state.
+func (j *Job) Snapshot() models.ImportJob {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	return j.data
+}
+
+// Wait blocks until the job has finished.
+func (j *Job) Wait() {
+	<-j.done
+}
+
+func (j *Job) start() {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	now := time.Now()
+	j.data.State = models.JobRunning
+	j.data.StartedAt = &now
+}
+
+func (j *Job) finish(err error) {
+	j.mu.Lock()
+	now := time.Now()
+	j.data.FinishedAt = &now
+	if errors.Is(err, errImportCancelled) {
+		j.data.State = models.JobCancelled
+		j.data.Error = err.Error()
+	} else if err != nil {
+		j.data.State = models.JobFailed
+		j.data.Error = err.Error()
+	} else {
+		j.data.State = models.JobCompleted
+	}
+	j.mu.Unlock()
+	close(j.done)
+}
+
+// Cancel asks a running job to stop. Workers finish the batch they are writing. It
+// returns false when the job has already finished.
+func (j *Job) Cancel() bool {
+	j.mu.Lock()
+	if j.finished() {
+		j.mu.Unlock()
+		return false
+	}
+	j.cancelled = true
+	stop := j.stop
+	j.mu.Unlock()
+	if stop != nil {
+		stop()
+	}
+	return true
+}
+
+func (j *Job) isCancelled() bool {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	return j.cancelled
+}
+
+// commitChunk records committed rows and moves the checkpoint past every chunk that is
+// now contiguous with it. Workers commit chunks out of order.
+func (j *Job) commitChunk(offset, rows int) {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	if j.chunks == nil {
+		j.chunks = make(map[int]int)
+	}
+	j.chunks[offset] = rows
+	for {
+		rows, ok := j.chunks[j.data.Checkpoint]
+		if !ok {
+			return
+		}
+		delete(j.chunks, j.data.Checkpoint)
+		j.data.Checkpoint += rows
+	}
+}
+
+// restoreChunks counts the chunks an earlier run of the import committed and takes back
+// the rows they rejected.
+func (j *Job) restoreChunks(chunks []models.ImportChunk) {
+	for _, chunk := range chunks {
+		j.mu.Lock()
+		j.data.RowsRead += chunk.Rows
+		j.data.RowsInserted += chunk.Inserted
+		j.data.RowsUpdated += chunk.Updated
+		j.data.RowsSkipped += chunk.Skipped
+		j.data.RowsFailed += chunk.Failed
+		for _, rowErr := range chunk.Errors {
+			j.keepRowError(rowErr)
+		}
+		j.mu.Unlock()
+		j.commitChunk(chunk.Offset, chunk.Rows)
+	}
+}
+
+func (j *Job) addRead(n int) {
+	j.mu.Lock()
+	j.data.RowsRead += n
+	j.mu.Unlock()
+}
+
+func (j *Job) addResult(result models.WriteResult) {
+	j.mu.Lock()
+	j.data.RowsInserted += result.Inserted
+	j.data.RowsUpdated += result.Updated
+	j.data.RowsSkipped += result.Skipped
+	j.mu.Unlock()
+}
+
+// rollback discards the write counters after the import transaction was rolled back
+// and remembers the row that caused it.
+func (j *Job) rollback() {
+	rowErrors := j.RowErrors()
+
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	j.data.RowsInserted, j.data.RowsUpdated, j.data.RowsSkipped = 0, 0, 0
+	if len(rowErrors) > 0 {
+		j.data.FirstError = &rowErrors[0]
+	}
+}
+
+// rejectRow records a row that could not be imported and counts it as failed.
+func (j *Job) rejectRow(line int, values []string, reason string) {
+	j.mu.Lock()
+	j.data.RowsFailed++
+	j.keepRowError(models.RowError{Line: line, Values: values, Reason: reason})
+	j.mu.Unlock()
+}
+
+// keepRowError stores a rejected row unless maxRowErrors are already kept. The caller
+// holds j.mu.
+func (j *Job) keepRowError(rowErr models.RowError) {
+	if len(j.errors) < maxRowErrors {
+		j.errors = append(j.errors, rowErr)
+	}
+}
+
+// RowErrors returns the kept rejected rows ordered by line number. They are the first
+// maxRowErrors the job rejected.
+func (j *Job) RowErrors() []models.RowError {
+	j.mu.Lock()
+	result := make([]models.RowError, len(j.errors))
+	copy(result, j.errors)
+	j.mu.Unlock()
+
+	sort.Slice(result, func(a, b int) bool { return result[a].Line < result[b].Line })
+	return result
+}
+
+func (j *Job) finished() bool {
+	select {
+	case <-j.done:
+		return true
+	default:
+		return false
+	}
+}
+
+// JobStore keeps recent import jobs in memory.
+type JobStore struct {
+	mu    sync.RWMutex
+	jobs  map[string]*Job
+	order []string // Job IDs, oldest first
+}
+
+func NewJobStore() *JobStore {
+	return &JobStore{jobs: make(map[string]*Job)}
+}
+
+// Create registers a new queued job for the given file and import options.
+func (js *JobStore) Create(filename string, opts importOptions) *Job {
+	job := &Job{
+		data: models.ImportJob{
+			ID:          newJobID(),
+			Filename:    filename,
+			Mode:        opts.Mode,
+			Transaction: opts.Transaction,
+			Loader:      opts.Loader,
+			State:       models.JobQueued,
+			CreatedAt:   time.Now(),
+		},
+		done: make(chan struct{}),
+	}
+
+	js.mu.Lock()
+	defer js.mu.Unlock()
+	js.jobs[job.data.ID] = job
+	js.order = append(js.order, job.data.ID)
+	js.evict()
+	return job
+}
+
+// Restore registers a job that continues the import of an audit record under the same ID.
+// It returns false when a job with that ID is still running.
+func (js *JobStore) Restore(record models.Import, opts importOptions) (*Job, bool) {
+	job := newJob(record.Filename, opts)
+	job.data.ID = record.ID
+	job.data.CreatedAt = record.CreatedAt
+
+	js.mu.Lock()
+	defer js.mu.Unlock()
+	if existing, ok := js.jobs[record.ID]; ok {
+		if !existing.finished() {
+			return nil, false
+		}
+		for i, id := range js.order {
+			if id == record.ID {
+				js.order = append(js.order[:i], js.order[i+1:]...)
+				break
+			}
+		}
+	}
+	js.jobs[record.ID] = job
+	js.order = append(js.order, record.ID)
+	js.evict()
+	return job, true
+}
+
+// Get looks up a job by ID.
+func (js *JobStore) Get(id string) (*Job, bool) {
+	js.mu.RLock()
+	defer js.mu.RUnlock()
+	job, ok := js.jobs[id]
+	return job, ok
+}
+
+// List returns up to limit jobs, newest first.
+func (js *JobStore) List(limit int) []models.ImportJob {
+	js.mu.RLock()
+	defer js.mu.RUnlock()
+	result := []models.ImportJob{}
+	for i := len(js.order) - 1; i >= 0 && len(result) < limit; i-- {
+		result = append(result, js.jobs[js.order[i]].Snapshot())
+	}
+	return result
+}
+
+// evict drops the oldest finished jobs once the store grows past maxJobs.
+// Callers must hold js.mu.
+func (js *JobStore) evict() {
+	for i := 0; len(js.order) > maxJobs && i < len(js.order); {
+		id := js.order[i]
+		if js.jobs[id].finished() {
+			delete(js.jobs, id)
+			js.order = append(js.order[:i], js.order[i+1:]...)
+			continue
+		}
+		i++
+	}
+}
+
+// newJob builds a queued job without registering it, e.g. for a dry run.
+func newJob(filename string, opts importOptions) *Job {
+	return &Job{
+		data: models.ImportJob{
+			ID:          newJobID(),
+			Filename:    filename,
+			Mode:        opts.Mode,
+			Transaction: opts.Transaction,
+			Loader:      opts.Loader,
+			State:       models.JobQueued,
+			CreatedAt:   time.Now(),
+		},
+		done: make(chan struct{}),
+	}
+}
+
+func newJobID() string {
+	buf := make([]byte, 16)
+	if _, err := rand.Read(buf); err != nil {
+		return strconv.FormatInt(time.Now().UnixNano(), 36)
+	}
+	return hex.EncodeToString(buf)
+}
+
+func (s *Service) GetJob(ctx *gin.Context) {
+	id := ctx.Param("id")
+	job, ok := s.Jobs.Get(id)
+	if !ok {
+		logs.Warn("Job not found", map[string]interface{}{
+			"id": id,
+		})
+		ctx.JSON(http.StatusNotFound, gin.H{
+			"status":  "error",
+			"message": "Job not found",
+		})
+		return
+	}
+
+	ctx.JSON(http.StatusOK, gin.H{
+		"status": "success",
+		"data":   job.Snapshot(),
+	})
+}
+
+// CancelJob stops a running import. Workers finish the batch they are writing, so every
+// row is either committed or left for POST /imports/:id/resume.
+func (s *Service) CancelJob(ctx *gin.Context) {
+	id := ctx.Param("id")
+	job, ok := s.Jobs.Get(id)
+	if !ok {
+		logs.Warn("Job not found", map[string]interface{}{
+			"id": id,
+		})
+		ctx.JSON(http.StatusNotFound, gin.H{
+			"status":  "error",
+			"message": "Job not found",
+		})
+		return
+	}
+	if !job.Cancel() {
+		ctx.JSON(http.StatusConflict, gin.H{
+			"status":  "error",
+			"message": "Job has already finished",
+		})
+		return
+	}
+
+	utils.LogInfo("CancelJob", "Cancellation requested for job "+id)
+	ctx.JSON(http.StatusAccepted, gin.H{
+		"status":  "accepted",
+		"message": "Job cancellation requested",
+		"job_id":  id,
+	})
+}
+
+func (s *Service) ListJobs(ctx *gin.Context) {
+	limit, err := strconv.Atoi(ctx.DefaultQuery("limit", "20"))
+	if err != nil || limit < 1 || limit > maxJobs {
+		limit = 20
+	}
+
+	ctx.JSON(http.StatusOK, gin.H{
+		"status": "success",
+		"data":   s.Jobs.List(limit),
+	})
+}
+
+// errorReportPageSize is the number of quarantined rows read at a time for an error report.
+const errorReportPageSize = 1000
+
+// GetJobErrors returns the rejected rows of a job, as JSON or as a downloadable CSV report (?format=csv).
+// The JSON holds the first rows the job kept in memory, with meta.truncated set when there
+// were more. The CSV report reads all of them from the quarantine when the import stored
+// them there.
+func (s *Service) GetJobErrors(ctx *gin.Context) {
+	id := ctx.Param("id")
+	job, ok := s.Jobs.Get(id)
+	if !ok {
+		logs.Warn("Job not found", map[string]interface{}{
+			"id": id,
+		})
+		ctx.JSON(http.StatusNotFound, gin.H{
+			"status":  "error",
+			"message": "Job not found",
+		})
+		return
+	}
+
+	rowErrors := job.RowErrors()
+	failed := job.Snapshot().RowsFailed
+	if ctx.Query("format") != "csv" {
+		meta := gin.H{"total": failed}
+		if failed > len(rowErrors) {
+			meta["truncated"] = true
+		}
+		ctx.JSON(http.StatusOK, gin.H{
+			"status": "success",
+			"data":   rowErrors,
+			"meta":   meta,
+		})
+		return
+	}
+
+	pages := keptErrors(rowErrors)
+	if failed > len(rowErrors) {
+		quarantined, err := s.quarantinedErrors(id)
+		if err != nil {
+			utils.LogError("GetJobErrors", "Error fetching quarantined rows from database", err)
+			ctx.JSON(http.StatusInternalServerError, gin.H{
+				"status":  "error",
+				"message": "Failed to fetch rejected rows",
+			})
+			return
+		}
+		if quarantined != nil {
+			pages = quarantined
+		}
+	}
+
+	ctx.Header("Content-Disposition", fmt.Sprintf("attachment; filename=%q", id+"-errors.csv"))
+	ctx.Header("Content-Type", "text/csv")
+	ctx.Status(http.StatusOK)
+	if err := writeErrorPages(ctx.Writer, pages); err != nil {
+		utils.LogError("GetJobErrors", "Failed to write error report", err)
+	}
+}
+
+// quarantinedErrors returns the pages of the rows an import stored in the quarantine, or
+// nil when it stored none. The first page is read right away so a database error can
+// still be reported before the response starts.
+func (s *Service) quarantinedErrors(importID string) (func() ([]models.RowError, error), error) {
+	offset := 0
+	next := func() ([]models.RowError, error) {
+		rows, _, err := s.Repo.ListQuarantine(importID, "", offset, errorReportPageSize)
+		if err != nil {
+			return nil, err
+		}
+		offset += len(rows)
+		rowErrors := make([]models.RowError, len(rows))
+		for i, row := range rows {
+			rowErrors[i] = models.RowError{Line: row.Line, Values: row.Values, Reason: row.Reason}
+		}
+		return rowErrors, nil
+	}
+
+	first, err := next()
+	if err != nil || len(first) == 0 {
+		return nil, err
+	}
+	return func() ([]models.RowError, error) {
+		if first != nil {
+			page := first
+			first = nil
+			return page, nil
+		}
+		return next()
+	}, nil
+}
+
+// keptErrors returns rowErrors as a single page.
+func keptErrors(rowErrors []models.RowError) func() ([]models.RowError, error) {
+	return func() ([]models.RowError, error) {
+		page := rowErrors
+		rowErrors = nil
+		return page, nil
+	}
+}
+
+// writeErrorReport writes rejected rows as CSV: line, reason, then the raw values.
+func writeErrorReport(w io.Writer, rowErrors []models.RowError) error {
+	return writeErrorPages(w, keptErrors(rowErrors))
+}
+
+// writeErrorPages writes the error report a page at a time. next returns no rows after
+// the last page.
+func writeErrorPages(w io.Writer, next func() ([]models.RowError, error)) error {
+	writer := csv.NewWriter(w)
+	if err := writer.Write([]string{"line", "reason", "values"}); err != nil {
+		return err
+	}
+	for {
+		rowErrors, err := next()
+		if err != nil {
+			return err
+		}
+		if len(rowErrors) == 0 {
+			break
+		}
+		for _, rowErr := range rowErrors {
+			if err := writer.Write(append([]string{strconv.Itoa(rowErr.Line), rowErr.Reason}, rowErr.Values...)); err != nil {
+				return err
+			}
+		}
+		writer.Flush()
+	}
+	writer.Flush()
+	return writer.Error()
+}
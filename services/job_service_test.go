@@ -0,0 +1,268 @@
+package services
+
+import (
+	"csv-microservice/mock"
+	"csv-microservice/models"
+	"csv-microservice/utils"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestJobStore_CreateAndList(t *testing.T) {
+	store := NewJobStore()
+
+	first := store.Create("first.csv", defaultImportOptions)
+	second := store.Create("second.csv", defaultImportOptions)
+
+	got, ok := store.Get(first.Snapshot().ID)
+	assert.True(t, ok)
+	assert.Equal(t, models.JobQueued, got.Snapshot().State)
+
+	// Newest jobs are listed first
+	list := store.List(10)
+	assert.Len(t, list, 2)
+	assert.Equal(t, second.Snapshot().ID, list[0].ID)
+	assert.Equal(t, first.Snapshot().ID, list[1].ID)
+
+	assert.Len(t, store.List(1), 1)
+}
+
+func TestJobStore_EvictsFinishedJobs(t *testing.T) {
+	store := NewJobStore()
+
+	running := store.Create("running.csv", defaultImportOptions)
+	running.start()
+	for i := 0; i < maxJobs+5; i++ {
+		store.Create("done.csv", defaultImportOptions).finish(nil)
+	}
+
+	// Unfinished jobs are never evicted
+	_, ok := store.Get(running.Snapshot().ID)
+	assert.True(t, ok)
+	assert.LessOrEqual(t, len(store.List(maxJobs+10)), maxJobs+1)
+}
+
+func TestGetJob(t *testing.T) {
+	service := NewService(nil)
+	job := service.Jobs.Create("users.csv", defaultImportOptions)
+	job.start()
+	job.addRead(3)
+	job.addResult(models.WriteResult{Inserted: 2})
+	job.rejectRow(4, []string{"x"}, "too few columns: expected 11, got 1")
+	job.finish(nil)
+
+	router := gin.Default()
+	router.GET("/jobs/:id", service.GetJob)
+
+	t.Run("Existing job", func(t *testing.T) {
+		req := httptest.NewRequest("GET", "/jobs/"+job.Snapshot().ID, nil)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusOK, w.Code)
+		var resp struct {
+			Status string           `json:"status"`
+			Data   models.ImportJob `json:"data"`
+		}
+		assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &resp))
+		assert.Equal(t, "success", resp.Status)
+		assert.Equal(t, models.JobCompleted, resp.Data.State)
+		assert.Equal(t, 3, resp.Data.RowsRead)
+		assert.Equal(t, 2, resp.Data.RowsInserted)
+		assert.Equal(t, 1, resp.Data.RowsFailed)
+	})
+
+	t.Run("Unknown job", func(t *testing.T) {
+		req := httptest.NewRequest("GET", "/jobs/unknown", nil)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusNotFound, w.Code)
+		assert.JSONEq(t, `{"status":"error","message":"Job not found"}`, w.Body.String())
+	})
+}
+
+func TestListJobs(t *testing.T) {
+	service := NewService(nil)
+	service.Jobs.Create("a.csv", defaultImportOptions)
+	service.Jobs.Create("b.csv", defaultImportOptions)
+
+	router := gin.Default()
+	router.GET("/jobs", service.ListJobs)
+
+	req := httptest.NewRequest("GET", "/jobs?limit=1", nil)
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	var resp struct {
+		Data []models.ImportJob `json:"data"`
+	}
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &resp))
+	assert.Len(t, resp.Data, 1)
+	assert.Equal(t, "b.csv", resp.Data[0].Filename)
+}
+
+func TestGetJobErrors(t *testing.T) {
+	service := NewService(nil)
+	job := service.Jobs.Create("users.csv", defaultImportOptions)
+	job.rejectRow(7, []string{"7", "Jane"}, "too few columns: expected 11, got 2")
+	job.rejectRow(3, []string{"abc"}, `id: invalid integer "abc"`)
+	job.finish(nil)
+
+	router := gin.Default()
+	router.GET("/jobs/:id/errors", service.GetJobErrors)
+
+	t.Run("JSON report", func(t *testing.T) {
+		req := httptest.NewRequest("GET", "/jobs/"+job.Snapshot().ID+"/errors", nil)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.JSONEq(t, `{
+			"status": "success",
+			"data": [
+				{"line": 3, "values": ["abc"], "reason": "id: invalid integer \"abc\""},
+				{"line": 7, "values": ["7", "Jane"], "reason": "too few columns: expected 11, got 2"}
+			],
+			"meta": {"total": 2}
+		}`, w.Body.String())
+	})
+
+	t.Run("CSV report", func(t *testing.T) {
+		req := httptest.NewRequest("GET", "/jobs/"+job.Snapshot().ID+"/errors?format=csv", nil)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Equal(t, "text/csv", w.Header().Get("Content-Type"))
+		assert.Equal(t, "line,reason,values\n3,\"id: invalid integer \"\"abc\"\"\",abc\n7,\"too few columns: expected 11, got 2\",7,Jane\n", w.Body.String())
+	})
+}
+
+func TestGetJobErrors_Truncated(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockRepo := mock.NewMockRepositoryInterface(ctrl)
+	service := NewService(mockRepo)
+	utils.InitLogger()
+	job := service.Jobs.Create("users.csv", defaultImportOptions)
+	id := job.Snapshot().ID
+	for line := 2; line < maxRowErrors+4; line++ {
+		job.rejectRow(line, []string{"x"}, "too few columns: expected 11, got 1")
+	}
+	job.finish(nil)
+
+	router := gin.Default()
+	router.GET("/jobs/:id/errors", service.GetJobErrors)
+
+	t.Run("JSON report", func(t *testing.T) {
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, httptest.NewRequest("GET", "/jobs/"+id+"/errors", nil))
+
+		assert.Equal(t, http.StatusOK, w.Code)
+		var resp struct {
+			Data []models.RowError `json:"data"`
+			Meta struct {
+				Total     int  `json:"total"`
+				Truncated bool `json:"truncated"`
+			} `json:"meta"`
+		}
+		assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &resp))
+		assert.Len(t, resp.Data, maxRowErrors)
+		assert.Equal(t, maxRowErrors+2, resp.Meta.Total)
+		assert.True(t, resp.Meta.Truncated)
+	})
+
+	t.Run("CSV report from the quarantine", func(t *testing.T) {
+		mockRepo.EXPECT().ListQuarantine(id, "", 0, errorReportPageSize).Return([]models.QuarantinedRow{
+			{Line: 2, Values: []string{"x"}, Reason: "first"},
+			{Line: 3, Values: []string{"y"}, Reason: "second"},
+		}, int64(3), nil)
+		mockRepo.EXPECT().ListQuarantine(id, "", 2, errorReportPageSize).Return([]models.QuarantinedRow{
+			{Line: 4, Values: []string{"z"}, Reason: "third"},
+		}, int64(3), nil)
+		mockRepo.EXPECT().ListQuarantine(id, "", 3, errorReportPageSize).Return(nil, int64(3), nil)
+
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, httptest.NewRequest("GET", "/jobs/"+id+"/errors?format=csv", nil))
+
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Equal(t, "line,reason,values\n2,first,x\n3,second,y\n4,third,z\n", w.Body.String())
+	})
+}
+
+func TestJob_CommitChunk(t *testing.T) {
+	job := newJob("users.csv", defaultImportOptions)
+
+	// Chunks commit out of order; the checkpoint only covers rows without gaps
+	job.commitChunk(100, 100)
+	assert.Equal(t, 0, job.Snapshot().Checkpoint)
+	job.commitChunk(0, 100)
+	assert.Equal(t, 200, job.Snapshot().Checkpoint)
+
+	restored := newJob("users.csv", defaultImportOptions)
+	restored.restoreChunks([]models.ImportChunk{
+		{Offset: 0, Rows: 100, Inserted: 98, Failed: 2, Errors: []models.RowError{
+			{Line: 9, Values: []string{"x"}, Reason: "too few columns: expected 3, got 1"},
+			{Line: 4, Values: []string{"y"}, Reason: "too few columns: expected 3, got 1"},
+		}},
+		{Offset: 200, Rows: 50, Inserted: 50},
+	})
+	snapshot := restored.Snapshot()
+	assert.Equal(t, 100, snapshot.Checkpoint)
+	assert.Equal(t, 150, snapshot.RowsRead)
+	assert.Equal(t, 148, snapshot.RowsInserted)
+	assert.Equal(t, 2, snapshot.RowsFailed)
+	assert.Equal(t, []int{4, 9}, []int{restored.RowErrors()[0].Line, restored.RowErrors()[1].Line})
+}
+
+func TestCancelJob(t *testing.T) {
+	service := NewService(nil)
+	running := service.Jobs.Create("running.csv", defaultImportOptions)
+	task := newImportTask(running, columnMap{}, defaultImportOptions, importSource{})
+	running.start()
+	finished := service.Jobs.Create("finished.csv", defaultImportOptions)
+	finished.finish(nil)
+
+	router := gin.Default()
+	router.POST("/jobs/:id/cancel", service.CancelJob)
+
+	t.Run("Running job", func(t *testing.T) {
+		req := httptest.NewRequest("POST", "/jobs/"+running.Snapshot().ID+"/cancel", nil)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusAccepted, w.Code)
+		assert.JSONEq(t, `{"status":"accepted","message":"Job cancellation requested","job_id":"`+running.Snapshot().ID+`"}`, w.Body.String())
+		assert.Error(t, task.ctx.Err(), "The workers are stopped")
+
+		running.finish(errImportCancelled)
+		assert.Equal(t, models.JobCancelled, running.Snapshot().State)
+	})
+
+	t.Run("Finished job", func(t *testing.T) {
+		req := httptest.NewRequest("POST", "/jobs/"+finished.Snapshot().ID+"/cancel", nil)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusConflict, w.Code)
+		assert.JSONEq(t, `{"status":"error","message":"Job has already finished"}`, w.Body.String())
+	})
+
+	t.Run("Unknown job", func(t *testing.T) {
+		req := httptest.NewRequest("POST", "/jobs/unknown/cancel", nil)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusNotFound, w.Code)
+		assert.JSONEq(t, `{"status":"error","message":"Job not found"}`, w.Body.String())
+	})
+}
@@ -0,0 +1,290 @@
+package services
+
+import (
+	"bufio"
+	"bytes"
+	"csv-microservice/utils"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// jsonRecordReader turns a JSON array or an NDJSON stream of user objects into rows.
+// The header row lists every User field, and each object becomes one row of cells in
+// that order, so JSON goes through the same mapping and validation as a CSV file.
+//
+// Row numbers are line numbers for NDJSON and 1-based element positions for arrays.
+type jsonRecordReader struct {
+	reader  *bufio.Reader
+	closer  io.Closer
+	array   bool
+	decoder *json.Decoder // Array elements; nil for NDJSON
+	header  bool          // Whether the header row has been returned
+	line    int
+	done    bool
+}
+
+// openJSON wraps a stream opener so the source yields rows built from JSON objects.
+func openJSON(open func() (io.ReadCloser, error)) func() (recordReader, error) {
+	return func() (recordReader, error) {
+		stream, err := open()
+		if err != nil {
+			return nil, err
+		}
+		reader := bufio.NewReader(stream)
+		first, err := firstJSONByte(reader)
+		if err != nil {
+			stream.Close()
+			return nil, err
+		}
+		records := &jsonRecordReader{reader: reader, closer: stream, array: first == '['}
+		if records.array {
+			records.decoder = json.NewDecoder(reader)
+			records.decoder.UseNumber()
+			if _, err := records.decoder.Token(); err != nil { // Opening bracket
+				stream.Close()
+				return nil, err
+			}
+		}
+		return records, nil
+	}
+}
+
+// firstJSONByte peeks at the first non-space byte, which tells an array from NDJSON.
+func firstJSONByte(reader *bufio.Reader) (byte, error) {
+	for {
+		b, err := reader.ReadByte()
+		if err == io.EOF {
+			return 0, errors.New("body is empty")
+		}
+		if err != nil {
+			return 0, err
+		}
+		if strings.ContainsRune(" \t\r\n", rune(b)) {
+			continue
+		}
+		reader.UnreadByte()
+		if b != '[' && b != '{' {
+			return 0, errors.New("body must be a JSON array of objects or newline-delimited JSON objects")
+		}
+		return b, nil
+	}
+}
+
+func (j *jsonRecordReader) Read() ([]string, int, error) {
+	if !j.header {
+		j.header = true
+		return append([]string(nil), userFields...), 0, nil
+	}
+	if j.done {
+		return nil, j.line, io.EOF
+	}
+	if j.array {
+		return j.readElement()
+	}
+	return j.readLine()
+}
+
+func (j *jsonRecordReader) readElement() ([]string, int, error) {
+	if !j.decoder.More() {
+		j.done = true
+		return nil, j.line, io.EOF
+	}
+	j.line++
+	var raw json.RawMessage
+	if err := j.decoder.Decode(&raw); err != nil {
+		// The decoder cannot resync after a syntax error, so stop reading.
+		j.done = true
+		return nil, j.line, fmt.Errorf("invalid JSON: %w", err)
+	}
+	record, err := jsonRow(raw)
+	return record, j.line, err
+}
+
+func (j *jsonRecordReader) readLine() ([]string, int, error) {
+	for {
+		text, err := j.reader.ReadString('\n')
+		if err != nil && err != io.EOF {
+			j.done = true
+			return nil, j.line, err
+		}
+		if text == "" && err == io.EOF {
+			j.done = true
+			return nil, j.line, io.EOF
+		}
+		j.line++
+		text = strings.TrimSpace(text)
+		if text == "" {
+			continue
+		}
+		if err == io.EOF {
+			j.done = true
+		}
+		record, err := jsonRow(json.RawMessage(text))
+		return record, j.line, err
+	}
+}
+
+func (j *jsonRecordReader) Close() error {
+	return j.closer.Close()
+}
+
+// jsonRow converts one JSON object into cells ordered like userFields. Keys that are not
+// User fields are ignored; null and missing keys give empty cells. Required fields of the
+// default profile must be present, as their columns must be in a CSV header.
+func jsonRow(raw json.RawMessage) ([]string, error) {
+	if trimmed := bytes.TrimSpace(raw); len(trimmed) == 0 || trimmed[0] != '{' {
+		if !json.Valid(raw) {
+			return []string{string(raw)}, errors.New("invalid JSON")
+		}
+		return []string{string(raw)}, errors.New("expected a JSON object")
+	}
+	var object map[string]json.RawMessage
+	if err := json.Unmarshal(raw, &object); err != nil {
+		return []string{string(raw)}, fmt.Errorf("invalid JSON: %w", err)
+	}
+
+	record := make([]string, len(userFields))
+	for i, field := range userFields {
+		value, ok := object[field]
+		if !ok {
+			continue
+		}
+		cell, err := jsonCell(value)
+		if err != nil {
+			return []string{string(raw)}, fmt.Errorf("%s: %w", field, err)
+		}
+		record[i] = cell
+	}
+
+	var missing []string
+	for _, field := range DefaultMappingProfile.Required {
+		if _, ok := object[field]; !ok {
+			missing = append(missing, field)
+		}
+	}
+	if len(missing) > 0 {
+		return record, fmt.Errorf("missing required fields: %s", strings.Join(missing, ", "))
+	}
+	return record, nil
+}
+
+// jsonCell renders a scalar JSON value the way it would appear in a CSV cell.
+func jsonCell(value json.RawMessage) (string, error) {
+	var scalar interface{}
+	decoder := json.NewDecoder(bytes.NewReader(value))
+	decoder.UseNumber()
+	if err := decoder.Decode(&scalar); err != nil {
+		return "", err
+	}
+	switch v := scalar.(type) {
+	case nil:
+		return "", nil
+	case string:
+		return v, nil
+	case json.Number:
+		return v.String(), nil
+	case bool:
+		if v {
+			return "true", nil
+		}
+		return "false", nil
+	default:
+		return "", fmt.Errorf("expected a string, number or boolean, got %s", value)
+	}
+}
+
+// UploadJSON imports users sent as a JSON array or as NDJSON (one object per line).
+// Objects use the same keys as models.User. Import options are taken from the query
+// string, e.g. /upload/json?mode=upsert_email&dry_run=true. Like POST /upload it honours
+// the Idempotency-Key header.
+func (s *Service) UploadJSON(ctx *gin.Context) {
+	s.idempotent(ctx, "UploadJSON", func() {
+		s.uploadJSON(ctx)
+	})
+}
+
+func (s *Service) uploadJSON(ctx *gin.Context) {
+	opts, err := parseImportOptions(ctx)
+	if err != nil {
+		utils.LogWarn("UploadJSON", err.Error())
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	// The request body is gone once the request ends, so keep a copy for the background job.
+	tmp, err := os.CreateTemp(s.UploadDir, "upload-*.json")
+	if err != nil {
+		utils.LogError("UploadJSON", "Failed to create temporary file", err)
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to store request body"})
+		return
+	}
+	upload, err := storeUpload(tmp, ctx.Request.Body, s.uploader(ctx))
+	if err != nil {
+		os.Remove(tmp.Name())
+		utils.LogError("UploadJSON", "Failed to store request body", err)
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "Failed to read request body"})
+		return
+	}
+	name := "request.json"
+	if strings.Contains(ctx.ContentType(), "ndjson") {
+		name = "request.ndjson"
+	}
+	s.importJSON(ctx, "UploadJSON", name, tmp.Name(), upload, DefaultMappingProfile, opts)
+}
+
+// importJSON starts the import of a JSON body stored at path, or validates it for a dry
+// run, and responds. Like importUpload it takes over the stored file.
+func (s *Service) importJSON(ctx *gin.Context, source, name, path string, upload uploadInfo, profile MappingProfile, opts importOptions) {
+	if s.duplicateUpload(ctx, source, upload, opts) {
+		os.Remove(path)
+		return
+	}
+
+	src := importSource{
+		Name:  name,
+		open:  openJSON(func() (io.ReadCloser, error) { return os.Open(path) }),
+		typed: true,
+	}
+	columns, err := resolveSourceColumns(src, profile)
+	if err != nil {
+		os.Remove(path)
+		utils.LogWarn(source, "Rejected request body: "+err.Error())
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "Invalid JSON body: " + err.Error()})
+		return
+	}
+
+	if opts.DryRun {
+		defer os.Remove(path)
+		task := newImportTask(newJob(src.Name, opts), columns, opts, src)
+		s.validateUpload(ctx, []*importTask{task}, nil, false)
+		return
+	}
+
+	upload.Filename = name
+	s.archiveUpload(source, path, &upload)
+	job := s.Jobs.Create(src.Name, opts)
+	task := newImportTask(job, columns, opts, src)
+	task.upload = upload
+	task.resume = &resumeState{Path: path, Filename: src.Name, Source: src.Name, Profile: profile, Options: opts, JSON: true}
+	go func() {
+		s.runImport(task)
+		if !keepUpload([]*importTask{task}) {
+			os.Remove(path)
+		}
+	}()
+
+	snapshot := job.Snapshot()
+	utils.LogInfo(source, fmt.Sprintf("Queued import job %s for %s", snapshot.ID, snapshot.Filename))
+	ctx.JSON(http.StatusAccepted, gin.H{
+		"status":  "accepted",
+		"message": "Records received and queued for processing",
+		"job_id":  snapshot.ID,
+	})
+}
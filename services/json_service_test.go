@@ -0,0 +1,164 @@
+package services
+
+import (
+	"csv-microservice/mock"
+	"csv-microservice/models"
+	"csv-microservice/utils"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestJSONRow(t *testing.T) {
+	record, err := jsonRow(json.RawMessage(`{"id":7,"first_name":"John","last_name":"Doe","email":"john@example.com","salary":1.5e3,"is_active":true,"age":null,"extra":"x"}`))
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"7", "John", "Doe", "john@example.com", "", "", "", "", "1.5e3", "", "true"}, record)
+
+	_, err = jsonRow(json.RawMessage(`{"first_name":"John","last_name":"Doe","email":"john@example.com","age":[30]}`))
+	assert.EqualError(t, err, "age: expected a string, number or boolean, got [30]")
+
+	_, err = jsonRow(json.RawMessage(`"John"`))
+	assert.EqualError(t, err, "expected a JSON object")
+
+	_, err = jsonRow(json.RawMessage(`{"first_name":`))
+	assert.ErrorContains(t, err, "invalid JSON")
+
+	_, err = jsonRow(json.RawMessage(`null`))
+	assert.EqualError(t, err, "expected a JSON object")
+
+	record, err = jsonRow(json.RawMessage(`{"first_name":"John"}`))
+	assert.EqualError(t, err, "missing required fields: last_name, email")
+	assert.Equal(t, "John", record[1])
+}
+
+func TestUploadJSON(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockRepo := mock.NewMockRepositoryInterface(ctrl)
+	expectImportAudit(mockRepo)
+	service := NewService(mockRepo)
+	utils.InitLogger()
+
+	router := gin.Default()
+	router.POST("/upload/json", service.UploadJSON)
+
+	post := func(target, contentType, body string) *httptest.ResponseRecorder {
+		req, _ := http.NewRequest(http.MethodPost, target, strings.NewReader(body))
+		req.Header.Set("Content-Type", contentType)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w
+	}
+	waitForJob := func(t *testing.T, w *httptest.ResponseRecorder) *Job {
+		assert.Equal(t, http.StatusAccepted, w.Code)
+		var resp struct {
+			JobID string `json:"job_id"`
+		}
+		assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &resp))
+		job, ok := service.Jobs.Get(resp.JobID)
+		if !assert.True(t, ok) {
+			t.FailNow()
+		}
+		job.Wait()
+		return job
+	}
+
+	t.Run("NDJSON stream", func(t *testing.T) {
+		var inserted []models.User
+		mockRepo.EXPECT().BulkInsert(gomock.Any()).DoAndReturn(func(users []models.User) error {
+			inserted = append(inserted, users...)
+			return nil
+		}).MinTimes(1)
+
+		body := `{"id":1,"first_name":"John","last_name":"Doe","email":"john@example.com","age":30}
+
+{"first_name":"Jane","last_name":"Roe","email":"jane@example.com","age":"thirty"}
+{"first_name":"Jim"}
+{"first_name":"Ann","last_name":"Lee","email":"ann@example.com","is_active":true}`
+		job := waitForJob(t, post("/upload/json", "application/x-ndjson", body))
+
+		snapshot := job.Snapshot()
+		assert.Equal(t, models.JobCompleted, snapshot.State)
+		assert.Equal(t, "request.ndjson", snapshot.Filename)
+		assert.Equal(t, 4, snapshot.RowsRead)
+		assert.Equal(t, 2, snapshot.RowsInserted)
+		assert.Equal(t, 2, snapshot.RowsFailed)
+		assert.ElementsMatch(t, []models.User{
+			{Id: 1, FirstName: "John", LastName: "Doe", Email: "john@example.com", Age: 30},
+			{FirstName: "Ann", LastName: "Lee", Email: "ann@example.com", IsActive: true},
+		}, withoutImportIDs(inserted))
+
+		rowErrors := job.RowErrors()
+		if assert.Len(t, rowErrors, 2) {
+			assert.Equal(t, 3, rowErrors[0].Line)
+			assert.Equal(t, `age: invalid integer "thirty"`, rowErrors[0].Reason)
+			assert.Equal(t, 4, rowErrors[1].Line)
+			assert.Equal(t, "missing required fields: last_name, email", rowErrors[1].Reason)
+		}
+	})
+
+	t.Run("JSON array with upsert mode", func(t *testing.T) {
+		mockRepo.EXPECT().UpsertBatch(gomock.Any(), models.ModeUpsertEmail, false).
+			DoAndReturn(func(records []models.UserRecord, mode string, merge bool) (models.WriteResult, error) {
+				return models.WriteResult{Updated: len(records)}, nil
+			}).MinTimes(1)
+
+		body := `[
+			{"first_name":"John","last_name":"Doe","email":"john@example.com"},
+			{"first_name":"Jane","last_name":"Roe","email":"jane@example.com"},
+			42
+		]`
+		job := waitForJob(t, post("/upload/json?mode=upsert_email", "application/json", body))
+
+		snapshot := job.Snapshot()
+		assert.Equal(t, "request.json", snapshot.Filename)
+		assert.Equal(t, models.ModeUpsertEmail, snapshot.Mode)
+		assert.Equal(t, 2, snapshot.RowsUpdated)
+		assert.Equal(t, []models.RowError{{Line: 3, Values: []string{"42"}, Reason: "expected a JSON object"}}, job.RowErrors())
+	})
+
+	t.Run("Dry run", func(t *testing.T) {
+		w := post("/upload/json?dry_run=true", "application/json", `[{"first_name":"John","last_name":"Doe","email":"john@example.com"}, {"first_name":`)
+		assert.Equal(t, http.StatusOK, w.Code)
+
+		var resp struct {
+			Data struct {
+				RowsRead  int               `json:"rows_read"`
+				RowsValid int               `json:"rows_valid"`
+				Errors    []models.RowError `json:"errors"`
+			} `json:"data"`
+		}
+		assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &resp))
+		assert.Equal(t, 2, resp.Data.RowsRead)
+		assert.Equal(t, 1, resp.Data.RowsValid)
+		if assert.Len(t, resp.Data.Errors, 1) {
+			assert.Equal(t, 2, resp.Data.Errors[0].Line)
+			assert.Contains(t, resp.Data.Errors[0].Reason, "invalid JSON")
+		}
+	})
+
+	t.Run("Empty body", func(t *testing.T) {
+		w := post("/upload/json", "application/json", "  \n")
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+		assert.JSONEq(t, `{"error":"Invalid JSON body: body is empty"}`, w.Body.String())
+	})
+
+	t.Run("Not JSON", func(t *testing.T) {
+		w := post("/upload/json", "application/json", "id,first_name\n")
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+		assert.JSONEq(t, `{"error":"Invalid JSON body: body must be a JSON array of objects or newline-delimited JSON objects"}`, w.Body.String())
+	})
+
+	t.Run("Invalid option", func(t *testing.T) {
+		w := post("/upload/json?mode=replace", "application/json", "[]")
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+		assert.Contains(t, w.Body.String(), `invalid mode \"replace\"`)
+	})
+}
@@ -0,0 +1,108 @@
+package services
+
+import (
+	"csv-microservice/models"
+	"strconv"
+	"sync"
+)
+
+// keyLocks serialises the writes that look a user up before inserting or updating it, so
+// two chunks upserting the same email or id cannot both miss the other's row, and the
+// later one fail on the unique index. A chunk holds the keys of its rows until its
+// transaction commits. An atomic import commits only at the end, so rather than collect
+// every key of its file it locks them all. The locks cover the imports of this process;
+// other writers, such as other instances, are kept from duplicating users by the index.
+type keyLocks struct {
+	mu      sync.Mutex
+	changed *sync.Cond // Signalled when keys are unlocked
+	held    map[string]bool
+	all     bool // An atomic import holds every key
+	waiting int  // Atomic imports waiting for every key; new chunks wait behind them
+}
+
+func newKeyLocks() *keyLocks {
+	l := &keyLocks{held: make(map[string]bool)}
+	l.changed = sync.NewCond(&l.mu)
+	return l
+}
+
+// lock waits until none of keys is held and takes them all at once, so a chunk never
+// holds some keys while it waits for others. The returned function unlocks them.
+func (l *keyLocks) lock(keys []string) func() {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	for l.all || l.waiting > 0 || l.anyHeld(keys) {
+		l.changed.Wait()
+	}
+	for _, key := range keys {
+		l.held[key] = true
+	}
+
+	return func() {
+		l.mu.Lock()
+		defer l.mu.Unlock()
+		for _, key := range keys {
+			delete(l.held, key)
+		}
+		l.changed.Broadcast()
+	}
+}
+
+// lockAll waits until no key is held and takes every key. The returned function unlocks
+// them.
+func (l *keyLocks) lockAll() func() {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.waiting++
+	for l.all || len(l.held) > 0 {
+		l.changed.Wait()
+	}
+	l.waiting--
+	l.all = true
+
+	return func() {
+		l.mu.Lock()
+		defer l.mu.Unlock()
+		l.all = false
+		l.changed.Broadcast()
+	}
+}
+
+func (l *keyLocks) anyHeld(keys []string) bool {
+	for _, key := range keys {
+		if l.held[key] {
+			return true
+		}
+	}
+	return false
+}
+
+// locksKeys reports whether an import looks up existing users before writing its rows.
+// Inserts and staged rows do not.
+func locksKeys(opts importOptions) bool {
+	return opts.Mode != models.ModeInsert && !opts.Stage && !opts.DryRun
+}
+
+// conflictKeys returns the keys the rows of a batch are matched on under mode. Rows that
+// are always inserted have none.
+func conflictKeys(batch []pendingRow, mode string) []string {
+	var keys []string
+	for _, pending := range batch {
+		if key := conflictKey(pending.record.User, mode); key != "" {
+			keys = append(keys, key)
+		}
+	}
+	return keys
+}
+
+// conflictKey returns the key a user is matched on under mode, as the repository's
+// UpsertBatch looks it up, or "" when the user is always inserted.
+func conflictKey(user models.User, mode string) string {
+	switch {
+	case (mode == models.ModeUpsertID || mode == models.ModeSkipExisting) && user.Id != 0:
+		return "id:" + strconv.Itoa(user.Id)
+	case (mode == models.ModeUpsertEmail || mode == models.ModeSkipExisting) && user.Email != "":
+		return "email:" + user.Email
+	}
+	return ""
+}
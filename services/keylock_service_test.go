@@ -0,0 +1,109 @@
+package services
+
+import (
+	"csv-microservice/mock"
+	"csv-microservice/models"
+	"csv-microservice/utils"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/assert"
+)
+
+// blocked reports whether fn is still waiting after a short while. fn keeps running in
+// the background; done is closed when it returns.
+func blocked(fn func()) (bool, chan struct{}) {
+	done := make(chan struct{})
+	go func() {
+		fn()
+		close(done)
+	}()
+	select {
+	case <-done:
+		return false, done
+	case <-time.After(50 * time.Millisecond):
+		return true, done
+	}
+}
+
+func TestKeyLocks(t *testing.T) {
+	locks := newKeyLocks()
+	unlock := locks.lock([]string{"email:john@example.com", "id:1"})
+
+	// Other keys are free; a held one waits
+	other, _ := blocked(func() { locks.lock([]string{"email:jane@example.com"})() })
+	assert.False(t, other)
+	waiting, done := blocked(func() { locks.lock([]string{"id:1", "id:2"})() })
+	assert.True(t, waiting)
+	unlock()
+	<-done
+
+	// Every key waits for an atomic import, and it waits for the keys held
+	unlock = locks.lock([]string{"id:3"})
+	waiting, all := blocked(func() {
+		unlockAll := locks.lockAll()
+		time.Sleep(50 * time.Millisecond)
+		unlockAll()
+	})
+	assert.True(t, waiting)
+	unlock()
+	waiting, done = blocked(func() { locks.lock([]string{"id:4"})() })
+	assert.True(t, waiting)
+	<-all
+	<-done
+	assert.Empty(t, locks.held)
+}
+
+func TestConflictKeys(t *testing.T) {
+	batch := []pendingRow{
+		{record: models.UserRecord{User: models.User{Id: 7, Email: "john@example.com"}}},
+		{record: models.UserRecord{User: models.User{Email: "jane@example.com"}}},
+	}
+	assert.Equal(t, []string{"email:john@example.com", "email:jane@example.com"}, conflictKeys(batch, models.ModeUpsertEmail))
+	assert.Equal(t, []string{"id:7"}, conflictKeys(batch, models.ModeUpsertID))
+	assert.Equal(t, []string{"id:7", "email:jane@example.com"}, conflictKeys(batch, models.ModeSkipExisting))
+	assert.Empty(t, conflictKeys(batch, models.ModeInsert))
+}
+
+func TestUploadCSV_UpsertChunksSerialised(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockRepo := mock.NewMockRepositoryInterface(ctrl)
+	service := NewService(mockRepo)
+	service.BatchSize = 1
+	utils.InitLogger()
+
+	router := gin.Default()
+	router.POST("/upload", service.UploadCSV)
+
+	// Every chunk upserts the same user, so no two may look it up at once
+	var content strings.Builder
+	content.WriteString("first_name,last_name,email\n")
+	for i := 0; i < 20; i++ {
+		fmt.Fprintf(&content, "John,Doe %d,john@example.com\n", i)
+	}
+	var upserts concurrency
+	expectImportAudit(mockRepo)
+	mockRepo.EXPECT().UpsertBatch(gomock.Len(1), models.ModeUpsertEmail, false).DoAndReturn(func(records []models.UserRecord, mode string, merge bool) (models.WriteResult, error) {
+		upserts.enter()
+		defer upserts.leave()
+		time.Sleep(time.Millisecond)
+		return models.WriteResult{Updated: 1}, nil
+	}).Times(20)
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, newUploadRequest(t, "/upload", "users.csv", content.String(), map[string]string{"mode": models.ModeUpsertEmail}))
+	assert.Equal(t, http.StatusAccepted, w.Code)
+	for _, job := range service.Jobs.List(10) {
+		stored, _ := service.Jobs.Get(job.ID)
+		stored.Wait()
+	}
+	assert.Equal(t, 1, upserts.peak)
+}
@@ -0,0 +1,262 @@
+package services
+
+import (
+	"csv-microservice/models"
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+)
+
+// Canonical User field names, matching the json tags on models.User.
+const (
+	FieldID         = "id"
+	FieldFirstName  = "first_name"
+	FieldLastName   = "last_name"
+	FieldEmail      = "email"
+	FieldAge        = "age"
+	FieldGender     = "gender"
+	FieldDepartment = "department"
+	FieldCompany    = "company"
+	FieldSalary     = "salary"
+	FieldDateJoined = "date_joined"
+	FieldIsActive   = "is_active"
+	FieldAttributes = "attributes" // Custom attributes, from columns that map to no field
+)
+
+// userFields lists every field a CSV column can be mapped to.
+var userFields = []string{
+	FieldID, FieldFirstName, FieldLastName, FieldEmail, FieldAge, FieldGender,
+	FieldDepartment, FieldCompany, FieldSalary, FieldDateJoined, FieldIsActive,
+}
+
+// MappingProfile describes how CSV header names map onto User fields.
+// Header names are matched case-insensitively, ignoring surrounding spaces, and
+// treating spaces and hyphens as underscores. A field's own name always matches.
+type MappingProfile struct {
+	Name     string              `json:"name"`
+	Aliases  map[string][]string `json:"aliases"`  // Field name -> accepted header names
+	Required []string            `json:"required"` // Fields that must be present in the header
+}
+
+// DefaultMappingProfile is used when an upload does not select a profile.
+var DefaultMappingProfile = MappingProfile{
+	Name: "default",
+	Aliases: map[string][]string{
+		FieldID:         {"user_id"},
+		FieldFirstName:  {"firstname", "given_name"},
+		FieldLastName:   {"lastname", "surname", "family_name"},
+		FieldEmail:      {"email_address", "e_mail", "mail"},
+		FieldDepartment: {"dept"},
+		FieldCompany:    {"company_name", "employer"},
+		FieldSalary:     {"pay", "annual_salary"},
+		FieldDateJoined: {"joined", "join_date", "start_date", "hire_date"},
+		FieldIsActive:   {"active"},
+	},
+	Required: []string{FieldFirstName, FieldLastName, FieldEmail},
+}
+
+// columnMap holds the column index of every User field found in a CSV header.
+type columnMap struct {
+	fields     map[string]int
+	attributes []attributeColumn // Columns that map to no field, in header order
+	columns    int               // Number of columns in the header
+}
+
+// attributeColumn is a CSV column that maps to no User field. Its cells are kept as the
+// custom attribute named after the normalised header.
+type attributeColumn struct {
+	name   string
+	header string
+	index  int
+}
+
+// value returns the cell for a field, or "" when the column is absent from the file.
+func (c columnMap) value(record []string, field string) string {
+	idx, ok := c.fields[field]
+	if !ok || idx >= len(record) {
+		return ""
+	}
+	return record[idx]
+}
+
+// attributeValues returns the non-empty cells of the attribute columns by attribute
+// name, nil when there are none.
+func (c columnMap) attributeValues(record []string) models.Attributes {
+	var attributes models.Attributes
+	for _, column := range c.attributes {
+		if column.index >= len(record) || strings.TrimSpace(record[column.index]) == "" {
+			continue
+		}
+		if attributes == nil {
+			attributes = make(models.Attributes)
+		}
+		attributes[column.name] = record[column.index]
+	}
+	return attributes
+}
+
+// hasAttribute reports whether an attribute column has the given name.
+func (c columnMap) hasAttribute(name string) bool {
+	for _, column := range c.attributes {
+		if column.name == name {
+			return true
+		}
+	}
+	return false
+}
+
+// presentFields lists the mapped fields whose cell in record is non-empty, and
+// FieldAttributes when an attribute column has a value.
+func (c columnMap) presentFields(record []string) []string {
+	var fields []string
+	for _, field := range userFields {
+		if strings.TrimSpace(c.value(record, field)) != "" {
+			fields = append(fields, field)
+		}
+	}
+	if len(c.attributeValues(record)) > 0 {
+		fields = append(fields, FieldAttributes)
+	}
+	return fields
+}
+
+// normalizeHeader folds a header name into the form used for matching.
+func normalizeHeader(name string) string {
+	name = strings.TrimPrefix(name, "\ufeff") // UTF-8 byte order mark
+	name = strings.ToLower(strings.TrimSpace(name))
+	return strings.NewReplacer(" ", "_", "-", "_").Replace(name)
+}
+
+// resolveColumns matches a header row against a profile. Headers that match no field
+// become attribute columns; blank ones are ignored, and a header repeating an attribute
+// gets a numbered one, e.g. notes_2. It fails when two headers map to the same field or a
+// required field is missing.
+func resolveColumns(header []string, profile MappingProfile) (columnMap, error) {
+	lookup := make(map[string]string)
+	for _, field := range userFields {
+		lookup[field] = field
+		for _, alias := range profile.Aliases[field] {
+			lookup[normalizeHeader(alias)] = field
+		}
+	}
+
+	columns := columnMap{fields: make(map[string]int), columns: len(header)}
+	for idx, name := range header {
+		normalized := normalizeHeader(name)
+		field, ok := lookup[normalized]
+		if !ok {
+			if normalized == "" {
+				continue
+			}
+			attribute := normalized
+			for n := 2; columns.hasAttribute(attribute); n++ {
+				attribute = fmt.Sprintf("%s_%d", normalized, n)
+			}
+			columns.attributes = append(columns.attributes, attributeColumn{name: attribute, header: strings.TrimSpace(strings.TrimPrefix(name, "\ufeff")), index: idx})
+			continue
+		}
+		if prev, dup := columns.fields[field]; dup {
+			return columnMap{}, fmt.Errorf("columns %q and %q both map to field %s", header[prev], name, field)
+		}
+		columns.fields[field] = idx
+	}
+
+	var missing []string
+	for _, field := range profile.Required {
+		if _, ok := columns.fields[field]; !ok {
+			missing = append(missing, field)
+		}
+	}
+	if len(missing) > 0 {
+		return columnMap{}, fmt.Errorf("missing required columns: %s", strings.Join(missing, ", "))
+	}
+	return columns, nil
+}
+
+// LoadMappingProfiles reads named mapping profiles from a JSON file containing an
+// array of profiles. The default profile is always available. An empty path loads
+// only the default profile.
+func LoadMappingProfiles(path string) (map[string]MappingProfile, error) {
+	profiles := map[string]MappingProfile{DefaultMappingProfile.Name: DefaultMappingProfile}
+	if path == "" {
+		return profiles, nil
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read mapping profiles: %w", err)
+	}
+	var loaded []MappingProfile
+	if err := json.Unmarshal(data, &loaded); err != nil {
+		return nil, fmt.Errorf("failed to parse mapping profiles: %w", err)
+	}
+	for _, profile := range loaded {
+		if err := validateProfile(profile); err != nil {
+			return nil, err
+		}
+		profiles[profile.Name] = profile
+	}
+	return profiles, nil
+}
+
+// validateProfile checks that a profile only refers to known User fields.
+func validateProfile(profile MappingProfile) error {
+	if profile.Name == "" {
+		return fmt.Errorf("mapping profile is missing a name")
+	}
+	known := make(map[string]bool)
+	for _, field := range userFields {
+		known[field] = true
+	}
+	for field := range profile.Aliases {
+		if !known[field] {
+			return fmt.Errorf("mapping profile %s: unknown field %q", profile.Name, field)
+		}
+	}
+	for _, field := range profile.Required {
+		if !known[field] {
+			return fmt.Errorf("mapping profile %s: unknown required field %q", profile.Name, field)
+		}
+	}
+	return nil
+}
+
+// buildUser maps a CSV record onto a User, reporting the first cell that cannot be parsed.
+func buildUser(record []string, columns columnMap, parser fieldParser) (models.User, error) {
+	id, err := parser.parseInt(columns.value(record, FieldID))
+	if err != nil {
+		return models.User{}, fmt.Errorf("%s: %w", FieldID, err)
+	}
+	age, err := parser.parseInt(columns.value(record, FieldAge))
+	if err != nil {
+		return models.User{}, fmt.Errorf("%s: %w", FieldAge, err)
+	}
+	salary, err := parser.parseFloat(columns.value(record, FieldSalary))
+	if err != nil {
+		return models.User{}, fmt.Errorf("%s: %w", FieldSalary, err)
+	}
+	dateJoined, err := parser.parseDate(columns.value(record, FieldDateJoined))
+	if err != nil {
+		return models.User{}, fmt.Errorf("%s: %w", FieldDateJoined, err)
+	}
+	isActive, err := parser.parseBool(columns.value(record, FieldIsActive))
+	if err != nil {
+		return models.User{}, fmt.Errorf("%s: %w", FieldIsActive, err)
+	}
+
+	return models.User{
+		Id:         id,
+		FirstName:  columns.value(record, FieldFirstName),
+		LastName:   columns.value(record, FieldLastName),
+		Email:      columns.value(record, FieldEmail),
+		Age:        age,
+		Gender:     columns.value(record, FieldGender),
+		Department: columns.value(record, FieldDepartment),
+		Company:    columns.value(record, FieldCompany),
+		Salary:     salary,
+		DateJoined: dateJoined,
+		IsActive:   isActive,
+		Attributes: columns.attributeValues(record),
+	}, nil
+}
@@ -0,0 +1,95 @@
+package services
+
+import (
+	"csv-microservice/models"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestResolveColumns(t *testing.T) {
+	t.Run("Matches aliases case-insensitively", func(t *testing.T) {
+		columns, err := resolveColumns([]string{"\ufeffE-Mail", " LastName ", "Given Name", "extra"}, DefaultMappingProfile)
+		assert.NoError(t, err)
+		assert.Equal(t, map[string]int{FieldEmail: 0, FieldLastName: 1, FieldFirstName: 2}, columns.fields)
+		assert.Equal(t, []attributeColumn{{name: "extra", header: "extra", index: 3}}, columns.attributes)
+		assert.Equal(t, 4, columns.columns)
+	})
+
+	t.Run("Unmapped columns become attributes", func(t *testing.T) {
+		columns, err := resolveColumns([]string{"first_name", "last_name", "email", "Cost Center", "Location", ""}, DefaultMappingProfile)
+		assert.NoError(t, err)
+		user, err := buildUser([]string{"John", "Doe", "john@example.com", "CC-42", " ", "x"}, columns, parserFor(LocaleUS))
+		assert.NoError(t, err)
+		assert.Equal(t, models.Attributes{"cost_center": "CC-42"}, user.Attributes, "Blank cells and headers are left out")
+		assert.Equal(t, []string{FieldFirstName, FieldLastName, FieldEmail, FieldAttributes}, columns.presentFields([]string{"John", "Doe", "john@example.com", "CC-42", "", ""}))
+		assert.Equal(t, []string{FieldFirstName, FieldLastName, FieldEmail}, columns.presentFields([]string{"John", "Doe", "john@example.com", "", "", ""}))
+	})
+
+	t.Run("Duplicate attributes", func(t *testing.T) {
+		columns, err := resolveColumns([]string{"first_name", "last_name", "email", "Notes", "Notes", "notes_2", "Cost Center", "cost-center"}, DefaultMappingProfile)
+		assert.NoError(t, err)
+		assert.Equal(t, []attributeColumn{
+			{name: "notes", header: "Notes", index: 3},
+			{name: "notes_2", header: "Notes", index: 4},
+			{name: "notes_2_2", header: "notes_2", index: 5},
+			{name: "cost_center", header: "Cost Center", index: 6},
+			{name: "cost_center_2", header: "cost-center", index: 7},
+		}, columns.attributes)
+
+		user, err := buildUser([]string{"John", "Doe", "john@example.com", "first", "second", "", "CC-42", ""}, columns, parserFor(LocaleUS))
+		assert.NoError(t, err)
+		assert.Equal(t, models.Attributes{"notes": "first", "notes_2": "second", "cost_center": "CC-42"}, user.Attributes)
+	})
+
+	t.Run("Missing required columns", func(t *testing.T) {
+		_, err := resolveColumns([]string{"first_name"}, DefaultMappingProfile)
+		assert.EqualError(t, err, "missing required columns: last_name, email")
+	})
+
+	t.Run("Duplicate columns", func(t *testing.T) {
+		_, err := resolveColumns([]string{"first_name", "last_name", "email", "mail"}, DefaultMappingProfile)
+		assert.EqualError(t, err, `columns "email" and "mail" both map to field email`)
+	})
+
+	t.Run("Optional columns read as empty", func(t *testing.T) {
+		columns, err := resolveColumns([]string{"first_name", "last_name", "email"}, DefaultMappingProfile)
+		assert.NoError(t, err)
+		user, err := buildUser([]string{"John", "Doe", "john@example.com"}, columns, parserFor(LocaleUS))
+		assert.NoError(t, err)
+		assert.Equal(t, "John", user.FirstName)
+		assert.Equal(t, 0, user.Age)
+		assert.Equal(t, "", user.Department)
+		assert.Nil(t, user.Attributes)
+	})
+}
+
+func TestLoadMappingProfiles(t *testing.T) {
+	dir := t.TempDir()
+
+	t.Run("No file", func(t *testing.T) {
+		profiles, err := LoadMappingProfiles("")
+		assert.NoError(t, err)
+		assert.Contains(t, profiles, "default")
+	})
+
+	t.Run("Valid file", func(t *testing.T) {
+		path := filepath.Join(dir, "profiles.json")
+		os.WriteFile(path, []byte(`[{"name":"vendor","aliases":{"email":["contact"]},"required":["email"]}]`), 0644)
+
+		profiles, err := LoadMappingProfiles(path)
+		assert.NoError(t, err)
+		assert.Contains(t, profiles, "default")
+		assert.Equal(t, []string{"contact"}, profiles["vendor"].Aliases["email"])
+	})
+
+	t.Run("Unknown field", func(t *testing.T) {
+		path := filepath.Join(dir, "bad.json")
+		os.WriteFile(path, []byte(`[{"name":"vendor","aliases":{"phone":["tel"]}}]`), 0644)
+
+		_, err := LoadMappingProfiles(path)
+		assert.EqualError(t, err, `mapping profile vendor: unknown field "phone"`)
+	})
+}
@@ -0,0 +1,216 @@
+package services
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+)
+
+// Locales accepted by the "locale" import option. They decide how numbers, booleans and
+// numeric dates are written in the file. Numbers of Excel and JSON sources are typed and
+// do not depend on the locale, see fieldParser.typed.
+const (
+	LocaleUS = "en-US" // 1,234.50 and 01/31/2024
+	LocaleGB = "en-GB" // 1,234.50 and 31/01/2024
+	LocaleDE = "de-DE" // 1.234,50 and 31.01.2024
+	LocaleFR = "fr-FR" // 1 234,50 and 31/01/2024
+)
+
+// fieldParser converts cells into typed User values following the conventions of a locale.
+type fieldParser struct {
+	decimal   rune     // Decimal separator
+	thousands string   // Characters accepted as thousands separators
+	dayFirst  bool     // Numeric dates put the day before the month
+	yes, no   []string // Boolean words besides the English ones
+	plain     bool     // Numbers may also be written as Go writes them, e.g. 1.5e3
+}
+
+var fieldParsers = map[string]fieldParser{
+	LocaleUS: {decimal: '.', thousands: ","},
+	LocaleGB: {decimal: '.', thousands: ",", dayFirst: true},
+	LocaleDE: {decimal: ',', thousands: ".", dayFirst: true, yes: []string{"ja", "j"}, no: []string{"nein"}},
+	LocaleFR: {decimal: ',', thousands: " \u00a0\u202f", dayFirst: true, yes: []string{"oui"}, no: []string{"non"}},
+}
+
+// Boolean words accepted in every locale, compared case-insensitively.
+var (
+	trueWords  = []string{"true", "t", "yes", "y", "1", "on"}
+	falseWords = []string{"false", "f", "no", "n", "0", "off"}
+)
+
+// Date layouts accepted in every locale, tried in order.
+var dateLayouts = []string{
+	"2006-01-02", "2006/01/02", "2006.01.02",
+	time.RFC3339, "2006-01-02T15:04:05", "2006-01-02 15:04:05",
+	"2 Jan 2006", "2 January 2006", "2-Jan-2006", "Jan 2, 2006", "January 2, 2006", "Jan 2 2006",
+}
+
+// Numeric date layouts, by the order of day and month.
+var (
+	dayFirstLayouts   = []string{"2/1/2006", "2.1.2006", "2-1-2006"}
+	monthFirstLayouts = []string{"1/2/2006", "1.2.2006", "1-2-2006"}
+)
+
+// localeNames lists the supported locales for error messages.
+var localeNames = []string{LocaleUS, LocaleGB, LocaleDE, LocaleFR}
+
+// validateLocale checks that a locale is supported.
+func validateLocale(locale string) error {
+	if _, ok := fieldParsers[locale]; !ok {
+		return fmt.Errorf("invalid locale %q: expected one of %s", locale, strings.Join(localeNames, ", "))
+	}
+	return nil
+}
+
+// parserFor returns the parser of a locale, falling back to en-US.
+func parserFor(locale string) fieldParser {
+	if parser, ok := fieldParsers[locale]; ok {
+		return parser
+	}
+	return fieldParsers[LocaleUS]
+}
+
+// typed returns the parser for typed sources: raw Excel numbers and JSON numbers always
+// use '.' as the decimal point, so only dates and booleans follow the locale.
+func (p fieldParser) typed() fieldParser {
+	invariant := fieldParsers[LocaleUS]
+	p.decimal, p.thousands, p.plain = invariant.decimal, invariant.thousands, true
+	return p
+}
+
+// parseInt parses an integer, allowing thousands separators. Empty cells parse as 0.
+func (p fieldParser) parseInt(value string) (int, error) {
+	value = strings.TrimSpace(value)
+	if value == "" {
+		return 0, nil
+	}
+	if parsed, err := strconv.Atoi(value); err == nil && p.plain {
+		return parsed, nil
+	}
+	digits, ok := p.normalizeNumber(value)
+	if !ok || strings.Contains(digits, ".") {
+		return 0, fmt.Errorf("invalid integer %q", value)
+	}
+	parsed, err := strconv.Atoi(digits)
+	if err != nil {
+		return 0, fmt.Errorf("invalid integer %q", value)
+	}
+	return parsed, nil
+}
+
+// parseFloat parses a number, allowing thousands separators and a currency symbol such
+// as "$1,234.50" or "1.234,50 €". Empty cells parse as 0.
+func (p fieldParser) parseFloat(value string) (float64, error) {
+	value = strings.TrimSpace(value)
+	if value == "" {
+		return 0.0, nil
+	}
+	if parsed, err := strconv.ParseFloat(value, 64); err == nil && p.plain && !math.IsNaN(parsed) && !math.IsInf(parsed, 0) {
+		return parsed, nil
+	}
+	digits, ok := p.normalizeNumber(value)
+	if !ok {
+		return 0.0, fmt.Errorf("invalid number %q", value)
+	}
+	parsed, err := strconv.ParseFloat(digits, 64)
+	if err != nil {
+		return 0.0, fmt.Errorf("invalid number %q", value)
+	}
+	return parsed, nil
+}
+
+// normalizeNumber strips the sign, currency symbol and thousands separators from a
+// number and returns it in the form strconv expects. Thousands separators must group
+// the integer part by three digits.
+func (p fieldParser) normalizeNumber(value string) (string, bool) {
+	sign := ""
+	trimCurrency := func(s string) string {
+		return strings.TrimFunc(s, func(r rune) bool { return unicode.Is(unicode.Sc, r) || unicode.IsSpace(r) })
+	}
+	for i := 0; i < 2; i++ { // The sign may come before or after the currency symbol
+		value = trimCurrency(value)
+		if value != "" && (value[0] == '-' || value[0] == '+') && sign == "" {
+			sign, value = value[:1], value[1:]
+		}
+	}
+
+	intPart, fracPart, hasFrac := strings.Cut(value, string(p.decimal))
+	if intPart == "" || (hasFrac && fracPart == "") || !allDigits(fracPart) {
+		return "", false
+	}
+	groups := strings.Split(strings.Map(func(r rune) rune {
+		if strings.ContainsRune(p.thousands, r) {
+			return ','
+		}
+		return r
+	}, intPart), ",")
+	for i, group := range groups {
+		if group == "" || !allDigits(group) || (len(groups) > 1 && (len(group) > 3 || (i > 0 && len(group) != 3))) {
+			return "", false
+		}
+	}
+
+	number := sign + strings.Join(groups, "")
+	if hasFrac {
+		number += "." + fracPart
+	}
+	return number, true
+}
+
+func allDigits(s string) bool {
+	for _, r := range s {
+		if r < '0' || r > '9' {
+			return false
+		}
+	}
+	return true
+}
+
+// parseBool accepts true/false, yes/no, y/n, 1/0, on/off and the locale's own words.
+// Empty cells parse as false.
+func (p fieldParser) parseBool(value string) (bool, error) {
+	value = strings.TrimSpace(value)
+	if value == "" {
+		return false, nil
+	}
+	word := strings.ToLower(value)
+	for _, words := range [][]string{trueWords, p.yes} {
+		for _, w := range words {
+			if word == w {
+				return true, nil
+			}
+		}
+	}
+	for _, words := range [][]string{falseWords, p.no} {
+		for _, w := range words {
+			if word == w {
+				return false, nil
+			}
+		}
+	}
+	return false, fmt.Errorf("invalid boolean %q", value)
+}
+
+// parseDate reads a date in one of the accepted layouts and returns it as YYYY-MM-DD.
+// Empty cells stay empty.
+func (p fieldParser) parseDate(value string) (string, error) {
+	value = strings.TrimSpace(value)
+	if value == "" {
+		return "", nil
+	}
+	numeric := monthFirstLayouts
+	if p.dayFirst {
+		numeric = dayFirstLayouts
+	}
+	for _, layouts := range [][]string{dateLayouts, numeric} {
+		for _, layout := range layouts {
+			if date, err := time.Parse(layout, value); err == nil {
+				return date.Format("2006-01-02"), nil
+			}
+		}
+	}
+	return "", fmt.Errorf("invalid date %q", value)
+}
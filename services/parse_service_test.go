@@ -0,0 +1,194 @@
+package services
+
+import (
+	"csv-microservice/mock"
+	"csv-microservice/models"
+	"csv-microservice/utils"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFieldParser_ParseFloat(t *testing.T) {
+	tests := []struct {
+		locale   string
+		value    string
+		expected float64
+		err      string
+	}{
+		{LocaleUS, "", 0, ""},
+		{LocaleUS, "1234.5", 1234.5, ""},
+		{LocaleUS, "1,234.50", 1234.5, ""},
+		{LocaleUS, "$1,234,567", 1234567, ""},
+		{LocaleUS, "-$1,200.00", -1200, ""},
+		{LocaleUS, "$-1,200.00", -1200, ""},
+		{LocaleGB, "£ 950", 950, ""},
+		{LocaleDE, "1.234,50 €", 1234.5, ""},
+		{LocaleDE, "12,5", 12.5, ""},
+		{LocaleFR, "1 234,50 €", 1234.5, ""},
+		{LocaleFR, "1 234,50", 1234.5, ""},
+		{LocaleUS, "1,23.4", 0, `invalid number "1,23.4"`},
+		{LocaleUS, "1,,234", 0, `invalid number "1,,234"`},
+		{LocaleUS, ",234", 0, `invalid number ",234"`},
+		{LocaleUS, "1.", 0, `invalid number "1."`},
+		{LocaleUS, "1e5", 0, `invalid number "1e5"`},
+		{LocaleUS, "NaN", 0, `invalid number "NaN"`},
+		{LocaleUS, "lots", 0, `invalid number "lots"`},
+		{LocaleDE, "1.5", 0, `invalid number "1.5"`},
+	}
+	for _, tt := range tests {
+		parsed, err := parserFor(tt.locale).parseFloat(tt.value)
+		if tt.err != "" {
+			assert.EqualError(t, err, tt.err, "%s %q", tt.locale, tt.value)
+			continue
+		}
+		assert.NoError(t, err, "%s %q", tt.locale, tt.value)
+		assert.Equal(t, tt.expected, parsed, "%s %q", tt.locale, tt.value)
+	}
+}
+
+func TestFieldParser_ParseInt(t *testing.T) {
+	parser := parserFor(LocaleUS)
+
+	parsed, err := parser.parseInt(" 1,024 ")
+	assert.NoError(t, err)
+	assert.Equal(t, 1024, parsed)
+
+	parsed, err = parser.parseInt("-7")
+	assert.NoError(t, err)
+	assert.Equal(t, -7, parsed)
+
+	_, err = parser.parseInt("30.5")
+	assert.EqualError(t, err, `invalid integer "30.5"`)
+
+	_, err = parser.parseInt("old")
+	assert.EqualError(t, err, `invalid integer "old"`)
+}
+
+func TestFieldParser_Typed(t *testing.T) {
+	parser := parserFor(LocaleDE).typed()
+
+	parsed, err := parser.parseFloat("1234.5")
+	assert.NoError(t, err, "Raw numbers use '.' whatever the locale")
+	assert.Equal(t, 1234.5, parsed)
+
+	parsed, err = parser.parseFloat("1.5e3")
+	assert.NoError(t, err)
+	assert.Equal(t, 1500.0, parsed)
+
+	_, err = parser.parseFloat("NaN")
+	assert.EqualError(t, err, `invalid number "NaN"`)
+
+	age, err := parser.parseInt("30")
+	assert.NoError(t, err)
+	assert.Equal(t, 30, age)
+
+	active, err := parser.parseBool("ja")
+	assert.NoError(t, err, "Booleans still follow the locale")
+	assert.True(t, active)
+}
+
+func TestFieldParser_ParseBool(t *testing.T) {
+	for _, value := range []string{"true", "TRUE", "Yes", "y", "1", "on", "T"} {
+		parsed, err := parserFor(LocaleUS).parseBool(value)
+		assert.NoError(t, err, value)
+		assert.True(t, parsed, value)
+	}
+	for _, value := range []string{"", "false", "No", "N", "0", "off"} {
+		parsed, err := parserFor(LocaleUS).parseBool(value)
+		assert.NoError(t, err, value)
+		assert.False(t, parsed, value)
+	}
+
+	parsed, err := parserFor(LocaleDE).parseBool("Ja")
+	assert.NoError(t, err)
+	assert.True(t, parsed)
+
+	_, err = parserFor(LocaleUS).parseBool("Ja")
+	assert.EqualError(t, err, `invalid boolean "Ja"`)
+}
+
+func TestFieldParser_ParseDate(t *testing.T) {
+	tests := []struct {
+		locale   string
+		value    string
+		expected string
+		err      string
+	}{
+		{LocaleUS, "", "", ""},
+		{LocaleUS, "2024-01-31", "2024-01-31", ""},
+		{LocaleUS, "2024/01/31", "2024-01-31", ""},
+		{LocaleUS, "2024-01-31T09:30:00Z", "2024-01-31", ""},
+		{LocaleUS, "31 Jan 2024", "2024-01-31", ""},
+		{LocaleUS, "January 31, 2024", "2024-01-31", ""},
+		{LocaleUS, "31-Jan-2024", "2024-01-31", ""},
+		{LocaleUS, "01/02/2024", "2024-01-02", ""},
+		{LocaleUS, "1/2/2024", "2024-01-02", ""},
+		{LocaleGB, "01/02/2024", "2024-02-01", ""},
+		{LocaleDE, "31.01.2024", "2024-01-31", ""},
+		{LocaleUS, "31/01/2024", "", `invalid date "31/01/2024"`},
+		{LocaleUS, "2024-02-30", "", `invalid date "2024-02-30"`},
+		{LocaleUS, "soon", "", `invalid date "soon"`},
+	}
+	for _, tt := range tests {
+		parsed, err := parserFor(tt.locale).parseDate(tt.value)
+		if tt.err != "" {
+			assert.EqualError(t, err, tt.err, "%s %q", tt.locale, tt.value)
+			continue
+		}
+		assert.NoError(t, err, "%s %q", tt.locale, tt.value)
+		assert.Equal(t, tt.expected, parsed, "%s %q", tt.locale, tt.value)
+	}
+}
+
+func TestValidateCSV_Locale(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockRepo := mock.NewMockRepositoryInterface(ctrl)
+	service := NewService(mockRepo)
+	utils.InitLogger()
+
+	router := gin.Default()
+	router.POST("/validate", service.ValidateCSV)
+
+	content := "first_name;last_name;email;salary;date_joined;is_active\n" +
+		"Hans;Meier;hans@example.com;1.234,50 €;31.01.2024;ja\n" +
+		"Eva;Roth;eva@example.com;viel;01.02.2024;nein\n"
+
+	t.Run("German file", func(t *testing.T) {
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, newUploadRequest(t, "/validate", "users.csv", content, map[string]string{"locale": LocaleDE}))
+
+		assert.Equal(t, http.StatusOK, w.Code)
+		var resp struct {
+			Data struct {
+				Errors  []models.RowError `json:"errors"`
+				Preview []models.User     `json:"preview"`
+			} `json:"data"`
+		}
+		assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &resp))
+		if assert.Len(t, resp.Data.Preview, 1) {
+			hans := resp.Data.Preview[0]
+			assert.Equal(t, 1234.5, hans.Salary)
+			assert.Equal(t, "2024-01-31", hans.DateJoined)
+			assert.True(t, hans.IsActive)
+		}
+		if assert.Len(t, resp.Data.Errors, 1) {
+			assert.Equal(t, `salary: invalid number "viel"`, resp.Data.Errors[0].Reason)
+		}
+	})
+
+	t.Run("Unknown locale", func(t *testing.T) {
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, newUploadRequest(t, "/validate", "users.csv", content, map[string]string{"locale": "xx"}))
+
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+		assert.JSONEq(t, `{"error":"invalid locale \"xx\": expected one of en-US, en-GB, de-DE, fr-FR"}`, w.Body.String())
+	})
+}
@@ -0,0 +1,443 @@
+package services
+
+import (
+	"csv-microservice/models"
+	"csv-microservice/utils"
+	"errors"
+	"fmt"
+	"net/http"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// maxResubmit is the most quarantined rows resubmitted by one request.
+const maxResubmit = 1000
+
+// resubmitLease is how long a resubmission may hold the rows it claimed. Rows claimed
+// longer ago are pending again, since the resubmission did not finish.
+const resubmitLease = 15 * time.Minute
+
+// quarantineBatchSize is the number of rejected rows an import collects before it stores
+// them in the quarantine.
+const quarantineBatchSize = 500
+
+// quarantineRows stores the rows an import rejected, with the values mapped to User fields
+// so they can be edited and resubmitted. Unless all is set, it waits until
+// quarantineBatchSize rows are collected. A failure is logged but does not fail the import.
+func (s *Service) quarantineRows(task *importTask, all bool) {
+	task.unquarantinedMu.Lock()
+	rowErrors := task.unquarantined
+	if len(rowErrors) == 0 || (!all && len(rowErrors) < quarantineBatchSize) {
+		task.unquarantinedMu.Unlock()
+		return
+	}
+	task.unquarantined = nil
+	task.unquarantinedMu.Unlock()
+	sort.Slice(rowErrors, func(a, b int) bool { return rowErrors[a].Line < rowErrors[b].Line })
+
+	snapshot := task.job.Snapshot()
+	rows := make([]models.QuarantinedRow, len(rowErrors))
+	for i, rowErr := range rowErrors {
+		fields := make(map[string]string)
+		for field := range task.columns.fields {
+			fields[field] = task.columns.value(rowErr.Values, field)
+		}
+		for _, column := range task.columns.attributes {
+			if column.index < len(rowErr.Values) {
+				fields[column.name] = rowErr.Values[column.index]
+			}
+		}
+		rows[i] = models.QuarantinedRow{
+			ImportID: snapshot.ID,
+			Filename: snapshot.Filename,
+			Line:     rowErr.Line,
+			Values:   rowErr.Values,
+			Fields:   fields,
+			Reason:   rowErr.Reason,
+			Status:   models.QuarantinePending,
+		}
+	}
+	if err := s.Repo.QuarantineRows(rows); err != nil {
+		utils.LogError("quarantineRows", "Failed to quarantine rejected rows of import "+snapshot.ID, err)
+	}
+}
+
+// quarantineHeader returns the User fields followed by the custom attributes of rows,
+// sorted by name.
+func quarantineHeader(rows []models.QuarantinedRow) []string {
+	known := make(map[string]bool)
+	for _, field := range userFields {
+		known[field] = true
+	}
+	var attributes []string
+	for _, row := range rows {
+		for name := range row.Fields {
+			if !known[name] {
+				known[name] = true
+				attributes = append(attributes, name)
+			}
+		}
+	}
+	sort.Strings(attributes)
+	return append(append([]string{}, userFields...), attributes...)
+}
+
+// quarantineSource feeds quarantined rows back into the import pipeline under header.
+// Each row is reported under its quarantine ID instead of a line number.
+func quarantineSource(header []string, rows []models.QuarantinedRow) importSource {
+	records := [][]string{header}
+	lines := []int{0}
+	for _, row := range rows {
+		record := make([]string, len(header))
+		for i, name := range header {
+			record[i] = row.Fields[name]
+		}
+		records = append(records, record)
+		lines = append(lines, int(row.ID))
+	}
+	return importSource{
+		Name: "quarantine",
+		open: func() (recordReader, error) { return &sliceRecordReader{rows: records, lines: lines}, nil },
+	}
+}
+
+// resubmitOptions returns the options of the imports that rejected rows, which
+// resubmitting them keeps. It responds with an error when the imports disagree or cannot
+// be read. Imports that are gone fall back to the default options.
+func (s *Service) resubmitOptions(ctx *gin.Context, rows []models.QuarantinedRow) (importOptions, bool) {
+	opts := defaultImportOptions
+	seen := make(map[string]bool)
+	var first *models.Import
+	for _, row := range rows {
+		if seen[row.ImportID] {
+			continue
+		}
+		seen[row.ImportID] = true
+		record, err := s.Repo.GetImport(row.ImportID)
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			continue
+		}
+		if err != nil {
+			utils.LogError("resubmit", "Error fetching import "+row.ImportID+" from database", err)
+			ctx.JSON(http.StatusInternalServerError, gin.H{
+				"status":  "error",
+				"message": "Failed to fetch import",
+			})
+			return importOptions{}, false
+		}
+		if first == nil {
+			first = &record
+			continue
+		}
+		if record.Mode != first.Mode || record.Merge != first.Merge || record.Loader != first.Loader || record.Locale != first.Locale {
+			ctx.JSON(http.StatusBadRequest, gin.H{"error": "Quarantined rows come from imports with different options; resubmit them by import_id"})
+			return importOptions{}, false
+		}
+	}
+	if first != nil {
+		opts.Mode, opts.Merge = first.Mode, first.Merge
+		if first.Loader != "" {
+			opts.Loader = first.Loader
+		}
+		if first.Locale != "" {
+			opts.Locale = first.Locale
+		}
+	}
+	return opts, true
+}
+
+// resubmit imports quarantined rows as a new import and responds with the outcome. Rows
+// that fail again stay pending with the new reason. The rows are written with the options
+// of the import that rejected them; mode, merge, loader, locale and transaction may be
+// overridden as query parameters, e.g. ?mode=upsert_email for rows refused as duplicates.
+func (s *Service) resubmit(ctx *gin.Context, rows []models.QuarantinedRow) {
+	opts, ok := s.resubmitOptions(ctx, rows)
+	if !ok {
+		return
+	}
+	opts, err := overrideImportOptions(ctx, opts)
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	// Resubmitted rows are written straight away, so they are marked resubmitted
+	opts.Stage, opts.DryRun = false, false
+	rows, ok = s.claimQuarantinedRows(ctx, rows)
+	if !ok {
+		return
+	}
+	header := quarantineHeader(rows)
+	columns, _ := resolveColumns(header, MappingProfile{})
+	job := s.Jobs.Create("quarantine", opts)
+	task := newImportTask(job, columns, opts, quarantineSource(header, rows))
+	task.upload = uploadInfo{Uploader: s.uploader(ctx)}
+	task.quarantine = false // Failing rows are already in quarantine
+	s.runImport(task)
+
+	snapshot := job.Snapshot()
+	failed := make(map[int]string)
+	for _, rowErr := range job.RowErrors() {
+		failed[rowErr.Line] = rowErr.Reason
+	}
+	resubmitted := 0
+	for i := range rows {
+		row := &rows[i]
+		row.Status = models.QuarantinePending // Released unless the row went in
+		if reason, ok := failed[int(row.ID)]; ok {
+			row.Reason = reason
+		} else if snapshot.State == models.JobCompleted {
+			row.Status = models.QuarantineResubmitted
+			row.ResubmittedImportID = &snapshot.ID
+			resubmitted++
+		}
+		if err := s.Repo.SaveQuarantinedRow(row); err != nil {
+			utils.LogError("resubmit", fmt.Sprintf("Failed to update quarantined row %d", row.ID), err)
+		}
+	}
+	utils.LogInfo("resubmit", fmt.Sprintf("Resubmitted %d quarantined rows as import %s: %d imported, %d failed", len(rows), snapshot.ID, resubmitted, len(failed)))
+
+	ctx.JSON(http.StatusOK, gin.H{
+		"status": "success",
+		"data": gin.H{
+			"import_id":   snapshot.ID,
+			"state":       snapshot.State,
+			"resubmitted": resubmitted,
+			"failed":      len(rows) - resubmitted,
+			"rows":        rows,
+		},
+	})
+}
+
+// claimQuarantinedRows claims rows for a resubmission and returns the ones it got, leaving
+// out those a concurrent resubmission claimed first. It responds with an error and returns
+// false when it got none.
+func (s *Service) claimQuarantinedRows(ctx *gin.Context, rows []models.QuarantinedRow) ([]models.QuarantinedRow, bool) {
+	ids := make([]uint, len(rows))
+	for i, row := range rows {
+		ids[i] = row.ID
+	}
+	claimedIDs, err := s.Repo.ClaimQuarantinedRows(ids)
+	if err != nil {
+		utils.LogError("resubmit", "Failed to claim quarantined rows", err)
+		ctx.JSON(http.StatusInternalServerError, gin.H{
+			"status":  "error",
+			"message": "Failed to claim quarantined rows",
+		})
+		return nil, false
+	}
+	claimed := make(map[uint]bool, len(claimedIDs))
+	for _, id := range claimedIDs {
+		claimed[id] = true
+	}
+	var result []models.QuarantinedRow
+	for _, row := range rows {
+		if claimed[row.ID] {
+			result = append(result, row)
+		}
+	}
+	if len(result) == 0 {
+		ctx.JSON(http.StatusConflict, gin.H{
+			"status":  "error",
+			"message": "Rows are already being resubmitted",
+		})
+		return nil, false
+	}
+	return result, true
+}
+
+// releaseQuarantinedRows makes the rows of resubmissions that did not finish pending
+// again. A failure is logged; those rows stay claimed until a later request.
+func (s *Service) releaseQuarantinedRows(source string) {
+	if err := s.Repo.ReleaseQuarantinedRows(time.Now().Add(-resubmitLease)); err != nil {
+		utils.LogError(source, "Failed to release abandoned quarantined rows", err)
+	}
+}
+
+// ListQuarantine lists quarantined rows, filtered by ?import_id= and ?status= (pending by
+// default, "all" for every row).
+func (s *Service) ListQuarantine(ctx *gin.Context) {
+	page, err := strconv.Atoi(ctx.DefaultQuery("page", "1"))
+	if err != nil || page < 1 {
+		page = 1
+	}
+	limit, err := strconv.Atoi(ctx.DefaultQuery("limit", "10"))
+	if err != nil || limit < 1 {
+		limit = 10
+	}
+	status := ctx.DefaultQuery("status", models.QuarantinePending)
+	if status == "all" {
+		status = ""
+	}
+
+	rows, total, err := s.Repo.ListQuarantine(ctx.Query("import_id"), status, (page-1)*limit, limit)
+	if err != nil {
+		utils.LogError("ListQuarantine", "Error fetching quarantined rows from database", err)
+		ctx.JSON(http.StatusInternalServerError, gin.H{
+			"status":  "error",
+			"message": "Failed to fetch quarantined rows",
+		})
+		return
+	}
+
+	ctx.JSON(http.StatusOK, gin.H{
+		"status": "success",
+		"data":   rows,
+		"meta": gin.H{
+			"page":  page,
+			"limit": limit,
+			"total": total,
+		},
+	})
+}
+
+// pendingQuarantinedRow loads the row named by the :id parameter. It responds with an
+// error and returns false when the row does not exist or was already resubmitted.
+func (s *Service) pendingQuarantinedRow(ctx *gin.Context, source string) (models.QuarantinedRow, bool) {
+	id, err := strconv.ParseUint(ctx.Param("id"), 10, 0)
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "Invalid ID format"})
+		return models.QuarantinedRow{}, false
+	}
+	rows, err := s.Repo.GetQuarantinedRows([]uint{uint(id)})
+	if err != nil {
+		utils.LogError(source, "Error fetching quarantined row from database", err)
+		ctx.JSON(http.StatusInternalServerError, gin.H{
+			"status":  "error",
+			"message": "Failed to fetch quarantined row",
+		})
+		return models.QuarantinedRow{}, false
+	}
+	if len(rows) == 0 {
+		ctx.JSON(http.StatusNotFound, gin.H{
+			"status":  "error",
+			"message": "Quarantined row not found",
+		})
+		return models.QuarantinedRow{}, false
+	}
+	if rows[0].Status == models.QuarantineResubmitting {
+		ctx.JSON(http.StatusConflict, gin.H{
+			"status":  "error",
+			"message": "Row is being resubmitted",
+		})
+		return models.QuarantinedRow{}, false
+	}
+	if rows[0].Status != models.QuarantinePending {
+		ctx.JSON(http.StatusConflict, gin.H{
+			"status":  "error",
+			"message": "Row was already resubmitted",
+		})
+		return models.QuarantinedRow{}, false
+	}
+	return rows[0], true
+}
+
+// UpdateQuarantinedRow edits the field values of a quarantined row. The body holds the
+// fields to change, e.g. {"fields": {"age": "30"}}; other fields keep their values. The
+// custom attributes the row was quarantined with can be edited like fields.
+func (s *Service) UpdateQuarantinedRow(ctx *gin.Context) {
+	row, ok := s.pendingQuarantinedRow(ctx, "UpdateQuarantinedRow")
+	if !ok {
+		return
+	}
+
+	var body struct {
+		Fields map[string]string `json:"fields" binding:"required"`
+	}
+	if err := ctx.ShouldBindJSON(&body); err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request body"})
+		return
+	}
+	known := make(map[string]bool)
+	for _, field := range userFields {
+		known[field] = true
+	}
+	for name := range row.Fields {
+		known[name] = true // Custom attributes of the row
+	}
+	if row.Fields == nil {
+		row.Fields = make(map[string]string)
+	}
+	for field, value := range body.Fields {
+		if !known[field] {
+			ctx.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("unknown field %q", field)})
+			return
+		}
+		row.Fields[field] = value
+	}
+
+	if err := s.Repo.SaveQuarantinedRow(&row); err != nil {
+		utils.LogError("UpdateQuarantinedRow", "Failed to update quarantined row", err)
+		ctx.JSON(http.StatusInternalServerError, gin.H{
+			"status":  "error",
+			"message": "Failed to update quarantined row",
+		})
+		return
+	}
+	ctx.JSON(http.StatusOK, gin.H{
+		"status": "success",
+		"data":   row,
+	})
+}
+
+// ResubmitQuarantinedRow imports a single quarantined row again.
+func (s *Service) ResubmitQuarantinedRow(ctx *gin.Context) {
+	s.releaseQuarantinedRows("ResubmitQuarantinedRow")
+	row, ok := s.pendingQuarantinedRow(ctx, "ResubmitQuarantinedRow")
+	if !ok {
+		return
+	}
+	s.resubmit(ctx, []models.QuarantinedRow{row})
+}
+
+// ResubmitQuarantine imports pending quarantined rows again, chosen by ID
+// ({"ids": [1, 2]}) or by the import that rejected them ({"import_id": "..."}).
+func (s *Service) ResubmitQuarantine(ctx *gin.Context) {
+	var body struct {
+		IDs      []uint `json:"ids"`
+		ImportID string `json:"import_id"`
+	}
+	if err := ctx.ShouldBindJSON(&body); err != nil || (len(body.IDs) == 0 && body.ImportID == "") {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "Request body must name ids or an import_id"})
+		return
+	}
+	if len(body.IDs) > maxResubmit {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("At most %d rows can be resubmitted at once", maxResubmit)})
+		return
+	}
+
+	s.releaseQuarantinedRows("ResubmitQuarantine")
+	var rows []models.QuarantinedRow
+	var err error
+	if len(body.IDs) > 0 {
+		rows, err = s.Repo.GetQuarantinedRows(body.IDs)
+	} else {
+		rows, _, err = s.Repo.ListQuarantine(body.ImportID, models.QuarantinePending, 0, maxResubmit)
+	}
+	if err != nil {
+		utils.LogError("ResubmitQuarantine", "Error fetching quarantined rows from database", err)
+		ctx.JSON(http.StatusInternalServerError, gin.H{
+			"status":  "error",
+			"message": "Failed to fetch quarantined rows",
+		})
+		return
+	}
+
+	pending := rows[:0]
+	for _, row := range rows {
+		if row.Status == models.QuarantinePending {
+			pending = append(pending, row)
+		}
+	}
+	if len(pending) == 0 {
+		ctx.JSON(http.StatusNotFound, gin.H{
+			"status":  "error",
+			"message": "No pending quarantined rows found",
+		})
+		return
+	}
+	s.resubmit(ctx, pending)
+}
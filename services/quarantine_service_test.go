@@ -0,0 +1,393 @@
+package services
+
+import (
+	"csv-microservice/mock"
+	"csv-microservice/models"
+	"csv-microservice/utils"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestUploadCSV_QuarantinesRejectedRows(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockRepo := mock.NewMockRepositoryInterface(ctrl)
+	service := NewService(mockRepo)
+	utils.InitLogger()
+
+	router := gin.Default()
+	router.POST("/upload", service.UploadCSV)
+
+	expectNewUploads(mockRepo)
+	mockRepo.EXPECT().SaveImport(gomock.Any()).Return(nil).AnyTimes()
+	expectChunks(mockRepo)
+	mockRepo.EXPECT().BulkInsert(gomock.Any()).Return(nil).AnyTimes()
+	var quarantined []models.QuarantinedRow
+	mockRepo.EXPECT().QuarantineRows(gomock.Any()).DoAndReturn(func(rows []models.QuarantinedRow) error {
+		quarantined = rows
+		return nil
+	}).Times(1)
+
+	content := "First Name,Surname,email,age\nJohn,Doe,john@example.com,30\nJane,Roe,jane@example.com,old\n"
+	req := newUploadRequest(t, "/upload", "users.csv", content, nil)
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusAccepted, w.Code)
+
+	var resp struct {
+		JobID string `json:"job_id"`
+	}
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &resp))
+	job, _ := service.Jobs.Get(resp.JobID)
+	job.Wait()
+
+	if assert.Len(t, quarantined, 1) {
+		row := quarantined[0]
+		assert.Equal(t, resp.JobID, row.ImportID)
+		assert.Equal(t, "users.csv", row.Filename)
+		assert.Equal(t, 3, row.Line)
+		assert.Equal(t, []string{"Jane", "Roe", "jane@example.com", "old"}, row.Values)
+		assert.Equal(t, map[string]string{FieldFirstName: "Jane", FieldLastName: "Roe", FieldEmail: "jane@example.com", FieldAge: "old"}, row.Fields)
+		assert.Contains(t, row.Reason, FieldAge)
+		assert.Equal(t, models.QuarantinePending, row.Status)
+	}
+}
+
+func TestUploadCSV_QuarantinesInBatches(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockRepo := mock.NewMockRepositoryInterface(ctrl)
+	service := NewService(mockRepo)
+	utils.InitLogger()
+
+	router := gin.Default()
+	router.POST("/upload", service.UploadCSV)
+
+	expectNewUploads(mockRepo)
+	mockRepo.EXPECT().SaveImport(gomock.Any()).Return(nil).AnyTimes()
+	expectChunks(mockRepo)
+	var batches []int
+	mockRepo.EXPECT().QuarantineRows(gomock.Any()).DoAndReturn(func(rows []models.QuarantinedRow) error {
+		batches = append(batches, len(rows))
+		return nil
+	}).MinTimes(2)
+
+	rejected := 2*maxRowErrors + 1
+	var content strings.Builder
+	content.WriteString("first_name,last_name,email,age\n")
+	for i := 0; i < rejected; i++ {
+		content.WriteString("Jane,Roe,jane@example.com,old\n")
+	}
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, newUploadRequest(t, "/upload", "users.csv", content.String(), nil))
+	assert.Equal(t, http.StatusAccepted, w.Code)
+
+	var resp struct {
+		JobID string `json:"job_id"`
+	}
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &resp))
+	job, _ := service.Jobs.Get(resp.JobID)
+	job.Wait()
+
+	// The job keeps only the first rejected rows; the quarantine gets them all
+	assert.Equal(t, rejected, job.Snapshot().RowsFailed)
+	assert.Len(t, job.RowErrors(), maxRowErrors)
+	total := 0
+	for _, size := range batches {
+		total += size
+	}
+	assert.Equal(t, rejected, total)
+}
+
+func TestListQuarantine(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockRepo := mock.NewMockRepositoryInterface(ctrl)
+	service := NewService(mockRepo)
+	utils.InitLogger()
+
+	router := gin.Default()
+	router.GET("/quarantine", service.ListQuarantine)
+
+	tests := []struct {
+		name           string
+		target         string
+		mockSetup      func()
+		expectedStatus int
+		expectedBody   string
+	}{
+		{
+			name:   "Pending by default",
+			target: "/quarantine?import_id=abc",
+			mockSetup: func() {
+				mockRepo.EXPECT().ListQuarantine("abc", models.QuarantinePending, 0, 10).Return([]models.QuarantinedRow{{ID: 4, Line: 3}}, int64(1), nil)
+			},
+			expectedStatus: http.StatusOK,
+			expectedBody:   `"meta":{"limit":10,"page":1,"total":1}`,
+		},
+		{
+			name:   "All statuses",
+			target: "/quarantine?status=all&page=3&limit=5",
+			mockSetup: func() {
+				mockRepo.EXPECT().ListQuarantine("", "", 10, 5).Return(nil, int64(0), nil)
+			},
+			expectedStatus: http.StatusOK,
+			expectedBody:   `"meta":{"limit":5,"page":3,"total":0}`,
+		},
+		{
+			name:   "Database error",
+			target: "/quarantine",
+			mockSetup: func() {
+				mockRepo.EXPECT().ListQuarantine("", models.QuarantinePending, 0, 10).Return(nil, int64(0), errors.New("db error"))
+			},
+			expectedStatus: http.StatusInternalServerError,
+			expectedBody:   `{"message":"Failed to fetch quarantined rows","status":"error"}`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.mockSetup()
+
+			w := httptest.NewRecorder()
+			req, _ := http.NewRequest(http.MethodGet, tt.target, nil)
+			router.ServeHTTP(w, req)
+
+			assert.Equal(t, tt.expectedStatus, w.Code)
+			assert.Contains(t, w.Body.String(), tt.expectedBody)
+		})
+	}
+}
+
+func TestUpdateQuarantinedRow(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockRepo := mock.NewMockRepositoryInterface(ctrl)
+	service := NewService(mockRepo)
+	utils.InitLogger()
+
+	router := gin.Default()
+	router.PUT("/quarantine/:id", service.UpdateQuarantinedRow)
+
+	pending := func() []models.QuarantinedRow {
+		return []models.QuarantinedRow{{ID: 4, Fields: map[string]string{FieldEmail: "jane@example.com", FieldAge: "old"}, Status: models.QuarantinePending}}
+	}
+	tests := []struct {
+		name           string
+		target         string
+		body           string
+		mockSetup      func()
+		expectedStatus int
+		expectedBody   string
+	}{
+		{
+			name:   "Edit",
+			target: "/quarantine/4",
+			body:   `{"fields":{"age":"30"}}`,
+			mockSetup: func() {
+				mockRepo.EXPECT().GetQuarantinedRows([]uint{4}).Return(pending(), nil)
+				mockRepo.EXPECT().SaveQuarantinedRow(gomock.Any()).DoAndReturn(func(row *models.QuarantinedRow) error {
+					assert.Equal(t, map[string]string{FieldEmail: "jane@example.com", FieldAge: "30"}, row.Fields)
+					return nil
+				})
+			},
+			expectedStatus: http.StatusOK,
+			expectedBody:   `"fields":{"age":"30","email":"jane@example.com"}`,
+		},
+		{
+			name:   "Unknown field",
+			target: "/quarantine/4",
+			body:   `{"fields":{"nickname":"JJ"}}`,
+			mockSetup: func() {
+				mockRepo.EXPECT().GetQuarantinedRows([]uint{4}).Return(pending(), nil)
+			},
+			expectedStatus: http.StatusBadRequest,
+			expectedBody:   `{"error":"unknown field \"nickname\""}`,
+		},
+		{
+			name:   "Already resubmitted",
+			target: "/quarantine/4",
+			body:   `{"fields":{"age":"30"}}`,
+			mockSetup: func() {
+				mockRepo.EXPECT().GetQuarantinedRows([]uint{4}).Return([]models.QuarantinedRow{{ID: 4, Status: models.QuarantineResubmitted}}, nil)
+			},
+			expectedStatus: http.StatusConflict,
+			expectedBody:   `{"message":"Row was already resubmitted","status":"error"}`,
+		},
+		{
+			name:   "Not found",
+			target: "/quarantine/9",
+			body:   `{"fields":{"age":"30"}}`,
+			mockSetup: func() {
+				mockRepo.EXPECT().GetQuarantinedRows([]uint{9}).Return(nil, nil)
+			},
+			expectedStatus: http.StatusNotFound,
+			expectedBody:   `{"message":"Quarantined row not found","status":"error"}`,
+		},
+		{
+			name:           "Invalid ID",
+			target:         "/quarantine/abc",
+			body:           `{"fields":{"age":"30"}}`,
+			mockSetup:      func() {},
+			expectedStatus: http.StatusBadRequest,
+			expectedBody:   `{"error":"Invalid ID format"}`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.mockSetup()
+
+			w := httptest.NewRecorder()
+			req, _ := http.NewRequest(http.MethodPut, tt.target, strings.NewReader(tt.body))
+			req.Header.Set("Content-Type", "application/json")
+			router.ServeHTTP(w, req)
+
+			assert.Equal(t, tt.expectedStatus, w.Code)
+			assert.Contains(t, w.Body.String(), tt.expectedBody)
+		})
+	}
+}
+
+func TestResubmitQuarantine(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockRepo := mock.NewMockRepositoryInterface(ctrl)
+	service := NewService(mockRepo)
+	utils.InitLogger()
+
+	router := gin.Default()
+	router.POST("/quarantine/resubmit", service.ResubmitQuarantine)
+	mockRepo.EXPECT().ReleaseQuarantinedRows(gomock.Any()).Return(nil).AnyTimes()
+
+	t.Run("Fixed and broken rows", func(t *testing.T) {
+		mockRepo.EXPECT().ListQuarantine("abc", models.QuarantinePending, 0, maxResubmit).Return([]models.QuarantinedRow{
+			{ID: 7, ImportID: "abc", Fields: map[string]string{FieldFirstName: "Jane", FieldLastName: "Roe", FieldEmail: "jane@example.com", FieldAge: "30"}, Status: models.QuarantinePending},
+			{ID: 8, ImportID: "abc", Fields: map[string]string{FieldFirstName: "Max", FieldLastName: "Poe", FieldEmail: "max@example.com", FieldAge: "old"}, Status: models.QuarantinePending},
+		}, int64(2), nil)
+		mockRepo.EXPECT().GetImport("abc").Return(models.Import{ID: "abc", Mode: models.ModeInsert, Loader: models.LoaderGorm, Locale: LocaleUS}, nil)
+		mockRepo.EXPECT().ClaimQuarantinedRows([]uint{7, 8}).Return([]uint{7, 8}, nil)
+		mockRepo.EXPECT().SaveImport(gomock.Any()).Return(nil).AnyTimes()
+		expectChunks(mockRepo)
+		mockRepo.EXPECT().BulkInsert(importedUsers{{FirstName: "Jane", LastName: "Roe", Email: "jane@example.com", Age: 30}}).Return(nil)
+		saved := make(map[uint]models.QuarantinedRow)
+		mockRepo.EXPECT().SaveQuarantinedRow(gomock.Any()).DoAndReturn(func(row *models.QuarantinedRow) error {
+			saved[row.ID] = *row
+			return nil
+		}).Times(2)
+
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest(http.MethodPost, "/quarantine/resubmit", strings.NewReader(`{"import_id":"abc"}`))
+		req.Header.Set("Content-Type", "application/json")
+		router.ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusOK, w.Code)
+		var resp struct {
+			Data struct {
+				ImportID    string `json:"import_id"`
+				Resubmitted int    `json:"resubmitted"`
+				Failed      int    `json:"failed"`
+			} `json:"data"`
+		}
+		assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &resp))
+		assert.Equal(t, 1, resp.Data.Resubmitted)
+		assert.Equal(t, 1, resp.Data.Failed)
+
+		assert.Equal(t, models.QuarantineResubmitted, saved[7].Status)
+		if assert.NotNil(t, saved[7].ResubmittedImportID) {
+			assert.Equal(t, resp.Data.ImportID, *saved[7].ResubmittedImportID)
+		}
+		assert.Equal(t, models.QuarantinePending, saved[8].Status)
+		assert.Contains(t, saved[8].Reason, FieldAge)
+	})
+
+	post := func(target, body string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest(http.MethodPost, target, strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		router.ServeHTTP(w, req)
+		return w
+	}
+	duplicate := models.QuarantinedRow{ID: 9, ImportID: "def", Fields: map[string]string{FieldFirstName: "Jim", FieldLastName: "Doe", FieldEmail: "jim@example.com"}, Status: models.QuarantinePending}
+
+	t.Run("Keeps the options of the source import", func(t *testing.T) {
+		mockRepo.EXPECT().GetQuarantinedRows([]uint{9}).Return([]models.QuarantinedRow{duplicate}, nil)
+		mockRepo.EXPECT().GetImport("def").Return(models.Import{ID: "def", Mode: models.ModeUpsertEmail, Merge: true, Loader: models.LoaderGorm, Locale: LocaleDE}, nil)
+		mockRepo.EXPECT().ClaimQuarantinedRows([]uint{9}).Return([]uint{9}, nil)
+		mockRepo.EXPECT().UpsertBatch(gomock.Any(), models.ModeUpsertEmail, true).Return(models.WriteResult{Updated: 1}, nil)
+		mockRepo.EXPECT().SaveQuarantinedRow(gomock.Any()).Return(nil)
+
+		w := post("/quarantine/resubmit", `{"ids":[9]}`)
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Contains(t, w.Body.String(), `"resubmitted":1`)
+	})
+
+	t.Run("Mode given on the request", func(t *testing.T) {
+		mockRepo.EXPECT().GetQuarantinedRows([]uint{9}).Return([]models.QuarantinedRow{duplicate}, nil)
+		mockRepo.EXPECT().GetImport("def").Return(models.Import{ID: "def", Mode: models.ModeInsert, Loader: models.LoaderGorm}, nil)
+		mockRepo.EXPECT().ClaimQuarantinedRows([]uint{9}).Return([]uint{9}, nil)
+		mockRepo.EXPECT().UpsertBatch(gomock.Any(), models.ModeUpsertEmail, false).Return(models.WriteResult{Updated: 1}, nil)
+		mockRepo.EXPECT().SaveQuarantinedRow(gomock.Any()).Return(nil)
+
+		w := post("/quarantine/resubmit?mode=upsert_email", `{"ids":[9]}`)
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Contains(t, w.Body.String(), `"resubmitted":1`)
+	})
+
+	t.Run("Claimed by a concurrent resubmission", func(t *testing.T) {
+		mockRepo.EXPECT().GetQuarantinedRows([]uint{9}).Return([]models.QuarantinedRow{duplicate}, nil)
+		mockRepo.EXPECT().GetImport("def").Return(models.Import{ID: "def", Mode: models.ModeInsert, Loader: models.LoaderGorm}, nil)
+		mockRepo.EXPECT().ClaimQuarantinedRows([]uint{9}).Return(nil, nil)
+
+		w := post("/quarantine/resubmit", `{"ids":[9]}`)
+		assert.Equal(t, http.StatusConflict, w.Code)
+		assert.JSONEq(t, `{"status":"error","message":"Rows are already being resubmitted"}`, w.Body.String())
+	})
+
+	t.Run("Source imports disagree", func(t *testing.T) {
+		other := duplicate
+		other.ID, other.ImportID = 10, "ghi"
+		mockRepo.EXPECT().GetQuarantinedRows([]uint{9, 10}).Return([]models.QuarantinedRow{duplicate, other}, nil)
+		mockRepo.EXPECT().GetImport("def").Return(models.Import{ID: "def", Mode: models.ModeInsert}, nil)
+		mockRepo.EXPECT().GetImport("ghi").Return(models.Import{ID: "ghi", Mode: models.ModeUpsertEmail}, nil)
+
+		w := post("/quarantine/resubmit", `{"ids":[9,10]}`)
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+		assert.JSONEq(t, `{"error":"Quarantined rows come from imports with different options; resubmit them by import_id"}`, w.Body.String())
+	})
+
+	t.Run("Nothing pending", func(t *testing.T) {
+		mockRepo.EXPECT().GetQuarantinedRows([]uint{1, 2}).Return([]models.QuarantinedRow{{ID: 1, Status: models.QuarantineResubmitted}}, nil)
+
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest(http.MethodPost, "/quarantine/resubmit", strings.NewReader(`{"ids":[1,2]}`))
+		req.Header.Set("Content-Type", "application/json")
+		router.ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusNotFound, w.Code)
+		assert.JSONEq(t, `{"status":"error","message":"No pending quarantined rows found"}`, w.Body.String())
+	})
+
+	t.Run("Empty body", func(t *testing.T) {
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest(http.MethodPost, "/quarantine/resubmit", strings.NewReader(`{}`))
+		req.Header.Set("Content-Type", "application/json")
+		router.ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+		assert.JSONEq(t, `{"error":"Request body must name ids or an import_id"}`, w.Body.String())
+	})
+}
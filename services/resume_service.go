@@ -0,0 +1,268 @@
+package services
+
+import (
+	"context"
+	"csv-microservice/models"
+	"csv-microservice/utils"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"net/http"
+	"os"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// resumeSweepInterval is how often uploads kept for resuming are checked for expiry.
+const resumeSweepInterval = time.Hour
+
+// resumeState is what an interrupted import needs to read its upload again. It is stored
+// with the audit record for as long as the upload is kept.
+type resumeState struct {
+	Path     string         `json:"path"`     // Stored copy of the upload
+	Filename string         `json:"filename"` // Name the upload was sent with
+	Source   string         `json:"source"`   // Table the import reads, e.g. a member of an archive
+	Sheet    string         `json:"sheet,omitempty"`
+	Dialect  csvDialect     `json:"dialect"`
+	Profile  MappingProfile `json:"profile"`
+	Options  importOptions  `json:"options"`
+	JSON     bool           `json:"json,omitempty"`   // The upload is a POST /upload/json body
+	Shared   bool           `json:"shared,omitempty"` // Other imports read the same upload
+}
+
+// source reopens the table the import reads.
+func (r resumeState) source() (importSource, error) {
+	if _, err := os.Stat(r.Path); err != nil {
+		return importSource{}, err
+	}
+	if r.JSON {
+		return importSource{
+			Name:  r.Source,
+			open:  openJSON(func() (io.ReadCloser, error) { return os.Open(r.Path) }),
+			typed: true,
+		}, nil
+	}
+
+	sources, err := uploadSources(r.Path, r.Filename, r.Sheet, r.Dialect)
+	if err != nil {
+		return importSource{}, err
+	}
+	for _, src := range sources {
+		if src.Name == r.Source {
+			return src, nil
+		}
+	}
+	return importSource{}, fmt.Errorf("%s not found in upload", r.Source)
+}
+
+// keepUpload reports whether the stored upload must stay on disk because one of the
+// imports reading it can still be resumed.
+func keepUpload(tasks []*importTask) bool {
+	for _, task := range tasks {
+		if task.resume != nil && task.resumable() && task.job.Snapshot().State != models.JobCompleted {
+			return true
+		}
+	}
+	return false
+}
+
+// ResumeImport continues an import that was cancelled, failed or cut short by a restart.
+// The chunks it committed are skipped, so no row is written twice.
+func (s *Service) ResumeImport(ctx *gin.Context) {
+	id := ctx.Param("id")
+	record, err := s.Repo.GetImport(id)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		utils.LogWarn("ResumeImport", "Import not found: "+id)
+		ctx.JSON(http.StatusNotFound, gin.H{
+			"status":  "error",
+			"message": "Import not found",
+		})
+		return
+	}
+	if err != nil {
+		utils.LogError("ResumeImport", "Error fetching import from database", err)
+		ctx.JSON(http.StatusInternalServerError, gin.H{
+			"status":  "error",
+			"message": "Failed to fetch import",
+		})
+		return
+	}
+
+	conflict := ""
+	switch {
+	case record.State == models.JobCompleted:
+		conflict = "Import has already completed"
+	case record.State == models.ImportReverted:
+		conflict = "Import was already reverted"
+	case record.State == models.ImportStaged:
+		conflict = "Import is waiting for approval"
+	case record.State == models.ImportRejected:
+		conflict = "Import was rejected"
+	case record.ResumeState == "":
+		conflict = "Import cannot be resumed"
+	}
+	if conflict != "" {
+		ctx.JSON(http.StatusConflict, gin.H{
+			"status":  "error",
+			"message": conflict,
+		})
+		return
+	}
+
+	var resume resumeState
+	if err := json.Unmarshal([]byte(record.ResumeState), &resume); err != nil {
+		utils.LogError("ResumeImport", "Invalid resume state of import "+id, err)
+		ctx.JSON(http.StatusInternalServerError, gin.H{
+			"status":  "error",
+			"message": "Failed to read import state",
+		})
+		return
+	}
+	src, err := resume.source()
+	if err != nil {
+		utils.LogWarn("ResumeImport", fmt.Sprintf("Upload of import %s is gone: %s", id, err.Error()))
+		ctx.JSON(http.StatusGone, gin.H{
+			"status":  "error",
+			"message": "Uploaded file is no longer available",
+		})
+		return
+	}
+	columns, err := resolveSourceColumns(src, resume.Profile)
+	if err != nil {
+		utils.LogError("ResumeImport", "Failed to read header of import "+id, err)
+		ctx.JSON(http.StatusInternalServerError, gin.H{
+			"status":  "error",
+			"message": "Failed to read uploaded file",
+		})
+		return
+	}
+	chunks, err := s.Repo.ListImportChunks(id)
+	if err != nil {
+		utils.LogError("ResumeImport", "Error fetching committed chunks from database", err)
+		ctx.JSON(http.StatusInternalServerError, gin.H{
+			"status":  "error",
+			"message": "Failed to fetch import progress",
+		})
+		return
+	}
+
+	// The rows the committed chunks rejected are reported again, but the earlier run may
+	// already have quarantined them
+	rejected := false
+	for _, chunk := range chunks {
+		rejected = rejected || len(chunk.Errors) > 0
+	}
+	quarantined := make(map[int]bool)
+	if rejected {
+		rows, _, err := s.Repo.ListQuarantine(id, "", 0, -1)
+		if err != nil {
+			utils.LogError("ResumeImport", "Error fetching quarantined rows from database", err)
+			ctx.JSON(http.StatusInternalServerError, gin.H{
+				"status":  "error",
+				"message": "Failed to fetch import progress",
+			})
+			return
+		}
+		for _, row := range rows {
+			quarantined[row.Line] = true
+		}
+	}
+
+	job, ok := s.Jobs.Restore(record, resume.Options)
+	if !ok {
+		ctx.JSON(http.StatusConflict, gin.H{
+			"status":  "error",
+			"message": "Import is still running",
+		})
+		return
+	}
+	job.restoreChunks(chunks)
+	task := newImportTask(job, columns, resume.Options, src)
+	task.upload = uploadInfo{Size: record.Size, SHA256: record.SHA256, Uploader: record.Uploader, Filename: record.Upload, Blob: record.Blob}
+	task.resume = &resume
+	task.committed = chunks
+	task.quarantined = quarantined
+	if task.quarantine {
+		for _, chunk := range chunks {
+			for _, rowErr := range chunk.Errors {
+				if !quarantined[rowErr.Line] {
+					task.unquarantined = append(task.unquarantined, rowErr)
+				}
+			}
+		}
+	}
+	checkpoint := job.Snapshot().Checkpoint
+	go func() {
+		s.runImport(task)
+		if !resume.Shared && !keepUpload([]*importTask{task}) {
+			os.Remove(resume.Path)
+		}
+	}()
+
+	utils.LogInfo("ResumeImport", fmt.Sprintf("Resuming import %s at row %d", id, checkpoint))
+	ctx.JSON(http.StatusAccepted, gin.H{
+		"status":     "accepted",
+		"message":    "Import resumed",
+		"job_id":     id,
+		"checkpoint": checkpoint,
+	})
+}
+
+// ExpireResumeUploads removes the uploads kept for resuming imports created before now
+// minus ResumeRetention, and clears the resume state of those imports, which can then no
+// longer be resumed. Imports running again are left for the next sweep. It returns the
+// number of imports that expired.
+func (s *Service) ExpireResumeUploads(now time.Time) (int, error) {
+	if s.ResumeRetention <= 0 {
+		return 0, nil
+	}
+	records, err := s.Repo.ListResumableImports(now.Add(-s.ResumeRetention))
+	if err != nil {
+		return 0, err
+	}
+
+	expired := 0
+	for _, record := range records {
+		if job, ok := s.Jobs.Get(record.ID); ok && !job.finished() {
+			continue
+		}
+		var resume resumeState
+		if json.Unmarshal([]byte(record.ResumeState), &resume) == nil && resume.Path != "" {
+			// Imports sharing the upload expire together, so the first one removes it
+			if err := os.Remove(resume.Path); err != nil && !errors.Is(err, fs.ErrNotExist) {
+				utils.LogError("ExpireResumeUploads", "Failed to remove upload of import "+record.ID, err)
+				continue
+			}
+		}
+		record.ResumeState = ""
+		if err := s.Repo.SaveImport(&record); err != nil {
+			return expired, err
+		}
+		expired++
+	}
+	return expired, nil
+}
+
+// RunResumeSweep removes expired resume uploads until ctx is cancelled.
+func (s *Service) RunResumeSweep(ctx context.Context) {
+	ticker := time.NewTicker(resumeSweepInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			expired, err := s.ExpireResumeUploads(time.Now())
+			if err != nil {
+				utils.LogError("ExpireResumeUploads", "Failed to expire resume uploads", err)
+			}
+			if expired > 0 {
+				utils.LogInfo("ExpireResumeUploads", fmt.Sprintf("Removed the uploads of %d imports past their resume retention", expired))
+			}
+		}
+	}
+}
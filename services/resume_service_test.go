@@ -0,0 +1,329 @@
+package services
+
+import (
+	"csv-microservice/mock"
+	"csv-microservice/models"
+	repository "csv-microservice/repositories"
+	"csv-microservice/utils"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/assert"
+	"gorm.io/gorm"
+)
+
+// storedUpload writes a CSV of n users to a temporary upload and returns the state needed
+// to resume an import of it.
+func storedUpload(t *testing.T, n int) resumeState {
+	var content strings.Builder
+	content.WriteString("first_name,last_name,email\n")
+	for i := 0; i < n; i++ {
+		fmt.Fprintf(&content, "User,%d,user%d@example.com\n", i, i)
+	}
+	path := filepath.Join(t.TempDir(), "upload-1")
+	assert.NoError(t, os.WriteFile(path, []byte(content.String()), 0644))
+	return resumeState{Path: path, Filename: "users.csv", Source: "users.csv", Profile: DefaultMappingProfile, Options: defaultImportOptions}
+}
+
+func TestImport_CancelAndResume(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockRepo := mock.NewMockRepositoryInterface(ctrl)
+	service := NewService(mockRepo)
+	utils.InitLogger()
+
+	router := gin.Default()
+	router.POST("/imports/:id/resume", service.ResumeImport)
+
+	resume := storedUpload(t, 150)
+	src, err := resume.source()
+	assert.NoError(t, err)
+	columns, err := resolveSourceColumns(src, resume.Profile)
+	assert.NoError(t, err)
+
+	// The first run is cancelled while it writes its first chunk
+	job := service.Jobs.Create("users.csv", defaultImportOptions)
+	id := job.Snapshot().ID
+	task := newImportTask(job, columns, defaultImportOptions, src)
+	var committed []models.ImportChunk
+	mockRepo.EXPECT().BulkInsert(gomock.Len(insertBatchSize)).Return(nil).Times(1)
+	mockRepo.EXPECT().WriteChunk(gomock.Any(), gomock.Any()).DoAndReturn(func(chunk *models.ImportChunk, write func(repo repository.RepositoryInterface) error) error {
+		job.Cancel()
+		err := write(mockRepo)
+		committed = append(committed, *chunk)
+		return err
+	}).Times(1)
+
+	err = service.importFile(task, service.Scheduler.open(1))
+	job.finish(err)
+	assert.ErrorIs(t, err, errImportCancelled)
+	assert.Equal(t, []models.ImportChunk{{ImportID: id, Offset: 0, Rows: 100, Inserted: 100}}, committed)
+	assert.Equal(t, models.JobCancelled, job.Snapshot().State)
+	assert.Equal(t, 100, job.Snapshot().Checkpoint)
+
+	// The resumed run only writes the rows after the committed chunk
+	state, _ := json.Marshal(resume)
+	mockRepo.EXPECT().GetImport(id).Return(models.Import{ID: id, Filename: "users.csv", State: models.JobCancelled, ResumeState: string(state)}, nil)
+	mockRepo.EXPECT().ListImportChunks(id).Return(committed, nil)
+	mockRepo.EXPECT().BulkInsert(gomock.Len(50)).Return(nil).Times(1)
+	expectImportAudit(mockRepo)
+
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest(http.MethodPost, "/imports/"+id+"/resume", nil)
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusAccepted, w.Code)
+	assert.JSONEq(t, `{"status":"accepted","message":"Import resumed","job_id":"`+id+`","checkpoint":100}`, w.Body.String())
+
+	resumed, _ := service.Jobs.Get(id)
+	resumed.Wait()
+	snapshot := resumed.Snapshot()
+	assert.Equal(t, models.JobCompleted, snapshot.State)
+	assert.Equal(t, 150, snapshot.RowsRead)
+	assert.Equal(t, 150, snapshot.RowsInserted)
+	assert.Equal(t, 150, snapshot.Checkpoint)
+}
+
+func TestResumeImport(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockRepo := mock.NewMockRepositoryInterface(ctrl)
+	service := NewService(mockRepo)
+	utils.InitLogger()
+
+	router := gin.Default()
+	router.POST("/imports/:id/resume", service.ResumeImport)
+
+	state, _ := json.Marshal(storedUpload(t, 1))
+	gone, _ := json.Marshal(resumeState{Path: filepath.Join(t.TempDir(), "missing"), Filename: "users.csv", Source: "users.csv"})
+	running := service.Jobs.Create("users.csv", defaultImportOptions)
+	running.start()
+
+	tests := []struct {
+		name           string
+		id             string
+		mockSetup      func(id string)
+		expectedStatus int
+		expectedBody   string
+	}{
+		{
+			name: "Completed",
+			id:   "abc",
+			mockSetup: func(id string) {
+				mockRepo.EXPECT().GetImport(id).Return(models.Import{ID: id, State: models.JobCompleted}, nil)
+			},
+			expectedStatus: http.StatusConflict,
+			expectedBody:   `{"status":"error","message":"Import has already completed"}`,
+		},
+		{
+			name: "Reverted",
+			id:   "abc",
+			mockSetup: func(id string) {
+				mockRepo.EXPECT().GetImport(id).Return(models.Import{ID: id, State: models.ImportReverted, ResumeState: string(state)}, nil)
+			},
+			expectedStatus: http.StatusConflict,
+			expectedBody:   `{"status":"error","message":"Import was already reverted"}`,
+		},
+		{
+			name: "Atomic import",
+			id:   "abc",
+			mockSetup: func(id string) {
+				mockRepo.EXPECT().GetImport(id).Return(models.Import{ID: id, State: models.JobFailed}, nil)
+			},
+			expectedStatus: http.StatusConflict,
+			expectedBody:   `{"status":"error","message":"Import cannot be resumed"}`,
+		},
+		{
+			name: "Upload removed",
+			id:   "abc",
+			mockSetup: func(id string) {
+				mockRepo.EXPECT().GetImport(id).Return(models.Import{ID: id, State: models.JobFailed, ResumeState: string(gone)}, nil)
+			},
+			expectedStatus: http.StatusGone,
+			expectedBody:   `{"status":"error","message":"Uploaded file is no longer available"}`,
+		},
+		{
+			name: "Still running",
+			id:   running.Snapshot().ID,
+			mockSetup: func(id string) {
+				mockRepo.EXPECT().GetImport(id).Return(models.Import{ID: id, State: models.JobRunning, ResumeState: string(state)}, nil)
+				mockRepo.EXPECT().ListImportChunks(id).Return(nil, nil)
+			},
+			expectedStatus: http.StatusConflict,
+			expectedBody:   `{"status":"error","message":"Import is still running"}`,
+		},
+		{
+			name: "Not found",
+			id:   "missing",
+			mockSetup: func(id string) {
+				mockRepo.EXPECT().GetImport(id).Return(models.Import{}, gorm.ErrRecordNotFound)
+			},
+			expectedStatus: http.StatusNotFound,
+			expectedBody:   `{"status":"error","message":"Import not found"}`,
+		},
+		{
+			name: "Database error",
+			id:   "abc",
+			mockSetup: func(id string) {
+				mockRepo.EXPECT().GetImport(id).Return(models.Import{}, errors.New("db error"))
+			},
+			expectedStatus: http.StatusInternalServerError,
+			expectedBody:   `{"status":"error","message":"Failed to fetch import"}`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.mockSetup(tt.id)
+
+			w := httptest.NewRecorder()
+			req, _ := http.NewRequest(http.MethodPost, "/imports/"+tt.id+"/resume", nil)
+			router.ServeHTTP(w, req)
+
+			assert.Equal(t, tt.expectedStatus, w.Code)
+			assert.JSONEq(t, tt.expectedBody, w.Body.String())
+		})
+	}
+}
+
+func TestImport_ResumeRowErrors(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockRepo := mock.NewMockRepositoryInterface(ctrl)
+	service := NewService(mockRepo)
+	utils.InitLogger()
+
+	router := gin.Default()
+	router.POST("/imports/:id/resume", service.ResumeImport)
+
+	// Line 7 of the upload lacks the email column
+	resume := storedUpload(t, 150)
+	content, err := os.ReadFile(resume.Path)
+	assert.NoError(t, err)
+	lines := strings.Split(string(content), "\n")
+	lines[6] = "User,5"
+	content = []byte(strings.Join(lines, "\n"))
+	assert.NoError(t, os.WriteFile(resume.Path, content, 0644))
+	src, err := resume.source()
+	assert.NoError(t, err)
+	columns, err := resolveSourceColumns(src, resume.Profile)
+	assert.NoError(t, err)
+
+	// The first run stops after its first chunk, before it quarantined the rejected row
+	job := service.Jobs.Create("users.csv", defaultImportOptions)
+	id := job.Snapshot().ID
+	task := newImportTask(job, columns, defaultImportOptions, src)
+	var committed []models.ImportChunk
+	mockRepo.EXPECT().BulkInsert(gomock.Len(insertBatchSize - 1)).Return(nil).Times(1)
+	mockRepo.EXPECT().WriteChunk(gomock.Any(), gomock.Any()).DoAndReturn(func(chunk *models.ImportChunk, write func(repo repository.RepositoryInterface) error) error {
+		job.Cancel()
+		err := write(mockRepo)
+		committed = append(committed, *chunk)
+		return err
+	}).Times(1)
+
+	err = service.importFile(task, service.Scheduler.open(1))
+	job.finish(err)
+	assert.Len(t, committed, 1)
+	assert.Equal(t, 1, committed[0].Failed)
+	assert.Equal(t, job.RowErrors(), committed[0].Errors)
+	assert.Equal(t, 7, committed[0].Errors[0].Line)
+
+	state, _ := json.Marshal(resume)
+	mockRepo.EXPECT().GetImport(id).Return(models.Import{ID: id, Filename: "users.csv", State: models.JobCancelled, ResumeState: string(state)}, nil).Times(2)
+	mockRepo.EXPECT().ListImportChunks(id).Return(committed, nil).Times(2)
+	mockRepo.EXPECT().BulkInsert(gomock.Len(50)).Return(nil).Times(2)
+	mockRepo.EXPECT().SaveImport(gomock.Any()).Return(nil).AnyTimes()
+	expectChunks(mockRepo)
+
+	resumeImport := func() *Job {
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest(http.MethodPost, "/imports/"+id+"/resume", nil)
+		router.ServeHTTP(w, req)
+		assert.Equal(t, http.StatusAccepted, w.Code)
+
+		resumed, _ := service.Jobs.Get(id)
+		resumed.Wait()
+		return resumed
+	}
+
+	// The resumed run reports the rejected row again and quarantines it
+	var quarantined []models.QuarantinedRow
+	mockRepo.EXPECT().ListQuarantine(id, "", 0, -1).Return(nil, int64(0), nil)
+	mockRepo.EXPECT().QuarantineRows(gomock.Any()).DoAndReturn(func(rows []models.QuarantinedRow) error {
+		quarantined = rows
+		return nil
+	}).Times(1)
+
+	resumed := resumeImport()
+	assert.Equal(t, 1, resumed.Snapshot().RowsFailed)
+	assert.Equal(t, committed[0].Errors, resumed.RowErrors())
+	assert.Len(t, quarantined, 1)
+	assert.Equal(t, 7, quarantined[0].Line)
+
+	// A row the earlier run already quarantined is not stored twice. The completed run
+	// removed the upload, so store it again.
+	assert.NoError(t, os.WriteFile(resume.Path, content, 0644))
+	mockRepo.EXPECT().ListQuarantine(id, "", 0, -1).Return(quarantined, int64(1), nil)
+
+	resumed = resumeImport()
+	assert.Equal(t, committed[0].Errors, resumed.RowErrors())
+}
+
+func TestExpireResumeUploads(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockRepo := mock.NewMockRepositoryInterface(ctrl)
+	service := NewService(mockRepo)
+	service.ResumeRetention = 72 * time.Hour
+	utils.InitLogger()
+
+	stale := storedUpload(t, 1)
+	running := storedUpload(t, 1)
+	staleState, _ := json.Marshal(stale)
+	runningState, _ := json.Marshal(running)
+	job := service.Jobs.Create("running.csv", defaultImportOptions)
+	job.start()
+
+	now := time.Now()
+	mockRepo.EXPECT().ListResumableImports(now.Add(-72*time.Hour)).Return([]models.Import{
+		{ID: "stale", ResumeState: string(staleState)},
+		{ID: job.Snapshot().ID, ResumeState: string(runningState)},
+	}, nil)
+	var saved []models.Import
+	mockRepo.EXPECT().SaveImport(gomock.Any()).DoAndReturn(func(record *models.Import) error {
+		saved = append(saved, *record)
+		return nil
+	})
+
+	expired, err := service.ExpireResumeUploads(now)
+	assert.NoError(t, err)
+	assert.Equal(t, 1, expired)
+	assert.Equal(t, []models.Import{{ID: "stale"}}, saved)
+	_, err = os.Stat(stale.Path)
+	assert.True(t, errors.Is(err, fs.ErrNotExist))
+	_, err = os.Stat(running.Path)
+	assert.NoError(t, err, "an import running again keeps its upload")
+
+	t.Run("Kept forever", func(t *testing.T) {
+		service.ResumeRetention = 0
+		expired, err := service.ExpireResumeUploads(now)
+		assert.NoError(t, err)
+		assert.Equal(t, 0, expired)
+	})
+}
@@ -0,0 +1,186 @@
+package services
+
+import (
+	"sync"
+)
+
+// defaultWorkers is the size of the worker pool when none is configured.
+const defaultWorkers = 10
+
+// Scheduler runs the chunks of every import on one pool of workers, so concurrent uploads
+// share a bounded number of database writers. Imports with waiting chunks are served
+// round robin, so a large upload cannot starve a small one.
+type Scheduler struct {
+	mu      sync.Mutex
+	ready   *sync.Cond // Signalled when a chunk is queued or a running chunk finishes
+	free    *sync.Cond // Signalled when a worker is no longer busy
+	queues  []*importQueue
+	next    int // Queue served first by the next free worker
+	workers int
+	busy    int // Workers running a chunk of an open import or reserved by one
+	start   sync.Once
+}
+
+// importQueue holds the chunks of one import waiting for a worker.
+type importQueue struct {
+	scheduler *Scheduler
+	changed   *sync.Cond // Signalled when a chunk is taken or finishes
+	pending   []func()
+	capacity  int // Chunks that may wait before Submit blocks the reader
+	limit     int // Chunks of this import that may run at once
+	running   int
+	reserved  bool // Holds a worker from reserve until release
+}
+
+// NewScheduler returns a scheduler with the given number of workers. The workers start
+// with the first import.
+func NewScheduler(workers int) *Scheduler {
+	if workers < 1 {
+		workers = defaultWorkers
+	}
+	s := &Scheduler{workers: workers}
+	s.ready = sync.NewCond(&s.mu)
+	s.free = sync.NewCond(&s.mu)
+	return s
+}
+
+// Workers returns the size of the worker pool.
+func (s *Scheduler) Workers() int {
+	return s.workers
+}
+
+// open registers an import. At most limit of its chunks run at once, or as many as there
+// are workers when limit is 0.
+func (s *Scheduler) open(limit int) *importQueue {
+	s.startWorkers()
+	if limit < 1 || limit > s.workers {
+		limit = s.workers
+	}
+	q := &importQueue{scheduler: s, changed: sync.NewCond(&s.mu), capacity: limit, limit: limit}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.queues = append(s.queues, q)
+	return q
+}
+
+// reserve registers an import that holds one worker until it is released, and runs its
+// chunks one at a time on that worker. It blocks until a worker is free; the other
+// imports share the remaining ones meanwhile. An atomic import reserves its worker before
+// its transaction begins, because the transaction keeps a database connection for its
+// whole run, also while its chunks wait in the queue.
+func (s *Scheduler) reserve() *importQueue {
+	s.startWorkers()
+	q := &importQueue{scheduler: s, changed: sync.NewCond(&s.mu), capacity: 1, limit: 1, reserved: true}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for s.busy >= s.workers {
+		s.free.Wait()
+	}
+	s.busy++
+	s.queues = append(s.queues, q)
+	return q
+}
+
+// release gives back the worker of a reserved import. Closing the import does not, so the
+// worker stays reserved until its transaction has ended.
+func (q *importQueue) release() {
+	s := q.scheduler
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if !q.reserved {
+		return
+	}
+	for q.running > 0 {
+		q.changed.Wait()
+	}
+	q.reserved = false
+	s.busy--
+	s.free.Broadcast()
+	s.ready.Broadcast() // Other imports may run another chunk now
+}
+
+func (s *Scheduler) startWorkers() {
+	s.start.Do(func() {
+		for i := 0; i < s.workers; i++ {
+			go s.work()
+		}
+	})
+}
+
+// submit queues a chunk of the import. It blocks while the import already has as many
+// chunks waiting as it may run, which holds back a reader that is faster than the database.
+func (q *importQueue) submit(fn func()) {
+	s := q.scheduler
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for len(q.pending) >= q.capacity {
+		q.changed.Wait()
+	}
+	q.pending = append(q.pending, fn)
+	s.ready.Signal()
+}
+
+// close waits for the queued chunks of the import to finish and unregisters it.
+func (q *importQueue) close() {
+	s := q.scheduler
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for len(q.pending) > 0 || q.running > 0 {
+		q.changed.Wait()
+	}
+	for i, queue := range s.queues {
+		if queue == q {
+			s.queues = append(s.queues[:i], s.queues[i+1:]...)
+			if s.next > i {
+				s.next--
+			}
+			break
+		}
+	}
+}
+
+// take picks the next chunk round robin, skipping imports at their limit. Chunks of a
+// reserved import run on its own worker; the others wait for a worker nobody reserved. It
+// must be called with s.mu held.
+func (s *Scheduler) take() (*importQueue, func()) {
+	for i := 0; i < len(s.queues); i++ {
+		idx := (s.next + i) % len(s.queues)
+		q := s.queues[idx]
+		if len(q.pending) == 0 || q.running >= q.limit || (!q.reserved && s.busy >= s.workers) {
+			continue
+		}
+		fn := q.pending[0]
+		q.pending = q.pending[1:]
+		q.running++
+		if !q.reserved {
+			s.busy++
+		}
+		s.next = (idx + 1) % len(s.queues)
+		q.changed.Broadcast()
+		return q, fn
+	}
+	return nil, nil
+}
+
+func (s *Scheduler) work() {
+	s.mu.Lock()
+	for {
+		q, fn := s.take()
+		if fn == nil {
+			s.ready.Wait()
+			continue
+		}
+		s.mu.Unlock()
+		fn()
+		s.mu.Lock()
+		q.running--
+		if !q.reserved {
+			s.busy--
+			s.free.Broadcast()
+		}
+		q.changed.Broadcast()
+		s.ready.Broadcast() // The import may run another chunk now
+	}
+}
@@ -0,0 +1,157 @@
+package services
+
+import (
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// concurrency tracks how many calls run at once and the highest number seen.
+type concurrency struct {
+	mu            sync.Mutex
+	running, peak int
+}
+
+func (c *concurrency) enter() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.running++
+	if c.running > c.peak {
+		c.peak = c.running
+	}
+}
+
+func (c *concurrency) leave() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.running--
+}
+
+func TestScheduler_BoundsWorkers(t *testing.T) {
+	scheduler := NewScheduler(3)
+	var all, atomicImport concurrency
+
+	var wg sync.WaitGroup
+	for i := 0; i < 4; i++ {
+		limit := 0
+		if i == 0 {
+			limit = 1 // Like an atomic import
+		}
+		wg.Add(1)
+		go func(limit int) {
+			defer wg.Done()
+			queue := scheduler.open(limit)
+			for j := 0; j < 20; j++ {
+				queue.submit(func() {
+					if limit == 1 {
+						atomicImport.enter()
+						defer atomicImport.leave()
+					}
+					all.enter()
+					defer all.leave()
+					time.Sleep(time.Millisecond)
+				})
+			}
+			queue.close()
+		}(limit)
+	}
+	wg.Wait()
+
+	assert.Equal(t, 3, all.peak)
+	assert.Equal(t, 1, atomicImport.peak)
+	assert.Empty(t, scheduler.queues, "Closed imports are unregistered")
+}
+
+func TestScheduler_RoundRobin(t *testing.T) {
+	scheduler := NewScheduler(1)
+	first := scheduler.open(0)
+	second := scheduler.open(0)
+
+	var mu sync.Mutex
+	var order []string
+	record := func(name string) func() {
+		return func() {
+			mu.Lock()
+			order = append(order, name)
+			mu.Unlock()
+		}
+	}
+
+	started, release := make(chan struct{}), make(chan struct{})
+	first.submit(func() {
+		close(started)
+		<-release
+		record("a0")()
+	})
+	<-started
+	first.submit(record("a1"))
+	second.submit(record("b1"))
+
+	// The first import has a chunk waiting already, so the reader is held back
+	submitted := make(chan struct{})
+	go func() {
+		first.submit(record("a2"))
+		close(submitted)
+	}()
+	select {
+	case <-submitted:
+		t.Fatal("submit should block while the import has a chunk waiting")
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	close(release)
+	<-submitted
+	first.close()
+	second.close()
+
+	assert.Equal(t, []string{"a0", "b1", "a1", "a2"}, order)
+}
+
+func TestScheduler_Reserve(t *testing.T) {
+	scheduler := NewScheduler(2)
+	atomicImport := scheduler.reserve()
+
+	// The other imports share the worker left
+	var others concurrency
+	queue := scheduler.open(0)
+	for i := 0; i < 10; i++ {
+		queue.submit(func() {
+			others.enter()
+			defer others.leave()
+			time.Sleep(time.Millisecond)
+		})
+	}
+	queue.close()
+	assert.Equal(t, 1, others.peak)
+
+	// With every worker reserved, a reserved import still runs its chunks on its own
+	second := scheduler.reserve()
+	ran := make(chan struct{})
+	atomicImport.submit(func() { close(ran) })
+	select {
+	case <-ran:
+	case <-time.After(time.Second):
+		t.Fatal("the chunk of a reserved import should run on its worker")
+	}
+	atomicImport.close()
+
+	// Another reservation waits until a worker is released
+	reserved := make(chan *importQueue)
+	go func() { reserved <- scheduler.reserve() }()
+	select {
+	case <-reserved:
+		t.Fatal("reserve should block while every worker is reserved")
+	case <-time.After(50 * time.Millisecond):
+	}
+	atomicImport.release()
+	third := <-reserved
+
+	for _, q := range []*importQueue{second, third} {
+		q.close()
+		q.release()
+	}
+	assert.Empty(t, scheduler.queues, "Closed imports are unregistered")
+	assert.Equal(t, 0, scheduler.busy)
+}
@@ -0,0 +1,253 @@
+package services
+
+import (
+	"archive/zip"
+	"bufio"
+	"compress/gzip"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"strings"
+)
+
+// importSource is one table of an upload: the file itself, a gunzipped file, a CSV
+// member of a zip archive, or a worksheet of an Excel workbook. Each source is imported
+// as its own job.
+type importSource struct {
+	Name  string
+	open  func() (recordReader, error)
+	typed bool // Cells come from typed values (Excel, JSON), whose numbers ignore the locale
+}
+
+// recordReader yields the rows of a source along with the line (or worksheet row)
+// number each one came from. The first row is the header.
+type recordReader interface {
+	Read() (record []string, line int, err error)
+	Close() error
+}
+
+// columnAware is implemented by readers whose cell values depend on the field a column
+// is mapped to, such as Excel date serials.
+type columnAware interface {
+	useColumns(columns columnMap)
+}
+
+// csvRecordReader reads rows from a CSV stream.
+type csvRecordReader struct {
+	reader *csv.Reader
+	closer io.Closer
+}
+
+func newCSVRecordReader(stream io.ReadCloser, dialect csvDialect) (*csvRecordReader, error) {
+	buffered := bufio.NewReaderSize(stream, sniffSize)
+	sample, err := buffered.Peek(sniffSize)
+	if err != nil && err != io.EOF {
+		return nil, err
+	}
+	dialect, err = dialect.resolve(sample)
+	if err != nil {
+		return nil, err
+	}
+
+	reader := csv.NewReader(dialect.decode(buffered))
+	reader.Comma = dialect.Delimiter
+	reader.Comment = dialect.Comment
+	reader.LazyQuotes = dialect.LazyQuotes
+	reader.FieldsPerRecord = -1 // Column counts are checked per row so short rows get a clear reason
+	return &csvRecordReader{reader: reader, closer: stream}, nil
+}
+
+func (c *csvRecordReader) Read() ([]string, int, error) {
+	record, err := c.reader.Read()
+	if err != nil {
+		line := 0
+		var parseErr *csv.ParseError
+		if errors.As(err, &parseErr) {
+			line = parseErr.StartLine
+		}
+		return record, line, err
+	}
+	line, _ := c.reader.FieldPos(0)
+	return record, line, nil
+}
+
+func (c *csvRecordReader) Close() error {
+	return c.closer.Close()
+}
+
+// sliceRecordReader yields rows held in memory, such as quarantined rows being resubmitted.
+type sliceRecordReader struct {
+	rows  [][]string // Header first
+	lines []int      // Line number reported for each row
+	next  int
+}
+
+func (r *sliceRecordReader) Read() ([]string, int, error) {
+	if r.next >= len(r.rows) {
+		return nil, 0, io.EOF
+	}
+	r.next++
+	return r.rows[r.next-1], r.lines[r.next-1], nil
+}
+
+func (r *sliceRecordReader) Close() error {
+	return nil
+}
+
+// openCSV wraps a stream opener so the source yields CSV rows in the given dialect.
+func openCSV(open func() (io.ReadCloser, error), dialect csvDialect) func() (recordReader, error) {
+	return func() (recordReader, error) {
+		stream, err := open()
+		if err != nil {
+			return nil, err
+		}
+		reader, err := newCSVRecordReader(stream, dialect)
+		if err != nil {
+			stream.Close()
+			return nil, err
+		}
+		return reader, nil
+	}
+}
+
+// isArchive reports whether an upload may contain several CSV files.
+func isArchive(filename string) bool {
+	return strings.EqualFold(path.Ext(filename), ".zip")
+}
+
+// supportedUpload reports whether the file extension is one UploadCSV accepts.
+func supportedUpload(filename string) bool {
+	lower := strings.ToLower(filename)
+	return strings.HasSuffix(lower, ".csv") || strings.HasSuffix(lower, ".csv.gz") ||
+		strings.HasSuffix(lower, ".zip") || strings.HasSuffix(lower, ".xlsx")
+}
+
+// uploadSources lists the tables in a stored upload. Gzip files and zip members are
+// decompressed while they are read, never extracted to disk. sheet selects the worksheet
+// of an Excel workbook; dialect applies to CSV files.
+func uploadSources(storedPath, filename, sheet string, dialect csvDialect) ([]importSource, error) {
+	lower := strings.ToLower(filename)
+	switch {
+	case strings.HasSuffix(lower, ".xlsx"):
+		return excelSources(storedPath, filename, sheet)
+	case strings.HasSuffix(lower, ".gz"):
+		// Open once so a corrupt file is refused before a job is queued.
+		reader, err := openGzip(storedPath)
+		if err != nil {
+			return nil, err
+		}
+		reader.Close()
+		return []importSource{{
+			Name: filename[:len(filename)-len(".gz")],
+			open: openCSV(func() (io.ReadCloser, error) { return openGzip(storedPath) }, dialect),
+		}}, nil
+	case strings.HasSuffix(lower, ".zip"):
+		return zipSources(storedPath, filename, dialect)
+	default:
+		return []importSource{{
+			Name: filename,
+			open: openCSV(func() (io.ReadCloser, error) { return os.Open(storedPath) }, dialect),
+		}}, nil
+	}
+}
+
+// gzipReader closes both the decompressor and the underlying file.
+type gzipReader struct {
+	*gzip.Reader
+	file *os.File
+}
+
+func (g gzipReader) Close() error {
+	g.Reader.Close()
+	return g.file.Close()
+}
+
+func openGzip(storedPath string) (io.ReadCloser, error) {
+	file, err := os.Open(storedPath)
+	if err != nil {
+		return nil, err
+	}
+	reader, err := gzip.NewReader(file)
+	if err != nil {
+		file.Close()
+		return nil, fmt.Errorf("invalid gzip file: %w", err)
+	}
+	return gzipReader{Reader: reader, file: file}, nil
+}
+
+// zipSources lists the CSV members of a zip archive, skipping directories and macOS metadata.
+func zipSources(storedPath, filename string, dialect csvDialect) ([]importSource, error) {
+	archive, err := zip.OpenReader(storedPath)
+	if err != nil {
+		return nil, fmt.Errorf("invalid zip file: %w", err)
+	}
+	defer archive.Close()
+
+	var sources []importSource
+	for _, member := range archive.File {
+		name := member.Name
+		if member.FileInfo().IsDir() || strings.HasPrefix(name, "__MACOSX/") || !strings.EqualFold(path.Ext(name), ".csv") {
+			continue
+		}
+		sources = append(sources, importSource{
+			Name: filename + "/" + name,
+			open: openCSV(func() (io.ReadCloser, error) { return openZipMember(storedPath, name) }, dialect),
+		})
+	}
+	if len(sources) == 0 {
+		return nil, errors.New("zip file contains no CSV files")
+	}
+	return sources, nil
+}
+
+// zipMemberReader closes both the member stream and the archive.
+type zipMemberReader struct {
+	io.ReadCloser
+	archive *zip.ReadCloser
+}
+
+func (z zipMemberReader) Close() error {
+	z.ReadCloser.Close()
+	return z.archive.Close()
+}
+
+func openZipMember(storedPath, name string) (io.ReadCloser, error) {
+	archive, err := zip.OpenReader(storedPath)
+	if err != nil {
+		return nil, err
+	}
+	for _, member := range archive.File {
+		if member.Name != name {
+			continue
+		}
+		reader, err := member.Open()
+		if err != nil {
+			archive.Close()
+			return nil, err
+		}
+		return zipMemberReader{ReadCloser: reader, archive: archive}, nil
+	}
+	archive.Close()
+	return nil, fmt.Errorf("zip member %s not found", name)
+}
+
+// resolveSourceColumns reads the header row of a source and matches it against a profile.
+func resolveSourceColumns(src importSource, profile MappingProfile) (columnMap, error) {
+	reader, err := src.open()
+	if err != nil {
+		return columnMap{}, err
+	}
+	defer reader.Close()
+
+	header, _, err := reader.Read()
+	if err == io.EOF {
+		return columnMap{}, errors.New("file is empty, expected a header row")
+	}
+	if err != nil {
+		return columnMap{}, err
+	}
+	return resolveColumns(header, profile)
+}
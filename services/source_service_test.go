@@ -0,0 +1,150 @@
+package services
+
+import (
+	"archive/zip"
+	"bytes"
+	"compress/gzip"
+	"csv-microservice/mock"
+	"csv-microservice/models"
+	"csv-microservice/utils"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/assert"
+)
+
+func gzipContent(t *testing.T, content string) string {
+	var buf bytes.Buffer
+	writer := gzip.NewWriter(&buf)
+	_, err := writer.Write([]byte(content))
+	assert.NoError(t, err)
+	assert.NoError(t, writer.Close())
+	return buf.String()
+}
+
+func zipContent(t *testing.T, files map[string]string) string {
+	var buf bytes.Buffer
+	writer := zip.NewWriter(&buf)
+	for name, content := range files {
+		part, err := writer.Create(name)
+		assert.NoError(t, err)
+		_, err = part.Write([]byte(content))
+		assert.NoError(t, err)
+	}
+	assert.NoError(t, writer.Close())
+	return buf.String()
+}
+
+func TestUploadCSV_Compressed(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockRepo := mock.NewMockRepositoryInterface(ctrl)
+	expectImportAudit(mockRepo)
+	service := NewService(mockRepo)
+	utils.InitLogger()
+
+	router := gin.Default()
+	router.POST("/upload", service.UploadCSV)
+
+	valid := "id,first_name,last_name,email\n1,John,Doe,john@example.com\n"
+
+	t.Run("Gzip file", func(t *testing.T) {
+		mockRepo.EXPECT().BulkInsert(gomock.Len(1)).Return(nil).Times(1)
+
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, newUploadRequest(t, "/upload", "users.csv.gz", gzipContent(t, valid), nil))
+		assert.Equal(t, http.StatusAccepted, w.Code)
+
+		var resp struct {
+			JobID string `json:"job_id"`
+		}
+		assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &resp))
+		job, ok := service.Jobs.Get(resp.JobID)
+		assert.True(t, ok)
+		job.Wait()
+		assert.Equal(t, "users.csv", job.Snapshot().Filename)
+		assert.Equal(t, 1, job.Snapshot().RowsInserted)
+	})
+
+	t.Run("Corrupt gzip file", func(t *testing.T) {
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, newUploadRequest(t, "/upload", "users.csv.gz", "not gzip", nil))
+
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+		assert.Contains(t, w.Body.String(), "Invalid upload: invalid gzip file")
+	})
+
+	t.Run("Zip archive", func(t *testing.T) {
+		mockRepo.EXPECT().BulkInsert(gomock.Len(1)).Return(nil).Times(2)
+
+		archive := zipContent(t, map[string]string{
+			"a.csv":          valid,
+			"nested/b.csv":   "first_name,last_name,email\nJane,Roe,jane@example.com\n",
+			"bad.csv":        "name\nJim\n",
+			"readme.txt":     "ignored",
+			"__MACOSX/a.csv": "ignored",
+		})
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, newUploadRequest(t, "/upload", "bundle.zip", archive, nil))
+		assert.Equal(t, http.StatusAccepted, w.Code)
+
+		var resp struct {
+			Jobs []struct {
+				File  string `json:"file"`
+				JobID string `json:"job_id"`
+			} `json:"jobs"`
+			Rejected []struct {
+				File  string `json:"file"`
+				Error string `json:"error"`
+			} `json:"rejected"`
+		}
+		assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &resp))
+		assert.Len(t, resp.Jobs, 2)
+		assert.Len(t, resp.Rejected, 1)
+		assert.Equal(t, "bundle.zip/bad.csv", resp.Rejected[0].File)
+		assert.Equal(t, "Invalid CSV header: missing required columns: first_name, last_name, email", resp.Rejected[0].Error)
+
+		files := map[string]bool{}
+		for _, queued := range resp.Jobs {
+			job, ok := service.Jobs.Get(queued.JobID)
+			assert.True(t, ok)
+			job.Wait()
+			assert.Equal(t, models.JobCompleted, job.Snapshot().State)
+			assert.Equal(t, 1, job.Snapshot().RowsInserted)
+			files[queued.File] = true
+		}
+		assert.Equal(t, map[string]bool{"bundle.zip/a.csv": true, "bundle.zip/nested/b.csv": true}, files)
+	})
+
+	t.Run("Zip archive dry run", func(t *testing.T) {
+		archive := zipContent(t, map[string]string{"a.csv": valid})
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, newUploadRequest(t, "/upload?dry_run=true", "bundle.zip", archive, nil))
+
+		assert.Equal(t, http.StatusOK, w.Code)
+		var resp struct {
+			Data []struct {
+				Filename  string `json:"filename"`
+				RowsValid int    `json:"rows_valid"`
+			} `json:"data"`
+		}
+		assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &resp))
+		assert.Len(t, resp.Data, 1)
+		assert.Equal(t, "bundle.zip/a.csv", resp.Data[0].Filename)
+		assert.Equal(t, 1, resp.Data[0].RowsValid)
+	})
+
+	t.Run("Zip without CSV files", func(t *testing.T) {
+		archive := zipContent(t, map[string]string{"readme.txt": "nothing"})
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, newUploadRequest(t, "/upload", "bundle.zip", archive, nil))
+
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+		assert.JSONEq(t, `{"error":"Invalid upload: zip file contains no CSV files"}`, w.Body.String())
+	})
+}
@@ -0,0 +1,208 @@
+package services
+
+import (
+	"csv-microservice/models"
+	repository "csv-microservice/repositories"
+	"csv-microservice/utils"
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// stagedRows turns parsed records of a staged import into staging rows. Without merge an
+// approved update overwrites every column, which nil Fields stands for.
+func stagedRows(records []models.UserRecord, opts importOptions) []models.StagedRow {
+	rows := make([]models.StagedRow, len(records))
+	for i, record := range records {
+		rows[i] = models.StagedRow{User: record.User}
+		if record.User.ImportID != nil {
+			rows[i].ImportID = *record.User.ImportID
+		}
+		if opts.Merge {
+			rows[i].Fields = append([]string{}, record.Fields...)
+		}
+	}
+	return rows
+}
+
+// missingUsers returns what applying a staged import does with users missing from its
+// file. Imports that did not choose keep them.
+func missingUsers(record models.Import) string {
+	if record.Missing == "" {
+		return models.MissingKeep
+	}
+	return record.Missing
+}
+
+// stagedImport looks up the import named in the path and checks that it waits for
+// approval, responding with an error otherwise.
+func (s *Service) stagedImport(ctx *gin.Context, source string) (models.Import, bool) {
+	id := ctx.Param("id")
+	record, err := s.Repo.GetImport(id)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		utils.LogWarn(source, "Import not found: "+id)
+		ctx.JSON(http.StatusNotFound, gin.H{
+			"status":  "error",
+			"message": "Import not found",
+		})
+		return models.Import{}, false
+	}
+	if err != nil {
+		utils.LogError(source, "Error fetching import from database", err)
+		ctx.JSON(http.StatusInternalServerError, gin.H{
+			"status":  "error",
+			"message": "Failed to fetch import",
+		})
+		return models.Import{}, false
+	}
+	if record.State != models.ImportStaged {
+		ctx.JSON(http.StatusConflict, gin.H{
+			"status":  "error",
+			"message": "Import is not waiting for approval",
+			"state":   record.State,
+		})
+		return models.Import{}, false
+	}
+	return record, true
+}
+
+// DiffImport shows what approving a staged import would do to the users table: the rows
+// it inserts, the users it changes and the users it deletes or deactivates because the
+// file no longer has them.
+func (s *Service) DiffImport(ctx *gin.Context) {
+	record, ok := s.stagedImport(ctx, "DiffImport")
+	if !ok {
+		return
+	}
+	diff, err := s.Repo.DiffStagedImport(record.ID, record.Mode, missingUsers(record))
+	if err != nil {
+		utils.LogError("DiffImport", "Failed to compare staged import "+record.ID, err)
+		ctx.JSON(http.StatusInternalServerError, gin.H{
+			"status":  "error",
+			"message": "Failed to compare staged import",
+		})
+		return
+	}
+
+	ctx.JSON(http.StatusOK, gin.H{
+		"status": "success",
+		"data":   diff,
+	})
+}
+
+// ApproveImport applies a staged import to the users table in one transaction. The
+// approver, identified as described at reviewer, must not be the person who uploaded the
+// file. An import that deletes or deactivates missing users is refused when some of its
+// rows could not be read, since their users would count as missing.
+func (s *Service) ApproveImport(ctx *gin.Context) {
+	record, ok := s.stagedImport(ctx, "ApproveImport")
+	if !ok {
+		return
+	}
+	reviewer, ok := s.reviewer(ctx, "ApproveImport")
+	if !ok {
+		return
+	}
+	if reviewer == record.Uploader {
+		utils.LogWarn("ApproveImport", fmt.Sprintf("%s tried to approve their own import %s", reviewer, record.ID))
+		ctx.JSON(http.StatusForbidden, gin.H{
+			"status":  "error",
+			"message": "A staged import must be approved by someone other than its uploader",
+		})
+		return
+	}
+
+	missing := missingUsers(record)
+	if missing != models.MissingKeep && record.RowsFailed > 0 {
+		utils.LogWarn("ApproveImport", fmt.Sprintf("Refused to approve import %s: %d rows could not be read", record.ID, record.RowsFailed))
+		ctx.JSON(http.StatusUnprocessableEntity, gin.H{
+			"status":  "error",
+			"message": fmt.Sprintf("%d rows could not be read; approving would %s their users", record.RowsFailed, missing),
+		})
+		return
+	}
+
+	summary, err := s.Repo.ApproveStagedImport(record.ID, record.Mode, missing, reviewer)
+	if errors.Is(err, repository.ErrNotStaged) {
+		ctx.JSON(http.StatusConflict, gin.H{
+			"status":  "error",
+			"message": "Import is not waiting for approval",
+		})
+		return
+	}
+	if err != nil {
+		utils.LogError("ApproveImport", "Failed to apply staged import "+record.ID, err)
+		ctx.JSON(http.StatusInternalServerError, gin.H{
+			"status":  "error",
+			"message": "Failed to apply staged import",
+		})
+		return
+	}
+	utils.LogInfo("ApproveImport", fmt.Sprintf("%s approved import %s: %d inserted, %d updated, %d deleted, %d deactivated",
+		reviewer, record.ID, summary.New, summary.Changed, summary.Removed, summary.Deactivated))
+
+	ctx.JSON(http.StatusOK, gin.H{
+		"status":  "success",
+		"message": "Import approved",
+		"data":    summary,
+	})
+}
+
+// reviewer identifies who approves or rejects a staged import. When Reviewers is set, the
+// request must carry the token of one of them. Uploads are still identified by the
+// X-Uploader header unless they carry a token too, so a reviewer who uploads without
+// theirs can approve their own file. Without Reviewers the reviewer is identified like an
+// uploader, which any client can choose, and the rule only guards against mistakes. It
+// responds with an error and returns false when the request carries no valid token.
+func (s *Service) reviewer(ctx *gin.Context, source string) (string, bool) {
+	if len(s.Reviewers) == 0 {
+		return s.uploader(ctx), true
+	}
+	name, ok := s.authenticatedReviewer(ctx)
+	if !ok {
+		utils.LogWarn(source, "Rejected a review without a valid reviewer token")
+		ctx.JSON(http.StatusUnauthorized, gin.H{
+			"status":  "error",
+			"message": "A valid reviewer token is required",
+		})
+		return "", false
+	}
+	return name, true
+}
+
+// RejectImport discards a staged import. Nothing is written to the users table.
+func (s *Service) RejectImport(ctx *gin.Context) {
+	record, ok := s.stagedImport(ctx, "RejectImport")
+	if !ok {
+		return
+	}
+	reviewer, ok := s.reviewer(ctx, "RejectImport")
+	if !ok {
+		return
+	}
+	err := s.Repo.RejectStagedImport(record.ID, reviewer)
+	if errors.Is(err, repository.ErrNotStaged) {
+		ctx.JSON(http.StatusConflict, gin.H{
+			"status":  "error",
+			"message": "Import is not waiting for approval",
+		})
+		return
+	}
+	if err != nil {
+		utils.LogError("RejectImport", "Failed to reject staged import "+record.ID, err)
+		ctx.JSON(http.StatusInternalServerError, gin.H{
+			"status":  "error",
+			"message": "Failed to reject staged import",
+		})
+		return
+	}
+	utils.LogInfo("RejectImport", fmt.Sprintf("%s rejected import %s", reviewer, record.ID))
+
+	ctx.JSON(http.StatusOK, gin.H{
+		"status":  "success",
+		"message": "Import rejected",
+	})
+}
@@ -0,0 +1,349 @@
+package services
+
+import (
+	"csv-microservice/mock"
+	"csv-microservice/models"
+	repository "csv-microservice/repositories"
+	"csv-microservice/utils"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/assert"
+	"gorm.io/gorm"
+)
+
+func TestUploadCSV_Staged(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockRepo := mock.NewMockRepositoryInterface(ctrl)
+	service := NewService(mockRepo)
+	utils.InitLogger()
+
+	router := gin.Default()
+	router.POST("/upload", service.UploadCSV)
+
+	content := "first_name,last_name,email,salary\nJohn,Doe,john@example.com,50000\nJane,Roe,jane@example.com,oops\n"
+
+	t.Run("Rows wait in the staging area", func(t *testing.T) {
+		var saved models.Import
+		var staged []models.StagedRow
+		expectNewUploads(mockRepo)
+		expectChunks(mockRepo)
+		mockRepo.EXPECT().SaveImport(gomock.Any()).DoAndReturn(func(record *models.Import) error {
+			saved = *record
+			return nil
+		}).Times(2)
+		mockRepo.EXPECT().StageRows(gomock.Any(), models.ModeUpsertEmail).DoAndReturn(func(rows []models.StagedRow, mode string) error {
+			staged = append(staged, rows...)
+			return nil
+		})
+
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, newUploadRequest(t, "/upload", "users.csv", content, map[string]string{"stage": "true", "mode": models.ModeUpsertEmail}))
+		assert.Equal(t, http.StatusAccepted, w.Code)
+
+		for _, job := range service.Jobs.List(10) {
+			stored, _ := service.Jobs.Get(job.ID)
+			stored.Wait()
+		}
+		assert.Equal(t, models.ImportStaged, saved.State)
+		assert.Equal(t, models.MissingKeep, saved.Missing, "Missing users are only removed on request")
+		assert.Equal(t, 0, saved.RowsInserted)
+		assert.Equal(t, 1, saved.RowsFailed, "Rejected rows are reported, not quarantined")
+		if assert.Len(t, staged, 1) {
+			assert.Equal(t, saved.ID, staged[0].ImportID)
+			assert.Equal(t, "john@example.com", staged[0].User.Email)
+			assert.Equal(t, 50000.0, staged[0].User.Salary)
+			assert.Nil(t, staged[0].Fields, "Without merge an update overwrites every column")
+		}
+	})
+
+	t.Run("Requires an upsert mode", func(t *testing.T) {
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, newUploadRequest(t, "/upload", "users.csv", content, map[string]string{"stage": "true"}))
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+		assert.JSONEq(t, `{"error":"stage requires mode upsert_id or upsert_email to match rows with users"}`, w.Body.String())
+	})
+}
+
+func TestReviewStagedImport(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockRepo := mock.NewMockRepositoryInterface(ctrl)
+	service := NewService(mockRepo)
+	utils.InitLogger()
+
+	router := gin.Default()
+	router.GET("/imports/:id/diff", service.DiffImport)
+	router.POST("/imports/:id/approve", service.ApproveImport)
+	router.POST("/imports/:id/reject", service.RejectImport)
+
+	staged := models.Import{ID: "abc", Filename: "payroll.csv", State: models.ImportStaged, Mode: models.ModeUpsertEmail, Uploader: "alice"}
+	before := models.User{Id: 7, FirstName: "John", LastName: "Doe", Email: "john@example.com", Salary: 50000}
+	after := before
+	after.Salary = 55000
+
+	tests := []struct {
+		name           string
+		method         string
+		path           string
+		reviewer       string
+		mockSetup      func()
+		expectedStatus int
+		expectedBody   string
+	}{
+		{
+			name:   "Diff",
+			method: http.MethodGet,
+			path:   "/imports/abc/diff",
+			mockSetup: func() {
+				mockRepo.EXPECT().GetImport("abc").Return(staged, nil)
+				mockRepo.EXPECT().DiffStagedImport("abc", models.ModeUpsertEmail, models.MissingKeep).Return(models.StagedDiff{
+					Summary: models.StagedSummary{Changed: 1},
+					Changes: []models.StagedChange{{Kind: models.StagedChanged, Line: 2, Before: &before, After: &after, Columns: []string{"salary"}}},
+				}, nil)
+			},
+			expectedStatus: http.StatusOK,
+			expectedBody: `{"status":"success","data":{"summary":{"new":0,"changed":1,"removed":0,"deactivated":0,"unchanged":0},"changes":[{"kind":"changed","line":2,` +
+				`"before":{"id":7,"first_name":"John","last_name":"Doe","email":"john@example.com","age":0,"gender":"","department":"","company":"","salary":50000,"date_joined":"","is_active":false},` +
+				`"after":{"id":7,"first_name":"John","last_name":"Doe","email":"john@example.com","age":0,"gender":"","department":"","company":"","salary":55000,"date_joined":"","is_active":false},` +
+				`"columns":["salary"]}]}}`,
+		},
+		{
+			name:     "Approve",
+			method:   http.MethodPost,
+			path:     "/imports/abc/approve",
+			reviewer: "bob",
+			mockSetup: func() {
+				mockRepo.EXPECT().GetImport("abc").Return(staged, nil)
+				mockRepo.EXPECT().ApproveStagedImport("abc", models.ModeUpsertEmail, models.MissingKeep, "bob").Return(models.StagedSummary{New: 2, Changed: 1, Removed: 1}, nil)
+			},
+			expectedStatus: http.StatusOK,
+			expectedBody:   `{"status":"success","message":"Import approved","data":{"new":2,"changed":1,"removed":1,"deactivated":0,"unchanged":0}}`,
+		},
+		{
+			name:     "Approve own import",
+			method:   http.MethodPost,
+			path:     "/imports/abc/approve",
+			reviewer: "alice",
+			mockSetup: func() {
+				mockRepo.EXPECT().GetImport("abc").Return(staged, nil)
+			},
+			expectedStatus: http.StatusForbidden,
+			expectedBody:   `{"status":"error","message":"A staged import must be approved by someone other than its uploader"}`,
+		},
+		{
+			name:     "Approved concurrently",
+			method:   http.MethodPost,
+			path:     "/imports/abc/approve",
+			reviewer: "bob",
+			mockSetup: func() {
+				mockRepo.EXPECT().GetImport("abc").Return(staged, nil)
+				mockRepo.EXPECT().ApproveStagedImport("abc", models.ModeUpsertEmail, models.MissingKeep, "bob").Return(models.StagedSummary{}, repository.ErrNotStaged)
+			},
+			expectedStatus: http.StatusConflict,
+			expectedBody:   `{"status":"error","message":"Import is not waiting for approval"}`,
+		},
+		{
+			name:     "Approve fails",
+			method:   http.MethodPost,
+			path:     "/imports/abc/approve",
+			reviewer: "bob",
+			mockSetup: func() {
+				mockRepo.EXPECT().GetImport("abc").Return(staged, nil)
+				mockRepo.EXPECT().ApproveStagedImport("abc", models.ModeUpsertEmail, models.MissingKeep, "bob").Return(models.StagedSummary{}, errors.New("duplicate key"))
+			},
+			expectedStatus: http.StatusInternalServerError,
+			expectedBody:   `{"status":"error","message":"Failed to apply staged import"}`,
+		},
+		{
+			name:     "Approve removals with unreadable rows",
+			method:   http.MethodPost,
+			path:     "/imports/abc/approve",
+			reviewer: "bob",
+			mockSetup: func() {
+				record := staged
+				record.Missing, record.RowsFailed = models.MissingDelete, 2
+				mockRepo.EXPECT().GetImport("abc").Return(record, nil)
+			},
+			expectedStatus: http.StatusUnprocessableEntity,
+			expectedBody:   `{"status":"error","message":"2 rows could not be read; approving would delete their users"}`,
+		},
+		{
+			name:     "Reject",
+			method:   http.MethodPost,
+			path:     "/imports/abc/reject",
+			reviewer: "alice",
+			mockSetup: func() {
+				mockRepo.EXPECT().GetImport("abc").Return(staged, nil)
+				mockRepo.EXPECT().RejectStagedImport("abc", "alice").Return(nil)
+			},
+			expectedStatus: http.StatusOK,
+			expectedBody:   `{"status":"success","message":"Import rejected"}`,
+		},
+		{
+			name:   "Not staged",
+			method: http.MethodPost,
+			path:   "/imports/abc/approve",
+			mockSetup: func() {
+				mockRepo.EXPECT().GetImport("abc").Return(models.Import{ID: "abc", State: models.JobCompleted}, nil)
+			},
+			expectedStatus: http.StatusConflict,
+			expectedBody:   `{"status":"error","message":"Import is not waiting for approval","state":"completed"}`,
+		},
+		{
+			name:   "Not found",
+			method: http.MethodGet,
+			path:   "/imports/abc/diff",
+			mockSetup: func() {
+				mockRepo.EXPECT().GetImport("abc").Return(models.Import{}, gorm.ErrRecordNotFound)
+			},
+			expectedStatus: http.StatusNotFound,
+			expectedBody:   `{"status":"error","message":"Import not found"}`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.mockSetup()
+
+			w := httptest.NewRecorder()
+			req, _ := http.NewRequest(tt.method, tt.path, nil)
+			if tt.reviewer != "" {
+				req.Header.Set("X-Uploader", tt.reviewer)
+			}
+			router.ServeHTTP(w, req)
+
+			assert.Equal(t, tt.expectedStatus, w.Code)
+			assert.JSONEq(t, tt.expectedBody, w.Body.String())
+		})
+	}
+}
+
+func TestReviewStagedImport_ReviewerTokens(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockRepo := mock.NewMockRepositoryInterface(ctrl)
+	service := NewService(mockRepo)
+	service.Reviewers = map[string]string{"a-token": "alice", "b-token": "bob"}
+	utils.InitLogger()
+
+	router := gin.Default()
+	router.POST("/imports/:id/approve", service.ApproveImport)
+	router.POST("/imports/:id/reject", service.RejectImport)
+
+	staged := models.Import{ID: "abc", Filename: "payroll.csv", State: models.ImportStaged, Mode: models.ModeUpsertEmail, Uploader: "alice"}
+	review := func(action, token, uploader string) *httptest.ResponseRecorder {
+		req, _ := http.NewRequest(http.MethodPost, "/imports/abc/"+action, nil)
+		if token != "" {
+			req.Header.Set("Authorization", "Bearer "+token)
+		}
+		req.Header.Set("X-Uploader", uploader)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w
+	}
+
+	t.Run("The header alone is not enough", func(t *testing.T) {
+		mockRepo.EXPECT().GetImport("abc").Return(staged, nil)
+		w := review("approve", "", "bob")
+		assert.Equal(t, http.StatusUnauthorized, w.Code)
+		assert.JSONEq(t, `{"status":"error","message":"A valid reviewer token is required"}`, w.Body.String())
+	})
+
+	t.Run("Unknown token", func(t *testing.T) {
+		mockRepo.EXPECT().GetImport("abc").Return(staged, nil)
+		w := review("reject", "guess", "bob")
+		assert.Equal(t, http.StatusUnauthorized, w.Code)
+	})
+
+	t.Run("The uploader's own token", func(t *testing.T) {
+		mockRepo.EXPECT().GetImport("abc").Return(staged, nil)
+		w := review("approve", "a-token", "bob")
+		assert.Equal(t, http.StatusForbidden, w.Code)
+	})
+
+	t.Run("Another reviewer", func(t *testing.T) {
+		mockRepo.EXPECT().GetImport("abc").Return(staged, nil)
+		mockRepo.EXPECT().ApproveStagedImport("abc", models.ModeUpsertEmail, models.MissingKeep, "bob").Return(models.StagedSummary{Changed: 1}, nil)
+		w := review("approve", "b-token", "alice")
+		assert.Equal(t, http.StatusOK, w.Code)
+	})
+
+	t.Run("Uploads are recorded under the token's name", func(t *testing.T) {
+		ctx, _ := gin.CreateTestContext(httptest.NewRecorder())
+		ctx.Request = httptest.NewRequest(http.MethodPost, "/upload", nil)
+		ctx.Request.Header.Set("Authorization", "Bearer a-token")
+		ctx.Request.Header.Set("X-Uploader", "carol")
+		assert.Equal(t, "alice", service.uploader(ctx))
+	})
+}
+
+func TestApproveStagedImport_FailedRows(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockRepo := mock.NewMockRepositoryInterface(ctrl)
+	service := NewService(mockRepo)
+	utils.InitLogger()
+
+	router := gin.Default()
+	router.POST("/upload", service.UploadCSV)
+	router.POST("/imports/:id/approve", service.ApproveImport)
+
+	// Jane's row cannot be read, so she has no staged row and counts as missing
+	content := "first_name,last_name,email,salary\nJohn,Doe,john@example.com,50000\nJane,Roe,jane@example.com,oops\n"
+	expectNewUploads(mockRepo)
+	expectChunks(mockRepo)
+	mockRepo.EXPECT().StageRows(gomock.Any(), models.ModeUpsertEmail).Return(nil).AnyTimes()
+
+	stage := func(t *testing.T, fields map[string]string) models.Import {
+		var saved models.Import
+		mockRepo.EXPECT().SaveImport(gomock.Any()).DoAndReturn(func(record *models.Import) error {
+			saved = *record
+			return nil
+		}).Times(2)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, newUploadRequest(t, "/upload", "users.csv", content, fields))
+		assert.Equal(t, http.StatusAccepted, w.Code)
+		for _, job := range service.Jobs.List(10) {
+			stored, _ := service.Jobs.Get(job.ID)
+			stored.Wait()
+		}
+		assert.Equal(t, 1, saved.RowsFailed)
+		saved.Uploader = "alice"
+		return saved
+	}
+	approve := func(record models.Import) *httptest.ResponseRecorder {
+		mockRepo.EXPECT().GetImport(record.ID).Return(record, nil)
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest(http.MethodPost, "/imports/"+record.ID+"/approve", nil)
+		req.Header.Set("X-Uploader", "bob")
+		router.ServeHTTP(w, req)
+		return w
+	}
+
+	t.Run("Missing users are kept", func(t *testing.T) {
+		record := stage(t, map[string]string{"stage": "true", "mode": models.ModeUpsertEmail})
+		mockRepo.EXPECT().ApproveStagedImport(record.ID, models.ModeUpsertEmail, models.MissingKeep, "bob").Return(models.StagedSummary{Changed: 1}, nil)
+
+		w := approve(record)
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Contains(t, w.Body.String(), `"removed":0`)
+	})
+
+	t.Run("Removal is refused", func(t *testing.T) {
+		record := stage(t, map[string]string{"stage": "true", "mode": models.ModeUpsertEmail, "missing": models.MissingDelete})
+
+		w := approve(record)
+		assert.Equal(t, http.StatusUnprocessableEntity, w.Code)
+		assert.JSONEq(t, `{"status":"error","message":"1 rows could not be read; approving would delete their users"}`, w.Body.String())
+	})
+}
@@ -0,0 +1,165 @@
+package services
+
+import (
+	"csv-microservice/models"
+	"csv-microservice/utils"
+	"fmt"
+	"net/http"
+	"os"
+
+	"github.com/gin-gonic/gin"
+)
+
+// SyncCSV makes the users table mirror an uploaded snapshot, such as a nightly export of
+// the HR system. Rows are matched with users by mode (upsert_id or upsert_email): new rows
+// are inserted, changed users updated, and users missing from the file deleted or, with
+// missing=deactivate, set inactive (missing=keep leaves them). The file is loaded into
+// the staging area and applied in one transaction, and the response summarises the
+// changes. With dry_run=true the changes are only listed. Nothing is applied when a row
+// cannot be read, since the user of that row would count as missing. Otherwise it takes
+// the same fields as POST /upload.
+func (s *Service) SyncCSV(ctx *gin.Context) {
+	s.idempotent(ctx, "SyncCSV", func() {
+		s.syncUpload(ctx)
+	})
+}
+
+func (s *Service) syncUpload(ctx *gin.Context) {
+	file, header, err := ctx.Request.FormFile("file")
+	if err != nil {
+		utils.LogError("SyncCSV", "Failed to get file", err)
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "Failed to get file"})
+		return
+	}
+	defer file.Close()
+	utils.LogInfo("SyncCSV", "Received snapshot: "+header.Filename)
+
+	if !supportedUpload(header.Filename) || isArchive(header.Filename) {
+		utils.LogWarn("SyncCSV", "Invalid file format: "+header.Filename)
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "Only CSV files (.csv or .csv.gz) and Excel workbooks (.xlsx) can be synchronised."})
+		return
+	}
+
+	request, err := s.parseUploadRequest(ctx, false)
+	if err == nil && request.opts.Mode != models.ModeUpsertID && request.opts.Mode != models.ModeUpsertEmail {
+		err = fmt.Errorf("sync requires mode %s or %s to match rows with users", models.ModeUpsertID, models.ModeUpsertEmail)
+	}
+	if err != nil {
+		utils.LogWarn("SyncCSV", err.Error())
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	opts := request.opts
+	if importParam(ctx, "missing", "") == "" {
+		opts.Missing = models.MissingDelete // A snapshot removes whoever it leaves out
+	}
+	preview := opts.DryRun
+	// The snapshot is compared in the staging area. The same snapshot may come again,
+	// e.g. when nothing changed overnight, and must still remove users deleted since.
+	opts.DryRun, opts.Stage, opts.Force = false, true, true
+
+	path, upload, ok := s.saveUploadedFile(ctx, "SyncCSV", file)
+	if !ok {
+		return
+	}
+	defer os.Remove(path)
+	sources, err := uploadSources(path, header.Filename, request.sheet, request.dialect)
+	if err != nil {
+		utils.LogWarn("SyncCSV", fmt.Sprintf("Rejected file %s: %s", header.Filename, err.Error()))
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "Invalid upload: " + err.Error()})
+		return
+	}
+	src := sources[0]
+	columns, err := resolveSourceColumns(src, request.profile)
+	if err != nil {
+		utils.LogWarn("SyncCSV", fmt.Sprintf("Rejected file %s: %s", src.Name, err.Error()))
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "Invalid CSV header: " + err.Error()})
+		return
+	}
+	upload.Filename = header.Filename
+	if !preview {
+		s.archiveUpload("SyncCSV", path, &upload)
+	}
+
+	job := s.Jobs.Create(src.Name, opts)
+	task := newImportTask(job, columns, opts, src)
+	task.upload = upload
+	s.runImport(task)
+
+	snapshot := job.Snapshot()
+	if snapshot.State != models.JobCompleted {
+		s.discardSync(snapshot.ID, models.JobFailed)
+		ctx.JSON(http.StatusInternalServerError, gin.H{
+			"status":    "error",
+			"message":   "Sync failed: " + snapshot.Error,
+			"import_id": snapshot.ID,
+		})
+		return
+	}
+
+	if preview {
+		diff, err := s.Repo.DiffStagedImport(snapshot.ID, opts.Mode, opts.Missing)
+		s.discardSync(snapshot.ID, models.ImportPreviewed)
+		if err != nil {
+			utils.LogError("SyncCSV", "Failed to compare snapshot "+snapshot.Filename, err)
+			ctx.JSON(http.StatusInternalServerError, gin.H{
+				"status":  "error",
+				"message": "Failed to compare snapshot",
+			})
+			return
+		}
+		ctx.JSON(http.StatusOK, gin.H{
+			"status":      "success",
+			"dry_run":     true,
+			"import_id":   snapshot.ID,
+			"missing":     opts.Missing,
+			"rows_read":   snapshot.RowsRead,
+			"rows_failed": snapshot.RowsFailed,
+			"errors":      job.RowErrors(),
+			"data":        diff,
+		})
+		return
+	}
+
+	if snapshot.RowsFailed > 0 {
+		s.discardSync(snapshot.ID, models.JobFailed)
+		utils.LogWarn("SyncCSV", fmt.Sprintf("Refused to sync %s: %d rows could not be read", snapshot.Filename, snapshot.RowsFailed))
+		ctx.JSON(http.StatusUnprocessableEntity, gin.H{
+			"status":    "error",
+			"message":   fmt.Sprintf("%d rows could not be read; nothing was changed", snapshot.RowsFailed),
+			"import_id": snapshot.ID,
+			"errors":    job.RowErrors(),
+		})
+		return
+	}
+
+	summary, err := s.Repo.ApproveStagedImport(snapshot.ID, opts.Mode, opts.Missing, "")
+	if err != nil {
+		s.discardSync(snapshot.ID, models.JobFailed)
+		utils.LogError("SyncCSV", "Failed to apply snapshot "+snapshot.Filename, err)
+		ctx.JSON(http.StatusInternalServerError, gin.H{
+			"status":    "error",
+			"message":   "Failed to apply snapshot",
+			"import_id": snapshot.ID,
+		})
+		return
+	}
+	utils.LogInfo("SyncCSV", fmt.Sprintf("Synchronised users with %s: %d inserted, %d updated, %d deleted, %d deactivated, %d unchanged",
+		snapshot.Filename, summary.New, summary.Changed, summary.Removed, summary.Deactivated, summary.Unchanged))
+
+	ctx.JSON(http.StatusOK, gin.H{
+		"status":    "success",
+		"message":   "Users synchronised with " + snapshot.Filename,
+		"import_id": snapshot.ID,
+		"missing":   opts.Missing,
+		"data":      summary,
+	})
+}
+
+// discardSync drops the staged rows of a sync that is not applied, moving its import to
+// state. A failure is logged; the rows are only left behind.
+func (s *Service) discardSync(id, state string) {
+	if err := s.Repo.DiscardStagedImport(id, state); err != nil {
+		utils.LogError("SyncCSV", "Failed to discard staged rows of import "+id, err)
+	}
+}
@@ -0,0 +1,89 @@
+package services
+
+import (
+	"csv-microservice/mock"
+	"csv-microservice/models"
+	"csv-microservice/utils"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSyncCSV(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockRepo := mock.NewMockRepositoryInterface(ctrl)
+	service := NewService(mockRepo)
+	utils.InitLogger()
+
+	router := gin.Default()
+	router.POST("/sync", service.SyncCSV)
+
+	content := "first_name,last_name,email,salary\nJohn,Doe,john@example.com,50000\nJane,Roe,jane@example.com,60000\n"
+	fields := map[string]string{"mode": models.ModeUpsertEmail, "missing": models.MissingDeactivate}
+	expectNewUploads(mockRepo)
+	expectChunks(mockRepo)
+	mockRepo.EXPECT().SaveImport(gomock.Any()).Return(nil).AnyTimes()
+	mockRepo.EXPECT().StageRows(gomock.Any(), models.ModeUpsertEmail).Return(nil).AnyTimes()
+
+	t.Run("Applies the snapshot", func(t *testing.T) {
+		mockRepo.EXPECT().ApproveStagedImport(gomock.Any(), models.ModeUpsertEmail, models.MissingDeactivate, "").
+			Return(models.StagedSummary{New: 1, Changed: 1, Deactivated: 3}, nil)
+
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, newUploadRequest(t, "/sync", "users.csv", content, fields))
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Contains(t, w.Body.String(), `"data":{"new":1,"changed":1,"removed":0,"deactivated":3,"unchanged":0}`)
+		assert.Contains(t, w.Body.String(), `"missing":"deactivate"`)
+	})
+
+	t.Run("Preview", func(t *testing.T) {
+		mockRepo.EXPECT().DiffStagedImport(gomock.Any(), models.ModeUpsertEmail, models.MissingDeactivate).
+			Return(models.StagedDiff{Summary: models.StagedSummary{Unchanged: 2}}, nil)
+		mockRepo.EXPECT().DiscardStagedImport(gomock.Any(), models.ImportPreviewed).Return(nil)
+
+		preview := map[string]string{"dry_run": "true"}
+		for key, value := range fields {
+			preview[key] = value
+		}
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, newUploadRequest(t, "/sync", "users.csv", content, preview))
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Contains(t, w.Body.String(), `"dry_run":true`)
+		assert.Contains(t, w.Body.String(), `"summary":{"new":0,"changed":0,"removed":0,"deactivated":0,"unchanged":2}`)
+	})
+
+	t.Run("Refuses a snapshot with unreadable rows", func(t *testing.T) {
+		mockRepo.EXPECT().DiscardStagedImport(gomock.Any(), models.JobFailed).Return(nil)
+
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, newUploadRequest(t, "/sync", "users.csv", content+"Jim,Poe,jim@example.com,oops\n", fields))
+		assert.Equal(t, http.StatusUnprocessableEntity, w.Code)
+		assert.Contains(t, w.Body.String(), `"message":"1 rows could not be read; nothing was changed"`)
+	})
+
+	t.Run("Requires an upsert mode", func(t *testing.T) {
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, newUploadRequest(t, "/sync", "users.csv", content, nil))
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+		assert.JSONEq(t, `{"error":"sync requires mode upsert_id or upsert_email to match rows with users"}`, w.Body.String())
+	})
+
+	t.Run("Invalid missing", func(t *testing.T) {
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, newUploadRequest(t, "/sync", "users.csv", content, map[string]string{"mode": models.ModeUpsertEmail, "missing": "ignore"}))
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+		assert.JSONEq(t, `{"error":"invalid missing \"ignore\": expected keep, delete or deactivate"}`, w.Body.String())
+	})
+
+	t.Run("Rejects archives", func(t *testing.T) {
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, newUploadRequest(t, "/sync", "users.zip", "PK", fields))
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+	})
+}
@@ -0,0 +1,175 @@
+package services
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+	"unicode"
+)
+
+// Transformation operations available to TransformRule.
+const (
+	TransformTrim           = "trim"            // Strip surrounding whitespace
+	TransformCollapseSpaces = "collapse_spaces" // Replace runs of whitespace with a single space
+	TransformLowercase      = "lowercase"
+	TransformUppercase      = "uppercase"
+	TransformTitleCase      = "title_case" // Capitalise the first letter of every word
+	TransformMap            = "map"        // Replace whole values listed in Values, e.g. "M" -> "Male"
+)
+
+// allFields selects every User field in a TransformRule.
+const allFields = "*"
+
+// TransformRule rewrites the value of a field after the CSV is parsed and before the
+// value is converted for models.User. Rules run in the order they are configured.
+type TransformRule struct {
+	Field      string            `json:"field"` // User field name, or "*" for every field
+	Op         string            `json:"op"`
+	Values     map[string]string `json:"values,omitempty"`      // For "map": value -> replacement
+	IgnoreCase bool              `json:"ignore_case,omitempty"` // For "map": match values case-insensitively
+}
+
+// TransformChange records a value changed by a rule, for the dry-run preview.
+type TransformChange struct {
+	Field  string `json:"field"`
+	Op     string `json:"op"`
+	Before string `json:"before"`
+	After  string `json:"after"`
+}
+
+// rowTransforms lists the changes made to one row.
+type rowTransforms struct {
+	Line    int               `json:"line"`
+	Changes []TransformChange `json:"changes"`
+}
+
+// LoadTransformRules reads transformation rules from a JSON file containing an array of
+// rules. An empty path loads no rules.
+func LoadTransformRules(path string) ([]TransformRule, error) {
+	if path == "" {
+		return nil, nil
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read transform rules: %w", err)
+	}
+	var rules []TransformRule
+	if err := json.Unmarshal(data, &rules); err != nil {
+		return nil, fmt.Errorf("failed to parse transform rules: %w", err)
+	}
+	for i, rule := range rules {
+		if err := validateRule(rule); err != nil {
+			return nil, fmt.Errorf("transform rule %d: %w", i+1, err)
+		}
+	}
+	return rules, nil
+}
+
+// validateRule checks that a rule names a known field and operation.
+func validateRule(rule TransformRule) error {
+	known := rule.Field == allFields
+	for _, field := range userFields {
+		known = known || rule.Field == field
+	}
+	if !known {
+		return fmt.Errorf("unknown field %q", rule.Field)
+	}
+	switch rule.Op {
+	case TransformTrim, TransformCollapseSpaces, TransformLowercase, TransformUppercase, TransformTitleCase:
+	case TransformMap:
+		if len(rule.Values) == 0 {
+			return fmt.Errorf("map rule for %s has no values", rule.Field)
+		}
+	default:
+		return fmt.Errorf("unknown op %q", rule.Op)
+	}
+	return nil
+}
+
+// fields lists the User fields a rule applies to.
+func (r TransformRule) fields() []string {
+	if r.Field == allFields {
+		return userFields
+	}
+	return []string{r.Field}
+}
+
+// apply returns the value after the rule.
+func (r TransformRule) apply(value string) string {
+	switch r.Op {
+	case TransformTrim:
+		return strings.TrimSpace(value)
+	case TransformCollapseSpaces:
+		return strings.Join(strings.Fields(value), " ")
+	case TransformLowercase:
+		return strings.ToLower(value)
+	case TransformUppercase:
+		return strings.ToUpper(value)
+	case TransformTitleCase:
+		return titleCase(value)
+	case TransformMap:
+		if mapped, ok := r.Values[value]; ok {
+			return mapped
+		}
+		if r.IgnoreCase {
+			for from, to := range r.Values {
+				if strings.EqualFold(from, value) {
+					return to
+				}
+			}
+		}
+	}
+	return value
+}
+
+// titleCase capitalises the first letter of every word and lowercases the rest. Words
+// are separated by spaces and hyphens, so "mary-JANE o'neil" becomes "Mary-Jane O'neil".
+func titleCase(value string) string {
+	runes := []rune(value)
+	wordStart := true
+	for i, r := range runes {
+		switch {
+		case unicode.IsSpace(r) || r == '-':
+			wordStart = true
+		case wordStart:
+			runes[i] = unicode.ToUpper(r)
+			wordStart = false
+		default:
+			runes[i] = unicode.ToLower(r)
+		}
+	}
+	return string(runes)
+}
+
+// transformRecord applies the rules to the mapped cells of a record. The record itself
+// is left untouched so rejected rows are reported as read. The changes are collected
+// only when trace is set.
+func transformRecord(rules []TransformRule, record []string, columns columnMap, trace bool) ([]string, []TransformChange) {
+	if len(rules) == 0 {
+		return record, nil
+	}
+
+	result := make([]string, len(record))
+	copy(result, record)
+	var changes []TransformChange
+	for _, rule := range rules {
+		for _, field := range rule.fields() {
+			idx, ok := columns.fields[field]
+			if !ok || idx >= len(result) {
+				continue
+			}
+			before := result[idx]
+			after := rule.apply(before)
+			if after == before {
+				continue
+			}
+			result[idx] = after
+			if trace {
+				changes = append(changes, TransformChange{Field: field, Op: rule.Op, Before: before, After: after})
+			}
+		}
+	}
+	return result, changes
+}
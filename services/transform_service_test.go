@@ -0,0 +1,146 @@
+package services
+
+import (
+	"csv-microservice/mock"
+	"csv-microservice/models"
+	"csv-microservice/utils"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLoadTransformRules(t *testing.T) {
+	dir := t.TempDir()
+
+	t.Run("No file", func(t *testing.T) {
+		rules, err := LoadTransformRules("")
+		assert.NoError(t, err)
+		assert.Empty(t, rules)
+	})
+
+	t.Run("Valid file", func(t *testing.T) {
+		path := filepath.Join(dir, "transforms.json")
+		os.WriteFile(path, []byte(`[{"field":"*","op":"trim"},{"field":"gender","op":"map","values":{"m":"Male"},"ignore_case":true}]`), 0644)
+
+		rules, err := LoadTransformRules(path)
+		assert.NoError(t, err)
+		assert.Equal(t, []TransformRule{
+			{Field: "*", Op: TransformTrim},
+			{Field: FieldGender, Op: TransformMap, Values: map[string]string{"m": "Male"}, IgnoreCase: true},
+		}, rules)
+	})
+
+	tests := []struct {
+		name    string
+		content string
+		err     string
+	}{
+		{"Unknown field", `[{"field":"nickname","op":"trim"}]`, `transform rule 1: unknown field "nickname"`},
+		{"Unknown op", `[{"field":"email","op":"trim"},{"field":"email","op":"reverse"}]`, `transform rule 2: unknown op "reverse"`},
+		{"Map without values", `[{"field":"gender","op":"map"}]`, `transform rule 1: map rule for gender has no values`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(dir, "bad.json")
+			os.WriteFile(path, []byte(tt.content), 0644)
+
+			_, err := LoadTransformRules(path)
+			assert.EqualError(t, err, tt.err)
+		})
+	}
+}
+
+func TestTransformRule_Apply(t *testing.T) {
+	tests := []struct {
+		rule     TransformRule
+		value    string
+		expected string
+	}{
+		{TransformRule{Op: TransformTrim}, "  John ", "John"},
+		{TransformRule{Op: TransformCollapseSpaces}, " Mary   Ann ", "Mary Ann"},
+		{TransformRule{Op: TransformLowercase}, "John@Example.COM", "john@example.com"},
+		{TransformRule{Op: TransformUppercase}, "hr", "HR"},
+		{TransformRule{Op: TransformTitleCase}, "mary-JANE o'neil", "Mary-Jane O'neil"},
+		{TransformRule{Op: TransformMap, Values: map[string]string{"M": "Male"}}, "M", "Male"},
+		{TransformRule{Op: TransformMap, Values: map[string]string{"M": "Male"}}, "m", "m"},
+		{TransformRule{Op: TransformMap, Values: map[string]string{"M": "Male"}, IgnoreCase: true}, "m", "Male"},
+		{TransformRule{Op: TransformMap, Values: map[string]string{"M": "Male"}}, "Other", "Other"},
+	}
+	for _, tt := range tests {
+		assert.Equal(t, tt.expected, tt.rule.apply(tt.value), "%s %q", tt.rule.Op, tt.value)
+	}
+}
+
+func TestTransformRecord(t *testing.T) {
+	columns, _ := resolveColumns([]string{"first_name", "email", "notes"}, MappingProfile{})
+	rules := []TransformRule{
+		{Field: "*", Op: TransformTrim},
+		{Field: FieldEmail, Op: TransformLowercase},
+		{Field: FieldFirstName, Op: TransformTitleCase},
+	}
+	record := []string{" jOHN ", "John@Example.com", "  unmapped  "}
+
+	values, changes := transformRecord(rules, record, columns, true)
+	assert.Equal(t, []string{"John", "john@example.com", "  unmapped  "}, values)
+	assert.Equal(t, []string{" jOHN ", "John@Example.com", "  unmapped  "}, record, "The record read is left untouched")
+	assert.Equal(t, []TransformChange{
+		{Field: FieldFirstName, Op: TransformTrim, Before: " jOHN ", After: "jOHN"},
+		{Field: FieldEmail, Op: TransformLowercase, Before: "John@Example.com", After: "john@example.com"},
+		{Field: FieldFirstName, Op: TransformTitleCase, Before: "jOHN", After: "John"},
+	}, changes)
+
+	_, changes = transformRecord(rules, record, columns, false)
+	assert.Empty(t, changes)
+}
+
+func TestValidateCSV_Transforms(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockRepo := mock.NewMockRepositoryInterface(ctrl)
+	service := NewService(mockRepo)
+	service.Transforms = []TransformRule{
+		{Field: FieldEmail, Op: TransformLowercase},
+		{Field: FieldGender, Op: TransformMap, Values: map[string]string{"M": "Male", "F": "Female"}},
+	}
+	utils.InitLogger()
+
+	router := gin.Default()
+	router.POST("/validate", service.ValidateCSV)
+
+	content := "first_name,last_name,email,gender\n" +
+		"John,Doe,John@Example.com,M\n" +
+		"Jane,Roe,jane@example.com,F\n"
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, newUploadRequest(t, "/validate", "users.csv", content, nil))
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	var resp struct {
+		Data struct {
+			Preview    []models.User   `json:"preview"`
+			Transforms []rowTransforms `json:"transforms"`
+		} `json:"data"`
+	}
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &resp))
+	if assert.Len(t, resp.Data.Preview, 2) {
+		assert.Equal(t, "john@example.com", resp.Data.Preview[0].Email)
+		assert.Equal(t, "Male", resp.Data.Preview[0].Gender)
+		assert.Equal(t, "Female", resp.Data.Preview[1].Gender)
+	}
+	assert.Equal(t, []rowTransforms{
+		{Line: 2, Changes: []TransformChange{
+			{Field: FieldEmail, Op: TransformLowercase, Before: "John@Example.com", After: "john@example.com"},
+			{Field: FieldGender, Op: TransformMap, Before: "M", After: "Male"},
+		}},
+		{Line: 3, Changes: []TransformChange{
+			{Field: FieldGender, Op: TransformMap, Before: "F", After: "Female"},
+		}},
+	}, resp.Data.Transforms)
+}
@@ -0,0 +1,127 @@
+package services
+
+import (
+	"context"
+	"csv-microservice/models"
+	"csv-microservice/utils"
+	"fmt"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+// ValidateCSV runs an upload through parsing, mapping and validation without writing
+// anything. Stored users are looked up under the selected mode, so the response tells how
+// many rows would be inserted, update a user or be skipped. It behaves like POST
+// /upload?dry_run=true.
+func (s *Service) ValidateCSV(ctx *gin.Context) {
+	s.handleUpload(ctx, "ValidateCSV", true)
+}
+
+// validateUpload runs dry-run imports synchronously and responds with what would have
+// been written. Archives report one result per CSV file.
+func (s *Service) validateUpload(ctx *gin.Context, tasks []*importTask, rejected []gin.H, archive bool) {
+	results := make([]gin.H, 0, len(tasks))
+	for _, task := range tasks {
+		// Validation runs while the client waits; stop it when the client goes away.
+		stop := context.AfterFunc(ctx.Request.Context(), func() { task.job.Cancel() })
+		result, err := s.validateTask(task)
+		stop()
+		if err != nil {
+			utils.LogError("validateUpload", "Failed to validate file", err)
+			ctx.JSON(http.StatusInternalServerError, gin.H{
+				"status":  "error",
+				"message": "Failed to validate file",
+			})
+			return
+		}
+		results = append(results, result)
+	}
+
+	if !archive {
+		ctx.JSON(http.StatusOK, gin.H{
+			"status":  "success",
+			"dry_run": true,
+			"data":    results[0],
+		})
+		return
+	}
+	ctx.JSON(http.StatusOK, gin.H{
+		"status":   "success",
+		"dry_run":  true,
+		"data":     results,
+		"rejected": rejected,
+	})
+}
+
+// validateTask runs one dry-run import and summarises it.
+func (s *Service) validateTask(task *importTask) (gin.H, error) {
+	job := task.job
+	job.start()
+	// A single worker keeps the preview in file order; nothing waits on the database.
+	err := s.importFile(task, s.Scheduler.open(1))
+	job.finish(err)
+	task.cancel()
+	if err != nil {
+		return nil, err
+	}
+
+	snapshot := job.Snapshot()
+	planned := task.plannedResult()
+	valid := planned.Inserted + planned.Updated + planned.Skipped
+	utils.LogInfo("validateTask", fmt.Sprintf("Validated %s: %d rows read, %d valid, %d rejected", snapshot.Filename, snapshot.RowsRead, valid, snapshot.RowsFailed))
+	result := gin.H{
+		"filename":     snapshot.Filename,
+		"mode":         snapshot.Mode,
+		"rows_read":    snapshot.RowsRead,
+		"rows_valid":   valid,
+		"would_insert": planned.Inserted,
+		"would_update": planned.Updated,
+		"would_skip":   planned.Skipped,
+		"rows_failed":  snapshot.RowsFailed,
+		"errors":       job.RowErrors(),
+		"preview":      task.previewRecords(),
+	}
+	if len(s.Transforms) > 0 {
+		result["transforms"] = task.previewChanges()
+	}
+	return result, nil
+}
+
+// addPreview keeps parsed records, and the changes the transform rules made to them,
+// until the preview limit is reached.
+func (t *importTask) addPreview(batch []pendingRow) {
+	t.previewMu.Lock()
+	defer t.previewMu.Unlock()
+	for _, pending := range batch {
+		if len(t.preview) >= t.opts.Preview {
+			return
+		}
+		t.preview = append(t.preview, pending.record.User)
+		if len(pending.changes) > 0 {
+			t.previewTransforms = append(t.previewTransforms, rowTransforms{Line: pending.row.Line, Changes: pending.changes})
+		}
+	}
+}
+
+func (t *importTask) plannedResult() models.WriteResult {
+	t.previewMu.Lock()
+	defer t.previewMu.Unlock()
+	return t.planned
+}
+
+func (t *importTask) previewChanges() []rowTransforms {
+	t.previewMu.Lock()
+	defer t.previewMu.Unlock()
+	result := make([]rowTransforms, len(t.previewTransforms))
+	copy(result, t.previewTransforms)
+	return result
+}
+
+func (t *importTask) previewRecords() []models.User {
+	t.previewMu.Lock()
+	defer t.previewMu.Unlock()
+	result := make([]models.User, len(t.preview))
+	copy(result, t.preview)
+	return result
+}
@@ -0,0 +1,123 @@
+package services
+
+import (
+	"bytes"
+	"csv-microservice/mock"
+	"csv-microservice/models"
+	"csv-microservice/utils"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/assert"
+)
+
+// newUploadRequest builds a multipart upload request with a file and extra form fields.
+func newUploadRequest(t *testing.T, target, fileName, content string, fields map[string]string) *http.Request {
+	body := &bytes.Buffer{}
+	writer := multipart.NewWriter(body)
+	part, err := writer.CreateFormFile("file", fileName)
+	assert.NoError(t, err, "Failed to create form file")
+	_, err = part.Write([]byte(content))
+	assert.NoError(t, err, "Failed to write file content")
+	for key, value := range fields {
+		assert.NoError(t, writer.WriteField(key, value))
+	}
+	writer.Close()
+
+	req := httptest.NewRequest("POST", target, body)
+	req.Header.Set("Content-Type", writer.FormDataContentType())
+	return req
+}
+
+func TestValidateCSV(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	// A dry run must not write anything; it only looks up stored users
+	mockRepo := mock.NewMockRepositoryInterface(ctrl)
+	service := NewService(mockRepo)
+	utils.InitLogger()
+
+	router := gin.Default()
+	router.POST("/upload", service.UploadCSV)
+	router.POST("/validate", service.ValidateCSV)
+
+	content := "id,first_name,last_name,email,age\n" +
+		"1,John,Doe,john@example.com,30\n" +
+		"2,Jane,Roe,jane@example.com,old\n" +
+		"3,Jim,Poe,jim@example.com,40\n"
+	expected := `{
+		"status": "success",
+		"dry_run": true,
+		"data": {
+			"filename": "users.csv",
+			"mode": "insert",
+			"rows_read": 3,
+			"rows_valid": 2,
+			"would_insert": 2,
+			"would_update": 0,
+			"would_skip": 0,
+			"rows_failed": 1,
+			"errors": [
+				{"line": 3, "values": ["2", "Jane", "Roe", "jane@example.com", "old"], "reason": "age: invalid integer \"old\""}
+			],
+			"preview": [
+				{"id": 1, "first_name": "John", "last_name": "Doe", "email": "john@example.com", "age": 30, "gender": "", "department": "", "company": "", "salary": 0, "date_joined": "", "is_active": false}
+			]
+		}
+	}`
+
+	t.Run("Upload with dry_run", func(t *testing.T) {
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, newUploadRequest(t, "/upload?dry_run=true&preview=1", "users.csv", content, nil))
+
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.JSONEq(t, expected, w.Body.String())
+		assert.Empty(t, service.Jobs.List(10), "Dry runs are not registered as jobs")
+	})
+
+	t.Run("Validate endpoint", func(t *testing.T) {
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, newUploadRequest(t, "/validate", "users.csv", content, map[string]string{"preview": "1"}))
+
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.JSONEq(t, expected, w.Body.String())
+	})
+
+	t.Run("Existing users", func(t *testing.T) {
+		// John is stored; Jim's second row updates the user his first row would insert
+		upserts := "first_name,last_name,email\nJohn,Doe,john@example.com\nJim,Poe,jim@example.com\nJim,Poe,jim@example.com\n"
+		validate := func(mode string) *httptest.ResponseRecorder {
+			mockRepo.EXPECT().FindExistingUsers(gomock.Len(3), mode).Return([]bool{true, false, false}, nil)
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, newUploadRequest(t, "/validate", "users.csv", upserts, map[string]string{"mode": mode}))
+			assert.Equal(t, http.StatusOK, w.Code)
+			return w
+		}
+
+		w := validate(models.ModeUpsertEmail)
+		assert.Contains(t, w.Body.String(), `"rows_valid":3,"would_insert":1,"would_skip":0,"would_update":2`)
+		w = validate(models.ModeSkipExisting)
+		assert.Contains(t, w.Body.String(), `"rows_valid":3,"would_insert":1,"would_skip":2,"would_update":0`)
+	})
+
+	t.Run("Invalid header", func(t *testing.T) {
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, newUploadRequest(t, "/validate", "users.csv", "id,name\n1,John\n", nil))
+
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+		assert.JSONEq(t, `{"error":"Invalid CSV header: missing required columns: first_name, last_name, email"}`, w.Body.String())
+	})
+
+	t.Run("Invalid preview", func(t *testing.T) {
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, newUploadRequest(t, "/validate?preview=-1", "users.csv", content, nil))
+
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+		assert.JSONEq(t, `{"error":"invalid preview value \"-1\": expected 0 to 1000"}`, w.Body.String())
+	})
+}
@@ -0,0 +1,305 @@
+package services
+
+import (
+	"bufio"
+	"context"
+	"crypto/sha256"
+	"csv-microservice/models"
+	"csv-microservice/utils"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Subfolders of the drop folder that files are moved to once handled.
+const (
+	processedDir = "processed"
+	failedDir    = "failed"
+	ledgerFile   = ".imported.jsonl"
+)
+
+// watcherUploader is recorded as the uploader of files imported from the drop folder.
+const watcherUploader = "folder-watcher"
+
+// ledgerEntry records a file whose content has been taken up for import.
+type ledgerEntry struct {
+	SHA256     string    `json:"sha256"`
+	File       string    `json:"file"`
+	ImportedAt time.Time `json:"imported_at"`
+}
+
+// FolderWatcher imports files dropped into a directory by systems that cannot call the
+// HTTP API. Each file goes through the same pipeline as UploadCSV and is then moved to
+// processed/ or failed/, next to its error report. The SHA-256 of every file taken up is
+// kept in a ledger, so a file is never imported twice, even across restarts.
+type FolderWatcher struct {
+	service  *Service
+	dir      string
+	interval time.Duration
+
+	mu       sync.Mutex
+	imported map[string]ledgerEntry // SHA-256 -> ledger entry
+	pending  map[string]os.FileInfo // Files seen on the last scan, imported once unchanged
+}
+
+// NewFolderWatcher prepares a watcher for dir, creating its subfolders and loading the ledger.
+func NewFolderWatcher(service *Service, dir string, interval time.Duration) (*FolderWatcher, error) {
+	for _, sub := range []string{processedDir, failedDir} {
+		if err := os.MkdirAll(filepath.Join(dir, sub), 0o755); err != nil {
+			return nil, fmt.Errorf("failed to create watch folder: %w", err)
+		}
+	}
+	watcher := &FolderWatcher{
+		service:  service,
+		dir:      dir,
+		interval: interval,
+		imported: make(map[string]ledgerEntry),
+		pending:  make(map[string]os.FileInfo),
+	}
+	if err := watcher.loadLedger(); err != nil {
+		return nil, err
+	}
+	return watcher, nil
+}
+
+// Run scans the folder every interval until ctx is cancelled.
+func (w *FolderWatcher) Run(ctx context.Context) {
+	utils.LogInfo("FolderWatcher", "Watching "+w.dir)
+	ticker := time.NewTicker(w.interval)
+	defer ticker.Stop()
+	for {
+		w.Scan()
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// Scan imports the files that have not changed since the previous scan. A file still
+// being written shows a new size or modification time and is left for the next scan.
+func (w *FolderWatcher) Scan() {
+	entries, err := os.ReadDir(w.dir)
+	if err != nil {
+		utils.LogError("FolderWatcher", "Failed to read watch folder", err)
+		return
+	}
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	seen := make(map[string]os.FileInfo)
+	for _, entry := range entries {
+		name := entry.Name()
+		if !entry.Type().IsRegular() || strings.HasPrefix(name, ".") || strings.HasPrefix(name, "~") {
+			continue
+		}
+		info, err := entry.Info()
+		if err != nil {
+			continue
+		}
+		prev, ok := w.pending[name]
+		if !ok || prev.Size() != info.Size() || !prev.ModTime().Equal(info.ModTime()) {
+			seen[name] = info
+			continue
+		}
+		w.importFile(name)
+	}
+	w.pending = seen
+}
+
+// importFile runs one dropped file through the import pipeline and moves it away.
+func (w *FolderWatcher) importFile(name string) {
+	path := filepath.Join(w.dir, name)
+	if !supportedUpload(name) {
+		utils.LogWarn("FolderWatcher", "Unsupported file type: "+name)
+		w.finish(name, false, "unsupported file type, expected .csv, .csv.gz, .zip or .xlsx", nil)
+		return
+	}
+
+	upload, err := fileUploadInfo(path)
+	if err != nil {
+		utils.LogError("FolderWatcher", "Failed to read "+name, err)
+		return
+	}
+	sum := upload.SHA256
+	if prev, ok := w.imported[sum]; ok {
+		utils.LogWarn("FolderWatcher", fmt.Sprintf("Skipping %s: same content as %s", name, prev.File))
+		w.finish(name, false, fmt.Sprintf("already imported: same content as %s, taken up at %s", prev.File, prev.ImportedAt.Format(time.RFC3339)), nil)
+		return
+	}
+
+	sources, err := uploadSources(path, name, "", csvDialect{})
+	if err != nil {
+		w.finish(name, false, "invalid file: "+err.Error(), nil)
+		return
+	}
+
+	var problems []string
+	var accepted []importSource
+	var columns []columnMap
+	for _, src := range sources {
+		cols, err := resolveSourceColumns(src, DefaultMappingProfile)
+		if err != nil {
+			problems = append(problems, fmt.Sprintf("%s: invalid CSV header: %s", src.Name, err.Error()))
+			continue
+		}
+		accepted = append(accepted, src)
+		columns = append(columns, cols)
+	}
+	if len(accepted) == 0 {
+		// Nothing was taken up, so the same content may be dropped again once fixed
+		w.finish(name, false, strings.Join(problems, "\n"), nil)
+		return
+	}
+
+	// Record the file before importing so an interrupted import is not repeated after a restart.
+	if err := w.record(ledgerEntry{SHA256: sum, File: name, ImportedAt: time.Now()}); err != nil {
+		utils.LogError("FolderWatcher", "Failed to update ledger, leaving "+name+" in place", err)
+		return
+	}
+
+	upload.Filename = name
+	w.service.archiveUpload("FolderWatcher", path, &upload)
+
+	tasks := make([]*importTask, len(accepted))
+	for i, src := range accepted {
+		job := w.service.Jobs.Create(src.Name, defaultImportOptions)
+		tasks[i] = newImportTask(job, columns[i], defaultImportOptions, src)
+		tasks[i].upload = upload
+	}
+
+	succeeded := true
+	for _, task := range tasks {
+		utils.LogInfo("FolderWatcher", fmt.Sprintf("Importing %s as job %s", task.source.Name, task.job.Snapshot().ID))
+		w.service.runImport(task)
+		if snapshot := task.job.Snapshot(); snapshot.State == models.JobFailed {
+			succeeded = false
+			problems = append(problems, fmt.Sprintf("%s: job %s failed: %s", snapshot.Filename, snapshot.ID, snapshot.Error))
+		}
+	}
+	w.finish(name, succeeded, strings.Join(problems, "\n"), tasks)
+}
+
+// finish moves a file to processed/ or failed/. A non-empty message is written to
+// <file>.error.txt, and the rejected rows of each job to <source>.errors.csv.
+func (w *FolderWatcher) finish(name string, succeeded bool, message string, tasks []*importTask) {
+	destDir := filepath.Join(w.dir, failedDir)
+	if succeeded {
+		destDir = filepath.Join(w.dir, processedDir)
+	}
+	dest := uniquePath(destDir, name)
+	if err := os.Rename(filepath.Join(w.dir, name), dest); err != nil {
+		utils.LogError("FolderWatcher", "Failed to move "+name, err)
+		return
+	}
+
+	if message != "" {
+		if err := os.WriteFile(dest+".error.txt", []byte(message+"\n"), 0o644); err != nil {
+			utils.LogError("FolderWatcher", "Failed to write error file for "+name, err)
+		}
+	}
+	for _, task := range tasks {
+		rowErrors := task.job.RowErrors()
+		if len(rowErrors) == 0 {
+			continue
+		}
+		report := dest + ".errors.csv"
+		if member := strings.TrimPrefix(task.source.Name, name+"/"); member != task.source.Name {
+			report = dest + "." + strings.ReplaceAll(member, "/", "_") + ".errors.csv"
+		}
+		if err := writeErrorReportFile(report, rowErrors); err != nil {
+			utils.LogError("FolderWatcher", "Failed to write error report for "+task.source.Name, err)
+		}
+	}
+	utils.LogInfo("FolderWatcher", fmt.Sprintf("Moved %s to %s", name, dest))
+}
+
+func writeErrorReportFile(path string, rowErrors []models.RowError) error {
+	file, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	if err := writeErrorReport(file, rowErrors); err != nil {
+		file.Close()
+		return err
+	}
+	return file.Close()
+}
+
+// uniquePath returns dir/name, or dir/name with a numeric suffix when that is taken.
+func uniquePath(dir, name string) string {
+	dest := filepath.Join(dir, name)
+	for i := 1; ; i++ {
+		if _, err := os.Stat(dest); os.IsNotExist(err) {
+			return dest
+		}
+		dest = filepath.Join(dir, fmt.Sprintf("%s.%d", name, i))
+	}
+}
+
+// fileUploadInfo describes a dropped file for the import audit.
+func fileUploadInfo(path string) (uploadInfo, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return uploadInfo{}, err
+	}
+	defer file.Close()
+	hash := sha256.New()
+	size, err := io.Copy(hash, file)
+	if err != nil {
+		return uploadInfo{}, err
+	}
+	return uploadInfo{Size: size, SHA256: hex.EncodeToString(hash.Sum(nil)), Uploader: watcherUploader}, nil
+}
+
+func (w *FolderWatcher) loadLedger() error {
+	file, err := os.Open(filepath.Join(w.dir, ledgerFile))
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("failed to open import ledger: %w", err)
+	}
+	defer file.Close()
+
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		var entry ledgerEntry
+		if err := json.Unmarshal(scanner.Bytes(), &entry); err != nil {
+			continue // A line cut short by a crash
+		}
+		w.imported[entry.SHA256] = entry
+	}
+	return scanner.Err()
+}
+
+func (w *FolderWatcher) record(entry ledgerEntry) error {
+	line, err := json.Marshal(entry)
+	if err != nil {
+		return err
+	}
+	file, err := os.OpenFile(filepath.Join(w.dir, ledgerFile), os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0o644)
+	if err != nil {
+		return err
+	}
+	if _, err := file.Write(append(line, '\n')); err != nil {
+		file.Close()
+		return err
+	}
+	if err := file.Sync(); err != nil {
+		file.Close()
+		return err
+	}
+	if err := file.Close(); err != nil {
+		return err
+	}
+	w.imported[entry.SHA256] = entry
+	return nil
+}
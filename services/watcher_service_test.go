@@ -0,0 +1,125 @@
+package services
+
+import (
+	"csv-microservice/mock"
+	"csv-microservice/utils"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFolderWatcher(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockRepo := mock.NewMockRepositoryInterface(ctrl)
+	expectImportAudit(mockRepo)
+	service := NewService(mockRepo)
+	utils.InitLogger()
+
+	dir := t.TempDir()
+	watcher, err := NewFolderWatcher(service, dir, time.Second)
+	assert.NoError(t, err)
+
+	drop := func(name, content string) {
+		assert.NoError(t, os.WriteFile(filepath.Join(dir, name), []byte(content), 0o644))
+	}
+	readFile := func(parts ...string) string {
+		data, err := os.ReadFile(filepath.Join(append([]string{dir}, parts...)...))
+		assert.NoError(t, err)
+		return string(data)
+	}
+	valid := "first_name,last_name,email,age\nJohn,Doe,john@example.com,30\nJane,Roe,jane@example.com,old\n"
+
+	t.Run("Imports a file once it stops changing", func(t *testing.T) {
+		mockRepo.EXPECT().BulkInsert(gomock.Len(1)).Return(nil).Times(1)
+
+		drop("users.csv", valid)
+		watcher.Scan() // First sighting only
+		assert.FileExists(t, filepath.Join(dir, "users.csv"))
+
+		watcher.Scan()
+		assert.NoFileExists(t, filepath.Join(dir, "users.csv"))
+		assert.Equal(t, valid, readFile(processedDir, "users.csv"))
+		assert.Equal(t, "line,reason,values\n3,\"age: invalid integer \"\"old\"\"\",Jane,Roe,jane@example.com,old\n", readFile(processedDir, "users.csv.errors.csv"))
+
+		jobs := service.Jobs.List(10)
+		if assert.Len(t, jobs, 1) {
+			assert.Equal(t, "users.csv", jobs[0].Filename)
+			assert.Equal(t, 1, jobs[0].RowsInserted)
+		}
+	})
+
+	t.Run("Never imports the same content twice", func(t *testing.T) {
+		drop("users-copy.csv", valid)
+		watcher.Scan()
+		watcher.Scan()
+
+		assert.FileExists(t, filepath.Join(dir, failedDir, "users-copy.csv"))
+		assert.Contains(t, readFile(failedDir, "users-copy.csv.error.txt"), "already imported: same content as users.csv")
+	})
+
+	t.Run("Ledger survives a restart", func(t *testing.T) {
+		restarted, err := NewFolderWatcher(service, dir, time.Second)
+		assert.NoError(t, err)
+
+		drop("users.csv", valid)
+		restarted.Scan()
+		restarted.Scan()
+
+		assert.FileExists(t, filepath.Join(dir, failedDir, "users.csv"))
+		assert.FileExists(t, filepath.Join(dir, processedDir, "users.csv")) // The first import is kept
+	})
+
+	t.Run("Invalid header fails the file", func(t *testing.T) {
+		drop("bad.csv", "name\nJohn\n")
+		watcher.Scan()
+		watcher.Scan()
+
+		assert.Equal(t, "bad.csv: invalid CSV header: missing required columns: first_name, last_name, email\n", readFile(failedDir, "bad.csv.error.txt"))
+
+		// A file that was refused is not in the ledger, so dropping it again validates it again
+		drop("bad.csv", "name\nJohn\n")
+		watcher.Scan()
+		watcher.Scan()
+		assert.Equal(t, "bad.csv: invalid CSV header: missing required columns: first_name, last_name, email\n", readFile(failedDir, "bad.csv.1.error.txt"))
+		assert.NotContains(t, readFile(ledgerFile), "bad.csv")
+	})
+
+	t.Run("Unsupported and hidden files", func(t *testing.T) {
+		drop("notes.txt", "hello")
+		drop(".users.csv.part", valid)
+		watcher.Scan()
+		watcher.Scan()
+
+		assert.FileExists(t, filepath.Join(dir, failedDir, "notes.txt"))
+		assert.FileExists(t, filepath.Join(dir, ".users.csv.part"))
+	})
+
+	t.Run("Waits while a file is still growing", func(t *testing.T) {
+		mockRepo.EXPECT().BulkInsert(gomock.Len(1)).Return(nil).Times(1)
+
+		drop("late.csv", "first_name,last_name,email\n")
+		watcher.Scan()
+		drop("late.csv", "first_name,last_name,email\nAnn,Lee,ann@example.com\n")
+		watcher.Scan()
+		assert.FileExists(t, filepath.Join(dir, "late.csv"))
+
+		watcher.Scan()
+		assert.FileExists(t, filepath.Join(dir, processedDir, "late.csv"))
+		assert.NoFileExists(t, filepath.Join(dir, processedDir, "late.csv.errors.csv"))
+	})
+}
+
+func TestUniquePath(t *testing.T) {
+	dir := t.TempDir()
+	assert.Equal(t, filepath.Join(dir, "a.csv"), uniquePath(dir, "a.csv"))
+
+	assert.NoError(t, os.WriteFile(filepath.Join(dir, "a.csv"), nil, 0o644))
+	assert.NoError(t, os.WriteFile(filepath.Join(dir, "a.csv.1"), nil, 0o644))
+	assert.Equal(t, filepath.Join(dir, "a.csv.2"), uniquePath(dir, "a.csv"))
+}